@@ -0,0 +1,73 @@
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"os"
+	"sync"
+	"time"
+)
+
+// This variable holds the audit logger every call to the NewRelic API is
+// recorded in. It is nil unless the audit_log_file input parameter is set.
+var auditLogger *AuditLogger
+
+// This struct is used to marshal a single line of the audit log. It must
+// never contain the API key or the body of a request or response.
+type auditEntry struct {
+	Timestamp          string `json:"timestamp"`
+	Endpoint           string `json:"endpoint"`
+	RequestBodyHash    string `json:"requestBodyHash"`
+	ResponseStatusCode int    `json:"responseStatusCode"`
+	ResponseTimeMs     int64  `json:"responseTimeMs"`
+	EntityCount        int    `json:"entityCount"`
+	Success            bool   `json:"success"`
+}
+
+// This struct appends the audit trail of the calls to the NewRelic API as JSON
+// lines to a file. Each line is written with a single write to a file opened
+// in append mode, so that lines are never interleaved, and the mutex makes it
+// safe for concurrent use.
+type AuditLogger struct {
+	mutex sync.Mutex
+	file  *os.File
+}
+
+// This function returns a new AuditLogger appending to the file at the given
+// path, creating the file if it does not exist.
+func NewAuditLogger(path string) (*AuditLogger, error) {
+	file, err := os.OpenFile(path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0600)
+	if err != nil {
+		return nil, err
+	}
+	return &AuditLogger{file: file}, nil
+}
+
+// This function appends a line for a single call to the given endpoint to the
+// audit log. Only the SHA-256 hash of the request body is recorded.
+func (l *AuditLogger) Record(endpoint string, requestBody []byte, statusCode int, responseTime time.Duration, entityCount int, success bool) error {
+	hash := sha256.Sum256(requestBody)
+	line, err := json.Marshal(auditEntry{
+		Timestamp:          time.Now().UTC().Format(time.RFC3339Nano),
+		Endpoint:           endpoint,
+		RequestBodyHash:    hex.EncodeToString(hash[:]),
+		ResponseStatusCode: statusCode,
+		ResponseTimeMs:     responseTime.Milliseconds(),
+		EntityCount:        entityCount,
+		Success:            success,
+	})
+	if err != nil {
+		return err
+	}
+
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+	_, err = l.file.Write(append(line, '\n'))
+	return err
+}
+
+// This function closes the audit log file.
+func (l *AuditLogger) Close() error {
+	return l.file.Close()
+}
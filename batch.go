@@ -0,0 +1,261 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"strings"
+	"sync"
+	"text/tabwriter"
+	"time"
+)
+
+// This struct holds the result of resolving a single app ID in batch mode.
+type batchResult struct {
+	AppID  string
+	Entity Entity
+	Err    error
+}
+
+// This struct holds the outcome of resolving a single app ID in batch mode,
+// as printed in the batchErrors output parameter.
+type BatchStatus struct {
+	AppID   string `json:"appId"`
+	GUID    string `json:"guid,omitempty"`
+	Success bool   `json:"success"`
+	Error   string `json:"error,omitempty"`
+}
+
+// This struct accumulates the metrics of a batch run. It is shared by all
+// goroutines of the batch, so its counters must only be changed through its
+// methods, which hold the mutex.
+type BatchMetrics struct {
+	mutex    sync.Mutex
+	Resolved int
+	NotFound int
+	Errors   int
+	APICalls int
+	Elapsed  time.Duration
+}
+
+// This function records the outcome of resolving a single app ID.
+func (m *BatchMetrics) recordResult(err error) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	switch {
+	case err == nil:
+		m.Resolved++
+	case errors.Is(err, ErrNoEntityFound):
+		m.NotFound++
+	default:
+		m.Errors++
+	}
+}
+
+// This function records a single call to the NewRelic API.
+func (m *BatchMetrics) recordAPICall() {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	m.APICalls++
+}
+
+// This function returns the rows of the batch summary.
+func (m *BatchMetrics) rows() [][2]string {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	return [][2]string{
+		{"Resolved", fmt.Sprint(m.Resolved)},
+		{"Not found", fmt.Sprint(m.NotFound)},
+		{"Errors", fmt.Sprint(m.Errors)},
+		{"API calls", fmt.Sprint(m.APICalls)},
+		{"Elapsed", m.Elapsed.Round(time.Millisecond).String()},
+	}
+}
+
+// This function prints the batch summary as a table to stdout. If the
+// GITHUB_STEP_SUMMARY environment variable is set, the summary is also
+// appended to the step summary as a Markdown table.
+func (m *BatchMetrics) printSummary() error {
+	rows := m.rows()
+
+	// Print the summary to stdout.
+	fmt.Println("Batch summary:")
+	table := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	for _, row := range rows {
+		fmt.Fprintf(table, "  %s\t%s\n", row[0], row[1])
+	}
+	table.Flush()
+
+	// Append the summary to the step summary, if available.
+	stepSummary := os.Getenv("GITHUB_STEP_SUMMARY")
+	if stepSummary == "" {
+		return nil
+	}
+	var markdown strings.Builder
+	markdown.WriteString("### NewRelic GUID fetcher batch summary\n\n")
+	markdown.WriteString("| Metric | Value |\n")
+	markdown.WriteString("|--------|-------|\n")
+	for _, row := range rows {
+		fmt.Fprintf(&markdown, "| %s | %s |\n", row[0], row[1])
+	}
+	return appendToFile(stepSummary, markdown.String())
+}
+
+// This struct wraps a HTTPDoer and records every request it sends as an API
+// call in the batch metrics.
+type metricsClient struct {
+	client  HTTPDoer
+	metrics *BatchMetrics
+}
+
+// This function records the API call and sends the request using the wrapped
+// client.
+func (c metricsClient) Do(req *http.Request) (*http.Response, error) {
+	c.metrics.recordAPICall()
+	return c.client.Do(req)
+}
+
+// This function appends the given content to the file at the given path,
+// creating the file if it does not exist.
+func appendToFile(path string, content string) error {
+	f, err := os.OpenFile(path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+	if err != nil {
+		return err
+	}
+	if _, err := f.WriteString(content); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+// This function splits the comma-separated list of app IDs specified in the
+// newrelicAppID input parameter. Whitespace around the app IDs and empty
+// entries are ignored.
+func splitAppIDs(value string) []string {
+	var appIDs []string
+	for _, appID := range strings.Split(value, ",") {
+		if appID = strings.TrimSpace(appID); appID != "" {
+			appIDs = append(appIDs, appID)
+		}
+	}
+	return appIDs
+}
+
+// This function returns the given IDs without duplicates, keeping the order
+// in which they first appear. A warning is printed for each duplicate ID.
+func deduplicateIDs(ids []string) []string {
+	seen := map[string]bool{}
+	unique := []string{}
+	for _, id := range ids {
+		if seen[id] {
+			fmt.Printf("::warning::App ID %s is specified more than once, it is only fetched once\n", id)
+			continue
+		}
+		seen[id] = true
+		unique = append(unique, id)
+	}
+	return unique
+}
+
+// This function resolves the entities of all given app IDs concurrently. The
+// search of each app ID is narrowed down by the given criteria. Each unique
+// app ID is only fetched once, the results are returned in the order of the
+// given app IDs, including duplicates. If more than one entity matches an app
+// ID, the first one is used. At most concurrency app IDs are resolved at the
+// same time, so that a long list of app IDs does not start a goroutine and
+// open a connection per app ID. The outcome of each app ID, the API calls and
+// the elapsed time are recorded in the given metrics. If failFast is set, the
+// requests of all other app IDs are cancelled as soon as one app ID fails.
+// The log lines of all app IDs are grouped in the workflow log.
+func (c *Client) resolveAllGUIDs(ctx context.Context, cacheEnabled bool, criteria searchCriteria, appIDs []string, metrics *BatchMetrics, failFast bool, concurrency int) []batchResult {
+	defer c.logger.Group(fmt.Sprintf("Resolving %d NewRelic app IDs", len(appIDs)))()
+
+	start := time.Now()
+	uniqueIDs := deduplicateIDs(appIDs)
+	client := *c
+	client.httpClient = metricsClient{client: c.httpClient, metrics: metrics}
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	// Queue the unique app IDs for the workers.
+	if concurrency < 1 {
+		concurrency = 1
+	}
+	queue := make(chan string, len(uniqueIDs))
+	for _, appID := range uniqueIDs {
+		queue <- appID
+	}
+	close(queue)
+
+	// Fetch the entity of each unique app ID in a pool of workers, each of
+	// which resolves one app ID after another until the queue is empty.
+	var mutex sync.Mutex
+	var wg sync.WaitGroup
+	resultsByID := map[string]batchResult{}
+	for i := 0; i < concurrency && i < len(uniqueIDs); i++ {
+		wg.Add(1)
+		go func(criteria searchCriteria) {
+			defer wg.Done()
+
+			for appID := range queue {
+				result := batchResult{AppID: appID}
+				criteria.AppID = appID
+				searchQuery := buildSearchQuery(criteria)
+				graphqlResponse, err := client.getGUIDCached(ctx, cacheEnabled, searchQuery)
+				if err == nil {
+					result.Entity, err = getApplicationEntity(graphqlResponse)
+					if errors.Is(err, ErrMultipleEntitiesFound) {
+						err = nil
+					}
+				}
+				result.Err = err
+				metrics.recordResult(err)
+				if err != nil && failFast {
+					cancel()
+				}
+
+				mutex.Lock()
+				resultsByID[appID] = result
+				mutex.Unlock()
+			}
+		}(criteria)
+	}
+	wg.Wait()
+	metrics.Elapsed = time.Since(start)
+
+	// Fan the results back out to the positions of the given app IDs.
+	results := make([]batchResult, len(appIDs))
+	for i, appID := range appIDs {
+		results[i] = resultsByID[appID]
+	}
+
+	return results
+}
+
+// This function returns the error of the app ID that failed first in a batch
+// run that has been aborted because of fail fast. The app IDs that have been
+// cancelled because of the failure are skipped, unless the run itself has
+// been cancelled.
+func firstBatchError(ctx context.Context, results []batchResult) *batchResult {
+	var cancelled *batchResult
+	for i := range results {
+		result := &results[i]
+		if result.Err == nil {
+			continue
+		}
+		if errors.Is(result.Err, context.Canceled) && ctx.Err() == nil {
+			if cancelled == nil {
+				cancelled = result
+			}
+			continue
+		}
+		return result
+	}
+	return cancelled
+}
@@ -0,0 +1,30 @@
+package main
+
+import (
+	"context"
+	"testing"
+)
+
+func TestResolveAllGUIDsGroupsLogLines(t *testing.T) {
+	server, _ := newFixtureServer(t, [][2]string{
+		{"entitySearch", "testdata/search/entity_search.json"},
+	})
+	logger := &recordingLogger{}
+	client := NewClient(server.Client(), server.URL, "NRAK-TEST", logger)
+
+	results := client.resolveAllGUIDs(context.Background(), false, searchCriteria{}, []string{"1", "2"}, &BatchMetrics{}, false, 2)
+	for _, result := range results {
+		if result.Err != nil {
+			t.Fatalf("resolving app ID %s failed: %v", result.AppID, result.Err)
+		}
+	}
+
+	// The log lines of all app IDs must be logged to the logger of the
+	// client, inside a single group.
+	if len(logger.lines) != 4 {
+		t.Fatalf("resolveAllGUIDs() logged %q, want a group of the two searches", logger.lines)
+	}
+	if first, last := logger.lines[0], logger.lines[len(logger.lines)-1]; first != "::group::Resolving 2 NewRelic app IDs\n" || last != "::endgroup::\n" {
+		t.Errorf("resolveAllGUIDs() logged %q, want the searches inside a group", logger.lines)
+	}
+}
@@ -0,0 +1,18 @@
+//go:build brotli
+
+package main
+
+import (
+	"io"
+
+	"github.com/andybalholm/brotli"
+)
+
+// This function registers the decoder of the Brotli content encoding. It is
+// only compiled in with the brotli build tag, as the standard library does not
+// include a Brotli decoder.
+func init() {
+	contentDecoders["br"] = func(r io.Reader) (io.ReadCloser, error) {
+		return io.NopCloser(brotli.NewReader(r)), nil
+	}
+}
@@ -0,0 +1,129 @@
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// This constant is the maximum time to wait for the lock of the cache file
+// before giving up, so that a stuck job can not deadlock other jobs.
+const cacheLockTimeout = 5 * time.Second
+
+// This struct is a file-based cache of GraphQL responses. It is shared by all
+// jobs running on the same runner, so every access to the cache file is
+// guarded by an exclusive lock on a .lock sentinel file next to it. Get and
+// Put each hold the lock on their own, so a Get followed by a Put is not
+// atomic: two jobs missing the same key both search for it and the last Put
+// wins. This is harmless, as both store a response to the same search.
+type guidCache struct {
+	path string
+}
+
+// This function returns a new cache stored in the given directory.
+func newGUIDCache(dir string) *guidCache {
+	return &guidCache{path: filepath.Join(dir, "newrelic-guid-fetcher-cache.json")}
+}
+
+// This function returns the cache key for the given entity search. The API
+// key is part of the cache key, as different API keys may have access to
+// different accounts, but only its hash is stored.
+func cacheKey(newrelicApiEndpoint string, newrelicApiKey string, searchQuery string) string {
+	hash := sha256.Sum256([]byte(newrelicApiEndpoint + "\n" + newrelicApiKey + "\n" + searchQuery))
+	return hex.EncodeToString(hash[:])
+}
+
+// This function returns the cached GraphQL response for the given key and
+// whether it has been found in the cache.
+func (c *guidCache) Get(key string) (GraphQL, bool, error) {
+	var entries map[string]GraphQL
+	err := c.withLock(func() error {
+		var err error
+		entries, err = c.read()
+		return err
+	})
+	if err != nil {
+		return GraphQL{}, false, err
+	}
+
+	value, ok := entries[key]
+	return value, ok, nil
+}
+
+// This function stores the given GraphQL response for the given key. The
+// cache file is read and written while holding the lock, so that entries
+// written by other jobs in the meantime are not lost.
+func (c *guidCache) Put(key string, value GraphQL) error {
+	return c.withLock(func() error {
+		entries, err := c.read()
+		if err != nil {
+			return err
+		}
+		entries[key] = value
+
+		data, err := json.Marshal(entries)
+		if err != nil {
+			return err
+		}
+
+		// Write to a temporary file first, so that the cache file is never
+		// left half-written.
+		tmp := c.path + ".tmp"
+		if err := os.WriteFile(tmp, data, 0600); err != nil {
+			return err
+		}
+		return os.Rename(tmp, c.path)
+	})
+}
+
+// This function reads all entries of the cache file. A missing cache file is
+// treated as an empty cache. It must only be called while holding the lock.
+func (c *guidCache) read() (map[string]GraphQL, error) {
+	entries := map[string]GraphQL{}
+	data, err := os.ReadFile(c.path)
+	if errors.Is(err, os.ErrNotExist) {
+		return entries, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(data, &entries); err != nil {
+		return nil, fmt.Errorf("reading cache file %s: %w", c.path, err)
+	}
+	return entries, nil
+}
+
+// This function calls fn while holding the exclusive lock of the cache file.
+// The lock is released when fn returns. If the lock can not be acquired
+// within cacheLockTimeout, an error is returned without calling fn.
+func (c *guidCache) withLock(fn func() error) error {
+	lock, err := os.OpenFile(c.path+".lock", os.O_CREATE|os.O_RDWR, 0600)
+	if err != nil {
+		return err
+	}
+	defer lock.Close()
+
+	// Try to acquire the lock until the timeout has been reached.
+	deadline := time.Now().Add(cacheLockTimeout)
+	for {
+		locked, err := tryLockFile(lock)
+		if err != nil {
+			return err
+		}
+		if locked {
+			break
+		}
+		if time.Now().After(deadline) {
+			return fmt.Errorf("timed out waiting for the lock of cache file %s", c.path)
+		}
+		time.Sleep(50 * time.Millisecond)
+	}
+	defer unlockFile(lock)
+
+	return fn()
+}
@@ -0,0 +1,58 @@
+package main
+
+import (
+	"fmt"
+	"testing"
+)
+
+func TestGUIDCacheConcurrentAccess(t *testing.T) {
+	const writers, keysPerWriter = 8, 5
+	cache := newGUIDCache(t.TempDir())
+
+	// Each writer puts its own keys and reads them back while the other
+	// writers access the cache file concurrently.
+	t.Run("writers", func(t *testing.T) {
+		for writer := 0; writer < writers; writer++ {
+			writer := writer
+			t.Run(fmt.Sprintf("writer %d", writer), func(t *testing.T) {
+				t.Parallel()
+				for i := 0; i < keysPerWriter; i++ {
+					key := cacheKey("https://api.newrelic.com/graphql", "NRAK-TEST", fmt.Sprintf("domainId = '%d'", writer*keysPerWriter+i))
+					var value GraphQL
+					value.Data.Actor.EntitySearch.Query = key
+					if err := cache.Put(key, value); err != nil {
+						t.Fatalf("Put() error = %v", err)
+					}
+					got, ok, err := cache.Get(key)
+					if err != nil || !ok {
+						t.Fatalf("Get() = %v, %v, want the entry", ok, err)
+					}
+					if got.Data.Actor.EntitySearch.Query != key {
+						t.Fatalf("Get() query = %q, want %q", got.Data.Actor.EntitySearch.Query, key)
+					}
+				}
+			})
+		}
+	})
+
+	// No entry may have been lost or corrupted by the concurrent writes.
+	entries, err := cache.read()
+	if err != nil {
+		t.Fatalf("read() error = %v", err)
+	}
+	if len(entries) != writers*keysPerWriter {
+		t.Errorf("cache holds %d entries, want %d", len(entries), writers*keysPerWriter)
+	}
+	for key, value := range entries {
+		if value.Data.Actor.EntitySearch.Query != key {
+			t.Errorf("entry %s holds query %q", key, value.Data.Actor.EntitySearch.Query)
+		}
+	}
+}
+
+func TestGUIDCacheMissingFile(t *testing.T) {
+	cache := newGUIDCache(t.TempDir())
+	if _, ok, err := cache.Get("missing"); ok || err != nil {
+		t.Errorf("Get() = %v, %v, want a miss without error", ok, err)
+	}
+}
@@ -0,0 +1,659 @@
+package main
+
+import (
+	"bytes"
+	"compress/gzip"
+	"compress/zlib"
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/zaljic/newrelic-guid-fetcher-action/pkg/newrelicguid"
+)
+
+// These constants are the defaults of the settings of a Client, which are
+// overridden by the input parameters of the same name when the client is
+// created from the config.
+const (
+	// The maximum size of a HTTP response body read from the NewRelic API, so
+	// that a very large response can not exhaust the memory of the runner.
+	defaultMaxResponseBodyBytes int64 = 10 << 20
+
+	// The timeout in milliseconds sent to the NewRelic API with each query as
+	// a hint to abort long-running queries on the server side.
+	defaultQueryTimeoutMs = 10000
+
+	// The complexity score above which a warning is printed before an entity
+	// search query is sent.
+	defaultMaxQueryComplexity = 100
+
+	// The maximum number of entities returned by an entity search, which is
+	// the maximum the NewRelic API returns at once.
+	defaultEntitySearchLimit = 200
+
+	// The criterion the results of an entity search are sorted by, so that the
+	// first entity is the same on every run if more than one entity matches.
+	defaultEntitySearchSortBy = "MOST_RELEVANT"
+
+	// The name of the operation of the queries, which shows up in the
+	// NerdGraph audit log.
+	defaultGraphQLOperationName = "entitySearchByDomainId"
+
+	// The value of the Accept-Encoding header.
+	defaultAcceptEncoding = "gzip, deflate"
+)
+
+// This map holds the criteria the results of an entity search can be sorted
+// by.
+var entitySearchSortCriteria = map[string]bool{
+	"NAME":                       true,
+	"MOST_RELEVANT":              true,
+	"LAST_REPORTING_CHANGE_TIME": true,
+}
+
+// This map holds the decoders of the content encodings the NewRelic API may
+// compress its responses with, keyed by the name of the encoding. Decoders
+// that require additional dependencies are registered by files guarded by
+// build tags.
+var contentDecoders = map[string]func(io.Reader) (io.ReadCloser, error){
+	"gzip": func(r io.Reader) (io.ReadCloser, error) {
+		return gzip.NewReader(r)
+	},
+	"deflate": func(r io.Reader) (io.ReadCloser, error) {
+		return zlib.NewReader(r)
+	},
+}
+
+// This interface describes anything that is able to send a HTTP request and
+// return the HTTP response, such as the net/http client.
+type HTTPDoer interface {
+	Do(req *http.Request) (*http.Response, error)
+}
+
+// This interface describes anything the progress of the action can be logged
+// to. Group starts a group of related log lines, e.g. the pages of a
+// paginated search, and returns the function that ends the group, which must
+// always be called, preferably using defer.
+type Logger interface {
+	Printf(format string, v ...interface{})
+	Group(name string) func()
+}
+
+// This struct is the Logger that prints to stdout, where the runner picks up
+// the log of the action.
+type stdoutLogger struct{}
+
+// This function prints the formatted message to stdout.
+func (stdoutLogger) Printf(format string, v ...interface{}) {
+	fmt.Printf(format, v...)
+}
+
+// This function starts a collapsible group in the workflow log. The returned
+// function ends the group.
+func (stdoutLogger) Group(name string) func() {
+	fmt.Printf("::group::%s\n", name)
+	return func() {
+		fmt.Println("::endgroup::")
+	}
+}
+
+// This struct is used to send entity searches to the NewRelic GraphQL
+// endpoint. It holds the state shared by all searches, so that the HTTP
+// client, endpoint, API key, logger and the settings of the queries do not
+// have to be passed to each call. The settings default to the constants of
+// the same name and are set from the config by newClientFromConfig.
+type Client struct {
+	httpClient HTTPDoer
+	endpoint   string
+	apiKey     string
+	logger     Logger
+
+	// The maximum size of a HTTP response body and the settings sent with
+	// each query.
+	maxResponseBodyBytes int64
+	queryTimeoutMs       int
+	acceptEncoding       string
+
+	// The settings of entity searches. Only entity searches are given the
+	// operation name. The cursor is empty to start at the first page of
+	// results.
+	operationName      string
+	maxQueryComplexity int
+	searchLimit        int
+	searchSortBy       string
+	searchCursor       string
+}
+
+// This variable makes sure that Client implements the Client interface of the
+// newrelicguid package.
+var _ newrelicguid.Client = (*Client)(nil)
+
+// This struct is used for account-level operations that are not bound to a
+// specific app ID, such as listing all entities of an account. It shares the
+// HTTP client, GraphQL types and authentication with the ID-based lookup.
+type AccountClient struct {
+	client *Client
+	logger Logger
+}
+
+// This struct is used to marshal the usage analytics sent to the telemetry
+// endpoint. It must never contain the API key or the GUID of an entity.
+type telemetryPayload struct {
+	ActionVersion string `json:"actionVersion"`
+	GoVersion     string `json:"goVersion"`
+	OS            string `json:"os"`
+	Arch          string `json:"arch"`
+	Region        string `json:"region"`
+	EntityType    string `json:"entityType"`
+	Success       bool   `json:"success"`
+}
+
+// This function returns the HTTP client used to send requests to the NewRelic
+// API. If a CA certificate file or directory is specified, the certificates
+// are trusted in addition to the system CA certificates. Only files ending in
+// .pem or .crt are loaded from the directory.
+func newHTTPClient(caCertFile string, caCertDir string) (*http.Client, error) {
+	// Use the default net/http client settings if no CA certificates have been
+	// specified.
+	if caCertFile == "" && caCertDir == "" {
+		return &http.Client{}, nil
+	}
+
+	// Start with the system CA certificates, if available.
+	certPool, err := x509.SystemCertPool()
+	if err != nil {
+		certPool = x509.NewCertPool()
+	}
+
+	// Collect the files to load the CA certificates from.
+	var certFiles []string
+	if caCertFile != "" {
+		certFiles = append(certFiles, caCertFile)
+	}
+	if caCertDir != "" {
+		entries, err := os.ReadDir(caCertDir)
+		if err != nil {
+			return nil, fmt.Errorf("reading CA certificate directory: %w", err)
+		}
+		dirCertFiles := 0
+		for _, entry := range entries {
+			ext := filepath.Ext(entry.Name())
+			if !entry.IsDir() && (ext == ".pem" || ext == ".crt") {
+				certFiles = append(certFiles, filepath.Join(caCertDir, entry.Name()))
+				dirCertFiles++
+			}
+		}
+		if dirCertFiles == 0 {
+			return nil, fmt.Errorf("no .pem or .crt files found in CA certificate directory %s", caCertDir)
+		}
+	}
+
+	// Add the PEM encoded certificates of each file to the pool.
+	for _, certFile := range certFiles {
+		pem, err := os.ReadFile(certFile)
+		if err != nil {
+			return nil, fmt.Errorf("reading CA certificate: %w", err)
+		}
+		if !certPool.AppendCertsFromPEM(pem) {
+			return nil, fmt.Errorf("no valid PEM encoded certificate found in %s", certFile)
+		}
+	}
+
+	// Use the certificate pool in a copy of the default transport.
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.TLSClientConfig = &tls.Config{RootCAs: certPool}
+
+	return &http.Client{Transport: transport}, nil
+}
+
+// This function sends the given GraphQL query to the NewRelic GraphQL endpoint
+// of the client and unmarshals the HTTP response body into the value pointed
+// to by response.
+func (c *Client) queryNerdGraph(ctx context.Context, query string, response interface{}) error {
+	return c.queryNerdGraphWithVariables(ctx, query, nil, response)
+}
+
+// This function sends the given GraphQL query together with the values of its
+// variables to the NewRelic GraphQL endpoint of the client and unmarshals the
+// HTTP response body into the value pointed to by response. The variables are
+// sent as null if they are nil.
+func (c *Client) queryNerdGraphWithVariables(ctx context.Context, query string, variables map[string]interface{}, response interface{}) error {
+	return c.sendGraphQLRequest(ctx, graphQLRequest{Query: query, Variables: variables, Timeout: c.queryTimeoutMs}, response)
+}
+
+// This function sends the given GraphQL request to the NewRelic GraphQL
+// endpoint of the client and unmarshals the HTTP response body into the value
+// pointed to by response.
+func (c *Client) sendGraphQLRequest(ctx context.Context, request graphQLRequest, response interface{}) (err error) {
+	// Specify data to be sent in the HTTP request body.
+	data, err := json.Marshal(request)
+	if err != nil {
+		return err
+	}
+
+	// Record the call in the audit log once it has finished, if enabled.
+	start := time.Now()
+	statusCode, entityCount := 0, 0
+	if auditLogger != nil {
+		defer func() {
+			success := err == nil
+			if auditErr := auditLogger.Record(c.endpoint, data, statusCode, time.Since(start), entityCount, success); auditErr != nil {
+				fmt.Printf("::warning::Writing the audit log failed: %s\n", auditErr)
+			}
+		}()
+	}
+
+	// Create a HTTP POST request to the NewRelic GraphQL endpoint of the
+	// client.
+	req, err := http.NewRequestWithContext(ctx, "POST", c.endpoint, bytes.NewReader(data))
+	if err != nil {
+		return err
+	}
+
+	// Set the Api-Key header to the API key of the client.
+	req.Header.Set("Api-Key", c.apiKey)
+
+	// Set the Content-Type header to application/json.
+	req.Header.Set("Content-Type", "application/json")
+
+	// Set the Accept-Encoding header to the accepted encodings of the client.
+	// Setting the header disables the transparent decompression of the
+	// net/http client, so the body is decoded by decodeBody instead.
+	if c.acceptEncoding != "" {
+		req.Header.Set("Accept-Encoding", c.acceptEncoding)
+	}
+
+	// Send the HTTP request using the HTTP client of the client.
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return err
+	}
+
+	// Close the HTTP response body.
+	defer resp.Body.Close()
+	statusCode = resp.StatusCode
+
+	// Return an error if the HTTP status code is not 200.
+	switch resp.StatusCode {
+	case 200:
+	case 401, 403:
+		return fmt.Errorf("%w: HTTP status code %d", ErrAuthenticationFailed, resp.StatusCode)
+	case 429:
+		return fmt.Errorf("%w: HTTP status code %d", ErrRateLimitExceeded, resp.StatusCode)
+	default:
+		return errors.New("HTTP status code is not 200")
+	}
+
+	// Decode the HTTP response body according to its content encoding.
+	decodedBody, err := decodeBody(resp)
+	if err != nil {
+		return err
+	}
+	defer decodedBody.Close()
+
+	// Read the HTTP response body up to the maximum size. One more byte than
+	// the maximum is read to detect whether the body has been truncated. The
+	// maximum applies to the decoded body, so that a small compressed body can
+	// not exhaust the memory of the runner either.
+	body, err := io.ReadAll(io.LimitReader(decodedBody, c.maxResponseBodyBytes+1))
+	if err != nil {
+		return err
+	}
+
+	// Return an error if the HTTP response body exceeds the maximum size.
+	if int64(len(body)) > c.maxResponseBodyBytes {
+		return fmt.Errorf("HTTP response body exceeds the maximum size of %d bytes", c.maxResponseBodyBytes)
+	}
+
+	// Return an error if the NewRelic API responded with GraphQL errors. The
+	// number of entities of an entity search is recorded in the audit log.
+	var envelope struct {
+		Errors []struct {
+			Message string `json:"message"`
+		} `json:"errors"`
+		Data struct {
+			Actor struct {
+				EntitySearch struct {
+					Results struct {
+						Entities []json.RawMessage `json:"entities"`
+					} `json:"results"`
+				} `json:"entitySearch"`
+			} `json:"actor"`
+		} `json:"data"`
+	}
+	if err := json.Unmarshal(body, &envelope); err != nil {
+		return fmt.Errorf("%w: %s", ErrUnexpectedResponse, err)
+	}
+	entityCount = len(envelope.Data.Actor.EntitySearch.Results.Entities)
+	if len(envelope.Errors) > 0 {
+		return fmt.Errorf("%w: %s", ErrGraphQLError, envelope.Errors[0].Message)
+	}
+
+	// Unmarshal the HTTP response body into the given response value.
+	return json.Unmarshal(body, response)
+}
+
+// This function sends a HTTP POST request to the endpoint specified in the
+// newrelicApiEndpoint input parameter and returns the GraphQL response
+// returned by the NewRelic API for the given entity search query. It is kept
+// for the callers that do not hold a Client and is a thin wrapper around
+// Client.Search.
+func getGUID(client HTTPDoer, newrelicApiKey string, newrelicApiEndpoint string, searchQuery string) (GraphQL, error) {
+	return NewClient(client, newrelicApiEndpoint, newrelicApiKey, stdoutLogger{}).Search(context.Background(), searchQuery)
+}
+
+// This function returns a new Client which sends its requests to the given
+// NewRelic GraphQL endpoint using the given HTTP client and API key, and logs
+// its progress to the given logger. Its settings are the defaults.
+func NewClient(httpClient HTTPDoer, endpoint string, apiKey string, logger Logger) *Client {
+	return &Client{
+		httpClient:           httpClient,
+		endpoint:             endpoint,
+		apiKey:               apiKey,
+		logger:               logger,
+		maxResponseBodyBytes: defaultMaxResponseBodyBytes,
+		queryTimeoutMs:       defaultQueryTimeoutMs,
+		acceptEncoding:       defaultAcceptEncoding,
+		operationName:        defaultGraphQLOperationName,
+		maxQueryComplexity:   defaultMaxQueryComplexity,
+		searchLimit:          defaultEntitySearchLimit,
+		searchSortBy:         defaultEntitySearchSortBy,
+	}
+}
+
+// This function returns a new Client set up from the given Config, the same
+// way the action sets up its client: the config is validated, the HTTP client
+// trusts the CA certificates of the config and is limited to its number of
+// requests per second, the API key is resolved from its sources, and progress
+// is logged to stdout. If no endpoint is set, the endpoint of the region is
+// used.
+func NewClientFromConfig(cfg Config) (*Client, error) {
+	if err := cfg.Validate(); err != nil {
+		return nil, err
+	}
+	return newClientFromConfig(context.Background(), cfg)
+}
+
+// This function returns a new Client set up from the given Config without
+// validating it. The given context is used to resolve the API key, e.g. from
+// Vault.
+func newClientFromConfig(ctx context.Context, cfg Config) (*Client, error) {
+	// Create the HTTP client, which trusts the CA certificates specified in
+	// the config in addition to the system CA certificates.
+	client, err := newHTTPClient(cfg.CACertFile, cfg.CACertDir)
+	if err != nil {
+		return nil, err
+	}
+
+	// Limit the rate of the requests, falling back to 10 requests per second
+	// if the config does not specify it.
+	requestsPerSecond := cfg.RequestsPerSecond
+	if requestsPerSecond <= 0 {
+		requestsPerSecond = 10
+	}
+	var httpClient HTTPDoer = rateLimitedClient{client: client, limiter: NewRateLimiter(requestsPerSecond)}
+
+	// Retry the requests failing with a transient HTTP status code. Each
+	// retry waits for the rate limiter as well.
+	httpClient = retryingClient{client: httpClient, logger: stdoutLogger{}, attempts: defaultRetryAttempts, delay: defaultRetryDelay}
+
+	// Resolve the API key from the source it has been specified in.
+	apiKey, err := resolveAPIKey(ctx, httpClient, cfg.KeySources)
+	if err != nil {
+		return nil, err
+	}
+
+	// Use the endpoint of the region if no endpoint is set.
+	endpoint := cfg.Endpoint
+	if endpoint == "" {
+		endpoint = resolveEndpoint(cfg.Region)
+	}
+
+	// Apply the settings of the config, keeping the defaults for the settings
+	// it does not specify.
+	newrelicClient := NewClient(httpClient, endpoint, apiKey, stdoutLogger{})
+	if cfg.MaxResponseBodyBytes > 0 {
+		newrelicClient.maxResponseBodyBytes = cfg.MaxResponseBodyBytes
+	}
+	if cfg.QueryTimeoutMs > 0 {
+		newrelicClient.queryTimeoutMs = cfg.QueryTimeoutMs
+	}
+	if cfg.AcceptEncoding != "" {
+		newrelicClient.acceptEncoding = cfg.AcceptEncoding
+	}
+	if cfg.GraphQLOperationName != "" {
+		newrelicClient.operationName = cfg.GraphQLOperationName
+	}
+	if cfg.MaxQueryComplexity > 0 {
+		newrelicClient.maxQueryComplexity = cfg.MaxQueryComplexity
+	}
+	if cfg.EntitySearchLimit > 0 {
+		newrelicClient.searchLimit = cfg.EntitySearchLimit
+	}
+	if cfg.EntitySearchSortBy != "" {
+		newrelicClient.searchSortBy = cfg.EntitySearchSortBy
+	}
+	newrelicClient.searchCursor = cfg.EntitySearchCursor
+
+	return newrelicClient, nil
+}
+
+// This function returns the GraphQL response of the entity search for the
+// given app ID.
+func (c *Client) GetGUID(ctx context.Context, appID string) (GraphQL, error) {
+	return c.Search(ctx, buildSearchQuery(searchCriteria{AppID: appID}))
+}
+
+// This function returns the GraphQL response returned by the NewRelic API for
+// the given entity search query. It is assumed that the GraphQL response
+// contains a list of applications. The fields requested for each entity are
+// controlled by the given options, which default to the account ID, type,
+// name, GUID and language.
+func (c *Client) Search(ctx context.Context, searchQuery string, options ...ProjectionOption) (GraphQL, error) {
+	c.logger.Printf("Searching NewRelic entities: %s\n", searchQuery)
+
+	// Warn about expensive search queries, e.g. wildcard name searches, as
+	// they consume the API quota.
+	if complexity := estimateQueryComplexity(searchQuery); complexity > c.maxQueryComplexity {
+		fmt.Printf("::warning::The estimated complexity %d of the search query exceeds %d, it may consume a large part of the NewRelic API quota\n", complexity, c.maxQueryComplexity)
+	}
+
+	// Send the query using the HTTP client and unmarshal the response into the
+	// GraphQL struct.
+	graphqlResponse, err := c.searchNerdGraph(ctx, c.buildEntitySearchQuery(searchQuery, options...))
+	if err != nil {
+		return GraphQL{}, err
+	}
+
+	// Return the GraphQL response.
+	return graphqlResponse, nil
+}
+
+// This function returns the GraphQL query of the entity search for the given
+// search query. The entity fields are the ones of the projection resulting
+// from the given options. The results are limited to the search limit of the
+// client, sorted by its sort criterion and start at the page of its cursor, if
+// any.
+func (c *Client) buildEntitySearchQuery(searchQuery string, options ...ProjectionOption) string {
+	cursor := "null"
+	if c.searchCursor != "" {
+		cursor = graphqlString(c.searchCursor)
+	}
+	return fmt.Sprintf(`{ actor { entitySearch(query: %s, sortBy: [%s]) { count query results(cursor: %s, limit: %d) { nextCursor entities { %s } } } } }`, graphqlString(searchQuery), c.searchSortBy, cursor, c.searchLimit, newrelicguid.NewProjection(options...).Fields)
+}
+
+// This function sends the given entity search query to the NewRelic GraphQL
+// endpoint of the client and unmarshals the response into the GraphQL struct.
+// The query is given the operation name of the client, so that entity
+// searches can be found in the NerdGraph audit log, while the other queries
+// and the mutations keep their anonymous operations. If the response does not
+// have the expected structure, an error including the raw response body is
+// returned, as the entities would otherwise silently be missing.
+func (c *Client) searchNerdGraph(ctx context.Context, query string) (GraphQL, error) {
+	// Send the named query and keep the raw response body for diagnostics.
+	request := graphQLRequest{Timeout: c.queryTimeoutMs}
+	request.Query, request.OperationName = nameOperation(query, c.operationName)
+	var body json.RawMessage
+	err := c.sendGraphQLRequest(ctx, request, &body)
+	if err != nil {
+		return GraphQL{}, err
+	}
+
+	// Unmarshal the response body and check its structure.
+	var graphqlResponse GraphQL
+	if err := json.Unmarshal(body, &graphqlResponse); err != nil {
+		return GraphQL{}, fmt.Errorf("%w: %s\nResponse body: %s", ErrUnexpectedResponse, err, body)
+	}
+	if err := validateGraphQLEnvelope(graphqlResponse); err != nil {
+		return GraphQL{}, fmt.Errorf("%w\nResponse body: %s", err, body)
+	}
+
+	return graphqlResponse, nil
+}
+
+// This function returns an error if the given response of an entity search
+// does not have the structure the action expects. NewRelic always echoes the
+// search query, so a response without it has not been decoded into the
+// entitySearch field, e.g. because actor or entitySearch have been renamed.
+func validateGraphQLEnvelope(r GraphQL) error {
+	if r.Data.Actor.EntitySearch.Query == "" {
+		return fmt.Errorf("%w: the response has no data.actor.entitySearch.query field, the NerdGraph API schema may have changed", ErrUnexpectedResponse)
+	}
+	return nil
+}
+
+// This function searches for the entities matching the search query unless the
+// GraphQL response for the search query has been cached by a previous run. If caching is enabled, the
+// cache is stored in the temporary directory of the runner and new responses
+// are added to it. Failing to access the cache is not fatal, in which case a
+// warning is printed and the cache is bypassed.
+func (c *Client) getGUIDCached(ctx context.Context, cacheEnabled bool, searchQuery string) (GraphQL, error) {
+	// Search for the entities directly if caching is disabled.
+	if !cacheEnabled {
+		return c.Search(ctx, searchQuery)
+	}
+
+	// Use the temporary directory of the runner. The key is derived from the
+	// GraphQL query sent, so that responses to searches with a different
+	// limit, sort criterion, cursor or projection are cached separately.
+	cache := newGUIDCache(runnerTempDir())
+	key := cacheKey(c.endpoint, c.apiKey, c.buildEntitySearchQuery(searchQuery))
+
+	// Return the cached GraphQL response, if any.
+	graphqlResponse, ok, err := cache.Get(key)
+	if err != nil {
+		fmt.Printf("::warning::Reading the cache failed: %s\n", err)
+	} else if ok {
+		return graphqlResponse, nil
+	}
+
+	// Fetch the GraphQL response and add it to the cache.
+	graphqlResponse, err = c.Search(ctx, searchQuery)
+	if err != nil {
+		return GraphQL{}, err
+	}
+	if err := cache.Put(key, graphqlResponse); err != nil {
+		fmt.Printf("::warning::Writing the cache failed: %s\n", err)
+	}
+
+	return graphqlResponse, nil
+}
+
+// This function returns the body of the given HTTP response decoded according
+// to its Content-Encoding header. A body without content encoding is returned
+// as is.
+func decodeBody(resp *http.Response) (io.ReadCloser, error) {
+	encoding := strings.ToLower(strings.TrimSpace(resp.Header.Get("Content-Encoding")))
+	if encoding == "" || encoding == "identity" {
+		return io.NopCloser(resp.Body), nil
+	}
+
+	// Return an error if no decoder is available for the content encoding.
+	decoder, ok := contentDecoders[encoding]
+	if !ok {
+		return nil, fmt.Errorf("unsupported content encoding %q", encoding)
+	}
+
+	return decoder(resp.Body)
+}
+
+// This function returns a new AccountClient which sends its requests to the
+// given NewRelic GraphQL endpoint using the given HTTP client and API key.
+func NewAccountClient(client HTTPDoer, newrelicApiEndpoint string, newrelicApiKey string) *AccountClient {
+	return &AccountClient{
+		client: NewClient(client, newrelicApiEndpoint, newrelicApiKey, stdoutLogger{}),
+		logger: stdoutLogger{},
+	}
+}
+
+// This function returns all entities of the given entity type the API key has
+// access to. The entity search returns its results in pages, so the function
+// keeps requesting the next page until NewRelic returns no further cursor.
+func (c *AccountClient) ListAllEntities(ctx context.Context, entityType string) ([]Entity, error) {
+	defer c.logger.Group(fmt.Sprintf("Listing NewRelic entities of type %s", entityType))()
+
+	entities := []Entity{}
+	cursor := "null"
+	for page := 1; ; page++ {
+		// Fetch the page of entities starting at the current cursor.
+		query := fmt.Sprintf(`{ actor { entitySearch(query: %s) { count query results(cursor: %s) { nextCursor entities { accountId entityType name guid } } } } }`, graphqlString("type = "+searchValue(entityType)), cursor)
+		graphqlResponse, err := c.client.searchNerdGraph(ctx, query)
+		if err != nil {
+			return nil, err
+		}
+
+		// Collect the entities of the page.
+		results := graphqlResponse.Data.Actor.EntitySearch.Results
+		entities = append(entities, results.Entities...)
+		c.logger.Printf("Page %d: %d entities\n", page, len(results.Entities))
+
+		// Stop if this was the last page.
+		if results.NextCursor == nil || *results.NextCursor == "" {
+			return entities, nil
+		}
+		cursor = graphqlString(*results.NextCursor)
+	}
+}
+
+// This function sends the given usage analytics to the telemetry endpoint in a
+// background goroutine. The returned channel is closed once the goroutine has
+// finished, which takes at most five seconds. Any failure is silently ignored
+// as telemetry must never affect the outcome of the action.
+func sendTelemetry(telemetryEndpoint string, payload telemetryPayload) <-chan struct{} {
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+
+		// Give up on the request after five seconds.
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+
+		data, err := json.Marshal(payload)
+		if err != nil {
+			return
+		}
+
+		req, err := http.NewRequestWithContext(ctx, "POST", telemetryEndpoint, bytes.NewReader(data))
+		if err != nil {
+			return
+		}
+		req.Header.Set("Content-Type", "application/json")
+
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			return
+		}
+		resp.Body.Close()
+	}()
+
+	return done
+}
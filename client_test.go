@@ -0,0 +1,293 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/zaljic/newrelic-guid-fetcher-action/pkg/newrelicguid"
+)
+
+func TestSearchOperationName(t *testing.T) {
+	tests := []struct {
+		name          string
+		operationName string
+		wantPrefix    string
+	}{
+		{name: "default", wantPrefix: "query entitySearchByDomainId { actor { entitySearch("},
+		{name: "custom", operationName: "deployPipeline", wantPrefix: "query deployPipeline { actor { entitySearch("},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server, recorded := newFixtureServer(t, [][2]string{
+				{"entitySearch", "testdata/search/entity_search.json"},
+			})
+
+			client := NewClient(server.Client(), server.URL, "NRAK-TEST", stdoutLogger{})
+			if tt.operationName != "" {
+				client.operationName = tt.operationName
+			}
+			graphqlResponse, err := client.GetGUID(context.Background(), "1")
+			if err != nil {
+				t.Fatalf("GetGUID() error = %v", err)
+			}
+			if got := graphqlResponse.Data.Actor.EntitySearch.Results.Entities[0].GUID; got != "MXxBUE18QVBQTElDQVRJT058MQ" {
+				t.Errorf("GetGUID() GUID = %q, want %q", got, "MXxBUE18QVBQTElDQVRJT058MQ")
+			}
+
+			// The entity search must have been sent as a named query.
+			queries := recorded.all()
+			if len(queries) != 1 || !strings.HasPrefix(queries[0], tt.wantPrefix) {
+				t.Errorf("GetGUID() sent %q, want a query starting with %q", queries, tt.wantPrefix)
+			}
+		})
+	}
+}
+
+func TestBuildEntitySearchQuery(t *testing.T) {
+	tests := []struct {
+		name   string
+		limit  int
+		sortBy string
+		cursor string
+		want   string
+	}{
+		{
+			name: "defaults",
+			want: `{ actor { entitySearch(query: "domainId = '1'", sortBy: [MOST_RELEVANT]) { count query results(cursor: null, limit: 200) { nextCursor entities { accountId entityType name guid deleted reporting lastReportingChangeAt ... on ApmApplicationEntityOutline { language } } } } } }`,
+		},
+		{
+			name:   "limit, sort criterion and cursor",
+			limit:  10,
+			sortBy: "NAME",
+			cursor: "next-page",
+			want:   `{ actor { entitySearch(query: "domainId = '1'", sortBy: [NAME]) { count query results(cursor: "next-page", limit: 10) { nextCursor entities { accountId entityType name guid deleted reporting lastReportingChangeAt ... on ApmApplicationEntityOutline { language } } } } } }`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			client := NewClient(nil, "", "", stdoutLogger{})
+			if tt.limit != 0 {
+				client.searchLimit = tt.limit
+			}
+			if tt.sortBy != "" {
+				client.searchSortBy = tt.sortBy
+			}
+			client.searchCursor = tt.cursor
+			if got := client.buildEntitySearchQuery("domainId = '1'"); got != tt.want {
+				t.Errorf("buildEntitySearchQuery() = %s, want %s", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestGetGUIDCachedKeysBySearchSettings(t *testing.T) {
+	t.Setenv("RUNNER_TEMP", t.TempDir())
+	server, recorded := newFixtureServer(t, [][2]string{
+		{"entitySearch", "testdata/search/entity_search.json"},
+	})
+
+	// Each search differing in a setting that shapes the query must miss the
+	// cache, while repeating a search must hit it.
+	searches := []struct {
+		limit       int
+		cursor      string
+		wantQueries int
+	}{
+		{limit: 200, wantQueries: 1},
+		{limit: 200, wantQueries: 1},
+		{limit: 10, wantQueries: 2},
+		{limit: 10, cursor: "next-page", wantQueries: 3},
+		{limit: 10, cursor: "next-page", wantQueries: 3},
+	}
+	for i, search := range searches {
+		client := NewClient(server.Client(), server.URL, "NRAK-TEST", stdoutLogger{})
+		client.searchLimit = search.limit
+		client.searchCursor = search.cursor
+		if _, err := client.getGUIDCached(context.Background(), true, "domainId = '1'"); err != nil {
+			t.Fatalf("search %d: getGUIDCached() error = %v", i, err)
+		}
+		if got := len(recorded.all()); got != search.wantQueries {
+			t.Errorf("search %d: %d queries sent, want %d", i, got, search.wantQueries)
+		}
+	}
+}
+
+func TestNewClientFromConfig(t *testing.T) {
+	keyFile := filepath.Join(t.TempDir(), "newrelic-api-key")
+	if err := os.WriteFile(keyFile, []byte("NRAK-FROM-FILE\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("TEST_NEWRELIC_API_KEY", "NRAK-FROM-ENV")
+
+	tests := []struct {
+		name         string
+		cfg          Config
+		wantEndpoint string
+		wantAPIKey   string
+		wantClient   Client
+	}{
+		{
+			name:         "defaults",
+			cfg:          Config{AppID: "1", KeySources: apiKeySources{Key: "NRAK-TEST"}},
+			wantEndpoint: "https://api.newrelic.com/graphql",
+			wantAPIKey:   "NRAK-TEST",
+			wantClient:   *NewClient(nil, "", "", nil),
+		},
+		{
+			name:         "region",
+			cfg:          Config{AppID: "1", KeySources: apiKeySources{Key: "NRAK-TEST"}, Region: newrelicguid.RegionEU},
+			wantEndpoint: "https://api.eu.newrelic.com/graphql",
+			wantAPIKey:   "NRAK-TEST",
+			wantClient:   *NewClient(nil, "", "", nil),
+		},
+		{
+			name:         "endpoint and API key file",
+			cfg:          Config{AppID: "1", KeySources: apiKeySources{File: keyFile}, Region: newrelicguid.RegionEU, Endpoint: "https://proxy.example.com/graphql"},
+			wantEndpoint: "https://proxy.example.com/graphql",
+			wantAPIKey:   "NRAK-FROM-FILE",
+			wantClient:   *NewClient(nil, "", "", nil),
+		},
+		{
+			name: "settings and API key environment variable",
+			cfg: Config{
+				AppID:                "1",
+				KeySources:           apiKeySources{Env: "TEST_NEWRELIC_API_KEY"},
+				MaxResponseBodyBytes: 1024,
+				QueryTimeoutMs:       500,
+				AcceptEncoding:       "gzip",
+				GraphQLOperationName: "deployPipeline",
+				MaxQueryComplexity:   10,
+				EntitySearchLimit:    25,
+				EntitySearchSortBy:   "NAME",
+				EntitySearchCursor:   "next-page",
+			},
+			wantEndpoint: "https://api.newrelic.com/graphql",
+			wantAPIKey:   "NRAK-FROM-ENV",
+			wantClient: Client{
+				maxResponseBodyBytes: 1024,
+				queryTimeoutMs:       500,
+				acceptEncoding:       "gzip",
+				operationName:        "deployPipeline",
+				maxQueryComplexity:   10,
+				searchLimit:          25,
+				searchSortBy:         "NAME",
+				searchCursor:         "next-page",
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			client, err := NewClientFromConfig(tt.cfg)
+			if err != nil {
+				t.Fatalf("NewClientFromConfig() error = %v", err)
+			}
+			if client.endpoint != tt.wantEndpoint {
+				t.Errorf("NewClientFromConfig() endpoint = %q, want %q", client.endpoint, tt.wantEndpoint)
+			}
+			if client.apiKey != tt.wantAPIKey {
+				t.Errorf("NewClientFromConfig() API key = %q, want %q", client.apiKey, tt.wantAPIKey)
+			}
+			if retrying, ok := client.httpClient.(retryingClient); !ok {
+				t.Errorf("NewClientFromConfig() HTTP client = %T, want a retryingClient", client.httpClient)
+			} else if _, ok := retrying.client.(rateLimitedClient); !ok {
+				t.Errorf("NewClientFromConfig() retried HTTP client = %T, want a rateLimitedClient", retrying.client)
+			}
+
+			// Compare the settings only.
+			got := *client
+			got.httpClient, got.endpoint, got.apiKey, got.logger = nil, "", "", nil
+			if got != tt.wantClient {
+				t.Errorf("NewClientFromConfig() settings = %+v, want %+v", got, tt.wantClient)
+			}
+		})
+	}
+}
+
+func TestNewClientFromConfigErrors(t *testing.T) {
+	tests := []struct {
+		name    string
+		cfg     Config
+		wantErr string
+	}{
+		{
+			name:    "invalid config",
+			cfg:     Config{KeySources: apiKeySources{Key: "NRAK-TEST"}},
+			wantErr: "NewRelic app ID not specified.",
+		},
+		{
+			name:    "no API key",
+			cfg:     Config{AppID: "1"},
+			wantErr: "NewRelic API key not specified.",
+		},
+		{
+			name:    "more than one API key source",
+			cfg:     Config{AppID: "1", KeySources: apiKeySources{Key: "NRAK-TEST", Env: "TEST_NEWRELIC_API_KEY"}},
+			wantErr: "Only one of newrelicAPIKey",
+		},
+		{
+			name:    "missing CA certificate",
+			cfg:     Config{AppID: "1", KeySources: apiKeySources{Key: "NRAK-TEST"}, CACertFile: filepath.Join(t.TempDir(), "missing.pem")},
+			wantErr: "reading CA certificate",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := NewClientFromConfig(tt.cfg)
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("NewClientFromConfig() error = %v, want it to contain %q", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestNewClientFromConfigSkipsValidation(t *testing.T) {
+	// The internal constructor is used by the action after the inputs have
+	// been validated and by subcommands that need no search criteria.
+	client, err := newClientFromConfig(context.Background(), Config{KeySources: apiKeySources{Key: "NRAK-TEST"}, Region: newrelicguid.RegionGov})
+	if err != nil {
+		t.Fatalf("newClientFromConfig() error = %v", err)
+	}
+	if client.endpoint != "https://gov-api.newrelic.com/graphql" {
+		t.Errorf("newClientFromConfig() endpoint = %q, want the GOV endpoint", client.endpoint)
+	}
+}
+
+func TestGetGUID(t *testing.T) {
+	server, recorded := newFixtureServer(t, [][2]string{
+		{"entitySearch", "testdata/search/entity_search.json"},
+	})
+
+	graphqlResponse, err := getGUID(server.Client(), "NRAK-TEST", server.URL, "domainId = '1'")
+	if err != nil {
+		t.Fatalf("getGUID() error = %v", err)
+	}
+	if got := graphqlResponse.Data.Actor.EntitySearch.Results.Entities[0].GUID; got != "MXxBUE18QVBQTElDQVRJT058MQ" {
+		t.Errorf("getGUID() GUID = %q, want %q", got, "MXxBUE18QVBQTElDQVRJT058MQ")
+	}
+
+	// The search query must have been sent as GraphQL string argument.
+	queries := recorded.all()
+	if len(queries) != 1 || !strings.Contains(queries[0], `entitySearch(query: "domainId = '1'"`) {
+		t.Errorf("getGUID() sent %q, want an entity search for domainId = '1'", queries)
+	}
+}
+
+func TestGetGUIDHTTPError(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, "unavailable", http.StatusServiceUnavailable)
+	}))
+	defer server.Close()
+
+	if _, err := getGUID(server.Client(), "NRAK-TEST", server.URL, "domainId = '1'"); err == nil {
+		t.Error("getGUID() error = nil, want an error for HTTP status code 503")
+	}
+}
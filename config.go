@@ -0,0 +1,631 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"text/template"
+
+	"github.com/zaljic/newrelic-guid-fetcher-action/pkg/newrelicguid"
+)
+
+// This struct holds the input parameters of the action. The input parameters
+// are read from the INPUT_* environment variables, which are set by the runner
+// or by the command-line flags.
+type Config struct {
+	KeySources                apiKeySources
+	Region                    Region
+	Endpoint                  string
+	AppID                     string
+	AppIDs                    []string
+	AccountID                 int
+	ClusterName               string
+	KubernetesNamespace       string
+	WorkloadName              string
+	FullTextSearchTerm        string
+	ParentGUID                string
+	EntityDomain              string
+	EntityType                string
+	AgentLanguage             string
+	AssertEntityType          string
+	MaxEntityAgeHours         int
+	IncludeDeleted            bool
+	MaxErrorRatePercent       float64
+	FetchBrowserSummary       bool
+	MaxJSErrorRatePercent     float64
+	FetchUsageMetrics         bool
+	MaxIngestGBWarning        float64
+	OutputFormat              string
+	OutputFile                string
+	OutputJSONSchemaFile      string
+	ConfigMapName             string
+	ConfigMapNamespace        string
+	AllowMultiple             bool
+	FailFast                  bool
+	BatchConcurrency          int
+	MultiValueDelimiter       string
+	FetchAlertPolicies        bool
+	FetchAlertConditions      bool
+	FetchNotificationChannels bool
+	FetchWorkloads            bool
+	FetchTeam                 bool
+	FetchInfrastructureHosts  bool
+	FetchK8sMetadata          bool
+	FetchContainerImages      bool
+	FetchMigrationStatus      bool
+	AssertContainerImage      string
+	FetchAccountHierarchy     bool
+	FetchDashboards           bool
+	FetchServiceMap           bool
+	FetchCrossAccountDeps     bool
+	FetchFeatureFlags         bool
+	FeatureFlagServiceURL     string
+	FeatureFlagServiceToken   string
+	CrossAccountIDs           []int
+	FetchAppSettings          bool
+	CompareGUID               string
+	FetchDeployments          bool
+	FetchLogsInContext        bool
+	FetchMetricTimeSeries     bool
+	TimeSeriesSince           string
+	TimeSeriesUntil           string
+	DeploymentsSince          string
+	FetchViolations           bool
+	FailOnOpenViolations      bool
+	FetchAnomalies            bool
+	FailOnActiveAnomaly       bool
+	FetchIncidentStatus       bool
+	FetchSyntheticStatus      bool
+	FailOnSyntheticFailure    bool
+	FailOnCriticalAlert       bool
+	RenameEntityTo            string
+	SetTags                   []Tag
+	TagUpsert                 bool
+	PersistGUIDAsAnnotation   bool
+	GitHubToken               string
+	SlackWebhookURL           string
+	SlackMessageTemplate      *template.Template
+	NRQLQuery                 string
+	GenerateNRQLExamples      bool
+	GraphQLQuery              string
+	GraphQLVariables          map[string]interface{}
+	FetchSLOs                 bool
+	MinSLOAttainmentPercent   float64
+	FetchSLOHistory           bool
+	SLOPeriodDays             int
+	DecodeGUID                bool
+	FetchAgentVersion         bool
+	MinAgentVersion           string
+	PermalinkType             newrelicguid.PageType
+	PermalinkTemplate         *template.Template
+	CacheEnabled              bool
+	MaxResponseBodyBytes      int64
+	QueryTimeoutMs            int
+	RequestsPerSecond         int
+	MaxQueryComplexity        int
+	EntitySearchLimit         int
+	EntitySearchSortBy        string
+	EntitySearchCursor        string
+	GraphQLOperationName      string
+	AcceptEncoding            string
+	AuditLogFile              string
+	CACertFile                string
+	CACertDir                 string
+	TelemetryEnabled          bool
+	TelemetryEndpoint         string
+}
+
+// This function reads the input parameters from the environment variables and
+// parses them into a Config. Optional numeric input parameters that are not
+// set are -1, or 0 for the account ID. If input parameters are invalid, the
+// config is returned together with the ValidationErrors, so that the telemetry
+// settings are available to report the failure.
+func NewConfig() (Config, error) {
+	// Get the input parameters from the environment variables.
+	config := Config{
+		KeySources: apiKeySources{
+			Key:          os.Getenv("INPUT_NEWRELICAPIKEY"),
+			File:         os.Getenv("INPUT_NEWRELICAPIKEY_FILE"),
+			Env:          os.Getenv("INPUT_NEWRELICAPIKEY_ENV"),
+			VaultPath:    os.Getenv("INPUT_NEWRELICAPIKEY_VAULT_PATH"),
+			VaultAddr:    os.Getenv("INPUT_VAULT_ADDR"),
+			VaultToken:   os.Getenv("INPUT_VAULT_TOKEN"),
+			SSMParameter: os.Getenv("INPUT_NEWRELICAPIKEY_SSM_PARAMETER"),
+			GCPSecret:    os.Getenv("INPUT_NEWRELICAPIKEY_GCP_SECRET"),
+		},
+		AppID:                     os.Getenv("INPUT_NEWRELICAPPID"),
+		ClusterName:               os.Getenv("INPUT_CLUSTER_NAME"),
+		KubernetesNamespace:       os.Getenv("INPUT_KUBERNETES_NAMESPACE"),
+		WorkloadName:              os.Getenv("INPUT_WORKLOAD_NAME"),
+		FullTextSearchTerm:        os.Getenv("INPUT_FULLTEXT_SEARCH_TERM"),
+		ParentGUID:                os.Getenv("INPUT_PARENT_GUID"),
+		AgentLanguage:             os.Getenv("INPUT_AGENT_LANGUAGE"),
+		AssertEntityType:          os.Getenv("INPUT_ASSERT_ENTITY_TYPE"),
+		IncludeDeleted:            os.Getenv("INPUT_INCLUDE_DELETED") == "true",
+		MaxErrorRatePercent:       -1,
+		FetchBrowserSummary:       os.Getenv("INPUT_FETCH_BROWSER_SUMMARY") == "true",
+		MaxJSErrorRatePercent:     -1,
+		FetchUsageMetrics:         os.Getenv("INPUT_FETCH_USAGE_METRICS") == "true",
+		MaxIngestGBWarning:        -1,
+		OutputFormat:              os.Getenv("INPUT_OUTPUT_FORMAT"),
+		OutputFile:                os.Getenv("INPUT_OUTPUT_FILE"),
+		OutputJSONSchemaFile:      os.Getenv("INPUT_OUTPUT_JSON_SCHEMA_FILE"),
+		ConfigMapName:             os.Getenv("INPUT_K8S_CONFIGMAP_NAME"),
+		ConfigMapNamespace:        os.Getenv("INPUT_K8S_NAMESPACE"),
+		AllowMultiple:             os.Getenv("INPUT_ALLOW_MULTIPLE") == "true",
+		FailFast:                  os.Getenv("INPUT_FAIL_FAST") == "true",
+		MultiValueDelimiter:       os.Getenv("INPUT_MULTI_VALUE_DELIMITER"),
+		FetchAlertPolicies:        os.Getenv("INPUT_FETCH_ALERT_POLICIES") == "true",
+		FetchAlertConditions:      os.Getenv("INPUT_FETCH_ALERT_CONDITIONS") == "true",
+		FetchNotificationChannels: os.Getenv("INPUT_FETCH_NOTIFICATION_CHANNELS") == "true",
+		FetchWorkloads:            os.Getenv("INPUT_FETCH_WORKLOADS") == "true",
+		FetchTeam:                 os.Getenv("INPUT_FETCH_TEAM") == "true",
+		FetchInfrastructureHosts:  os.Getenv("INPUT_FETCH_INFRASTRUCTURE_HOSTS") == "true",
+		FetchK8sMetadata:          os.Getenv("INPUT_FETCH_K8S_METADATA") == "true",
+		FetchContainerImages:      os.Getenv("INPUT_FETCH_CONTAINER_IMAGES") == "true",
+		FetchMigrationStatus:      os.Getenv("INPUT_FETCH_MIGRATION_STATUS") == "true",
+		AssertContainerImage:      os.Getenv("INPUT_ASSERT_CONTAINER_IMAGE"),
+		FetchAccountHierarchy:     os.Getenv("INPUT_FETCH_ACCOUNT_HIERARCHY") == "true",
+		FetchDashboards:           os.Getenv("INPUT_FETCH_DASHBOARDS") == "true",
+		FetchServiceMap:           os.Getenv("INPUT_FETCH_SERVICE_MAP") == "true",
+		FetchCrossAccountDeps:     os.Getenv("INPUT_FETCH_CROSS_ACCOUNT_DEPS") == "true",
+		FetchFeatureFlags:         os.Getenv("INPUT_FETCH_FEATURE_FLAGS") == "true",
+		FeatureFlagServiceURL:     os.Getenv("INPUT_FEATURE_FLAG_SERVICE_URL"),
+		FeatureFlagServiceToken:   os.Getenv("INPUT_FEATURE_FLAG_SERVICE_TOKEN"),
+		FetchAppSettings:          os.Getenv("INPUT_FETCH_APP_SETTINGS") == "true",
+		CompareGUID:               os.Getenv("INPUT_COMPARE_GUID"),
+		FetchDeployments:          os.Getenv("INPUT_FETCH_DEPLOYMENTS") == "true",
+		FetchLogsInContext:        os.Getenv("INPUT_FETCH_LOGS_IN_CONTEXT") == "true",
+		FetchMetricTimeSeries:     os.Getenv("INPUT_FETCH_METRIC_TIMESERIES") == "true",
+		TimeSeriesSince:           os.Getenv("INPUT_TIMESERIES_SINCE"),
+		TimeSeriesUntil:           os.Getenv("INPUT_TIMESERIES_UNTIL"),
+		DeploymentsSince:          os.Getenv("INPUT_DEPLOYMENTS_SINCE"),
+		FetchViolations:           os.Getenv("INPUT_FETCH_VIOLATIONS") == "true",
+		FailOnOpenViolations:      os.Getenv("INPUT_FAIL_ON_OPEN_VIOLATIONS") == "true",
+		FetchAnomalies:            os.Getenv("INPUT_FETCH_ANOMALIES") == "true",
+		FailOnActiveAnomaly:       os.Getenv("INPUT_FAIL_ON_ACTIVE_ANOMALY") == "true",
+		FetchIncidentStatus:       os.Getenv("INPUT_FETCH_INCIDENT_STATUS") == "true",
+		FetchSyntheticStatus:      os.Getenv("INPUT_FETCH_SYNTHETIC_STATUS") == "true",
+		FailOnSyntheticFailure:    os.Getenv("INPUT_FAIL_ON_SYNTHETIC_FAILURE") == "true",
+		FailOnCriticalAlert:       os.Getenv("INPUT_FAIL_ON_CRITICAL_ALERT") == "true",
+		RenameEntityTo:            os.Getenv("INPUT_RENAME_ENTITY_TO"),
+		TagUpsert:                 os.Getenv("INPUT_TAG_UPSERT") == "true",
+		PersistGUIDAsAnnotation:   os.Getenv("INPUT_PERSIST_GUID_AS_ANNOTATION") == "true",
+		GitHubToken:               os.Getenv("INPUT_GITHUB_TOKEN"),
+		SlackWebhookURL:           os.Getenv("INPUT_SLACK_WEBHOOK_URL"),
+		NRQLQuery:                 os.Getenv("INPUT_NRQL_QUERY"),
+		GenerateNRQLExamples:      os.Getenv("INPUT_GENERATE_NRQL_EXAMPLES") == "true",
+		GraphQLQuery:              os.Getenv("INPUT_GRAPHQL_QUERY"),
+		FetchSLOs:                 os.Getenv("INPUT_FETCH_SLOS") == "true",
+		MinSLOAttainmentPercent:   -1,
+		FetchSLOHistory:           os.Getenv("INPUT_FETCH_SLO_HISTORY") == "true",
+		SLOPeriodDays:             7,
+		DecodeGUID:                os.Getenv("INPUT_DECODE_GUID") == "true",
+		FetchAgentVersion:         os.Getenv("INPUT_FETCH_AGENT_VERSION") == "true",
+		MinAgentVersion:           strings.TrimPrefix(os.Getenv("INPUT_MIN_AGENT_VERSION"), "v"),
+		CacheEnabled:              os.Getenv("INPUT_CACHE") == "true",
+		MaxResponseBodyBytes:      defaultMaxResponseBodyBytes,
+		QueryTimeoutMs:            defaultQueryTimeoutMs,
+		RequestsPerSecond:         10,
+		BatchConcurrency:          5,
+		MaxQueryComplexity:        defaultMaxQueryComplexity,
+		EntitySearchLimit:         defaultEntitySearchLimit,
+		EntitySearchSortBy:        defaultEntitySearchSortBy,
+		EntitySearchCursor:        os.Getenv("INPUT_ENTITY_SEARCH_CURSOR"),
+		GraphQLOperationName:      defaultGraphQLOperationName,
+		AcceptEncoding:            defaultAcceptEncoding,
+		AuditLogFile:              os.Getenv("INPUT_AUDIT_LOG_FILE"),
+		CACertFile:                os.Getenv("INPUT_CA_CERT_FILE"),
+		CACertDir:                 os.Getenv("INPUT_CA_CERT_DIR"),
+		TelemetryEnabled:          os.Getenv("INPUT_TELEMETRY_ENABLED") == "true",
+		TelemetryEndpoint:         os.Getenv("INPUT_TELEMETRY_ENDPOINT"),
+	}
+	// Fall back to the environment variables of the Vault CLI if the Vault
+	// address or token have not been specified.
+	if config.KeySources.VaultAddr == "" {
+		config.KeySources.VaultAddr = os.Getenv("VAULT_ADDR")
+	}
+	if config.KeySources.VaultToken == "" {
+		config.KeySources.VaultToken = os.Getenv("VAULT_TOKEN")
+	}
+
+	// Use the default delimiter, ConfigMap name and deployment time range if
+	// none have been specified.
+	if config.MultiValueDelimiter == "" {
+		config.MultiValueDelimiter = ","
+	}
+	if config.ConfigMapName == "" {
+		config.ConfigMapName = "newrelic-entity"
+	}
+	if config.DeploymentsSince == "" {
+		config.DeploymentsSince = "7 days ago"
+	}
+	if config.TimeSeriesSince == "" {
+		config.TimeSeriesSince = "30 MINUTES AGO"
+	}
+	if config.TimeSeriesUntil == "" {
+		config.TimeSeriesUntil = "NOW"
+	}
+
+	// Parse and validate the input parameters. The errors of all invalid input
+	// parameters are returned at once.
+	err := applyValidators(&config, inputValidators)
+	return config, err
+}
+
+// This function prints a warning for each input parameter whose value is a
+// GitHub Actions expression, e.g. ${{ secrets.NR_APP_ID }}. The runner expands
+// expressions in the with: block, so an unexpanded expression points to a
+// syntax error in the workflow rather than an invalid value.
+func warnUnexpandedExpressions() {
+	var names []string
+	for _, variable := range os.Environ() {
+		name, value, _ := strings.Cut(variable, "=")
+		value = strings.TrimSpace(value)
+		if strings.HasPrefix(name, "INPUT_") && strings.HasPrefix(value, "${{") && strings.HasSuffix(value, "}}") {
+			names = append(names, strings.ToLower(strings.TrimPrefix(name, "INPUT_")))
+		}
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		fmt.Printf("::warning::The %s input parameter contains an unexpanded expression, please check the expression syntax in the with: block of the workflow\n", name)
+	}
+}
+
+// This function checks that the input parameters required to fetch a GUID
+// have been specified and can be combined with each other. It is not called
+// for the self-test, which only requires the API key and region. The errors of
+// all invalid combinations are returned at once.
+func (c Config) Validate() error {
+	return applyValidators(&c, searchValidators)
+}
+
+// This function returns the criteria the entity search of the given app ID is
+// narrowed down by.
+func (c Config) criteria(appID string) searchCriteria {
+	return searchCriteria{
+		AppID:               appID,
+		ClusterName:         c.ClusterName,
+		KubernetesNamespace: c.KubernetesNamespace,
+		Domain:              c.EntityDomain,
+		Type:                c.EntityType,
+		Language:            c.AgentLanguage,
+		WorkloadName:        c.WorkloadName,
+		FullTextSearchTerm:  c.FullTextSearchTerm,
+		AccountID:           c.AccountID,
+		IncludeDeleted:      c.IncludeDeleted,
+	}
+}
+
+// This struct holds the sources the NewRelic API key can be specified in.
+// Exactly one of Key, File, Env, VaultPath, SSMParameter and GCPSecret must be
+// set. VaultAddr and VaultToken are only used together with VaultPath.
+type apiKeySources struct {
+	Key          string
+	File         string
+	Env          string
+	VaultPath    string
+	VaultAddr    string
+	VaultToken   string
+	SSMParameter string
+	GCPSecret    string
+}
+
+// This struct describes a command-line flag of the action binary. Flags with
+// an environment variable set the input parameter of that name, so that the
+// binary can be used as a standalone CLI tool. Flags without an environment
+// variable are boolean flags that change what the binary does.
+type cliFlag struct {
+	Env   string
+	Usage string
+}
+
+// This map holds the command-line flags of the action binary, keyed by flag
+// name. The completion scripts are generated from it.
+var cliFlags = map[string]cliFlag{
+	"newrelic-api-key":               {Env: "INPUT_NEWRELICAPIKEY", Usage: "NewRelic API key"},
+	"newrelic-api-key-file":          {Env: "INPUT_NEWRELICAPIKEY_FILE", Usage: "File containing the NewRelic API key"},
+	"newrelic-api-key-env":           {Env: "INPUT_NEWRELICAPIKEY_ENV", Usage: "Environment variable containing the NewRelic API key"},
+	"newrelic-api-key-vault-path":    {Env: "INPUT_NEWRELICAPIKEY_VAULT_PATH", Usage: "Vault secret containing the NewRelic API key"},
+	"newrelic-api-key-ssm-parameter": {Env: "INPUT_NEWRELICAPIKEY_SSM_PARAMETER", Usage: "AWS SSM parameter containing the NewRelic API key"},
+	"newrelic-api-key-gcp-secret":    {Env: "INPUT_NEWRELICAPIKEY_GCP_SECRET", Usage: "GCP Secret Manager secret version containing the NewRelic API key"},
+	"region":                         {Env: "INPUT_NEWRELICREGION", Usage: "Region the NewRelic account is running in"},
+	"app-id":                         {Env: "INPUT_NEWRELICAPPID", Usage: "NewRelic app ID to fetch the GUID for"},
+	"account-id":                     {Env: "INPUT_NEWRELICACCOUNTID", Usage: "NewRelic account ID the app must be reported in"},
+	"entity-synthesis-rule-type":     {Env: "INPUT_ENTITY_SYNTHESIS_RULE_TYPE", Usage: "Custom entity type to narrow the entity search down to"},
+	"assert-entity-type":             {Env: "INPUT_ASSERT_ENTITY_TYPE", Usage: "Entity type the resolved entity must have"},
+	"max-entity-age-hours":           {Env: "INPUT_MAX_ENTITY_AGE_HOURS", Usage: "Maximum number of hours since the entity stopped reporting"},
+	"include-deleted":                {Env: "INPUT_INCLUDE_DELETED", Usage: "Include deleted entities in the search results"},
+	"cluster-name":                   {Env: "INPUT_CLUSTER_NAME", Usage: "Kubernetes cluster to fetch the GUID for"},
+	"kubernetes-namespace":           {Env: "INPUT_KUBERNETES_NAMESPACE", Usage: "Kubernetes namespace to fetch the GUID for"},
+	"max-error-rate":                 {Env: "INPUT_MAX_ERROR_RATE_PERCENT", Usage: "Maximum error rate in percent the app may have"},
+	"output-format":                  {Env: "INPUT_OUTPUT_FORMAT", Usage: "Additional output format to write the app entity in"},
+	"output-file":                    {Env: "INPUT_OUTPUT_FILE", Usage: "File the additional output format is written to"},
+	"ca-cert-file":                   {Env: "INPUT_CA_CERT_FILE", Usage: "CA certificate to trust"},
+	"ca-cert-dir":                    {Env: "INPUT_CA_CERT_DIR", Usage: "Directory of CA certificates to trust"},
+	"completion-bash":                {Usage: "Print the bash completion script"},
+	"completion-zsh":                 {Usage: "Print the zsh completion script"},
+	"completion-fish":                {Usage: "Print the fish completion script"},
+	"self-test":                      {Usage: "Check the credentials and the connection to the NewRelic API, then exit"},
+}
+
+// This map holds the subcommands of the action binary and what they do, keyed
+// by name. Each subcommand runs a single step of the action, so that the steps
+// can be used on their own, e.g. in the composite action. Without a
+// subcommand, all steps are run.
+var subcommands = map[string]string{
+	"validate":    "Check the input parameters without calling the NewRelic API",
+	"resolve":     "Fetch the GUID of the app and set its output parameters",
+	"tag":         "Fetch the GUID of the app and add the tags specified in set_tags to it",
+	"healthcheck": "Fetch the GUID of the app and check its error rate against max_error_rate_percent",
+}
+
+// This struct holds the command-line flags and the subcommand that change
+// what the binary does.
+type cliOptions struct {
+	SelfTest bool
+	Command  string
+}
+
+// This function parses the subcommand and the command-line flags following it.
+// Each flag that is set overrides the environment variable of its input
+// parameter. If one of the completion flags is set, the completion script is
+// printed and the binary exits.
+func parseFlags() cliOptions {
+	// Register all flags.
+	values := map[string]*string{}
+	switches := map[string]*bool{}
+	for name, definition := range cliFlags {
+		if definition.Env != "" {
+			values[name] = flag.String(name, "", definition.Usage)
+		} else {
+			switches[name] = flag.Bool(name, false, definition.Usage)
+		}
+	}
+
+	// Take the subcommand from the first argument, if it is one, and parse
+	// the flags following it.
+	command, arguments := "", os.Args[1:]
+	if len(arguments) > 0 {
+		if _, ok := subcommands[arguments[0]]; ok {
+			command, arguments = arguments[0], arguments[1:]
+		}
+	}
+	flag.CommandLine.Parse(arguments)
+
+	// Exit if an argument is neither a subcommand nor a flag.
+	if flag.NArg() > 0 {
+		fmt.Printf("Unknown subcommand %q, expected one of %s.\n", flag.Arg(0), strings.Join(sortedSubcommands(), ", "))
+		os.Exit(2)
+	}
+
+	// Print the completion script if requested.
+	program := filepath.Base(os.Args[0])
+	for shell, generate := range map[string]func(string) string{
+		"completion-bash": bashCompletion,
+		"completion-zsh":  zshCompletion,
+		"completion-fish": fishCompletion,
+	} {
+		if *switches[shell] {
+			fmt.Print(generate(program))
+			os.Exit(0)
+		}
+	}
+
+	// Override the environment variables of the flags that have been set.
+	flag.Visit(func(f *flag.Flag) {
+		if definition := cliFlags[f.Name]; definition.Env != "" {
+			os.Setenv(definition.Env, *values[f.Name])
+		}
+	})
+
+	return cliOptions{SelfTest: *switches["self-test"], Command: command}
+}
+
+// This function returns the names of all subcommands in alphabetical order.
+func sortedSubcommands() []string {
+	names := make([]string, 0, len(subcommands))
+	for name := range subcommands {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// This function returns the names of all command-line flags in alphabetical
+// order, so that the completion scripts are always generated the same way.
+func sortedFlagNames() []string {
+	names := make([]string, 0, len(cliFlags))
+	for name := range cliFlags {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// This function generates the bash completion script for the given program.
+func bashCompletion(program string) string {
+	words := sortedSubcommands()
+	for _, name := range sortedFlagNames() {
+		words = append(words, "--"+name)
+	}
+
+	function := "_" + strings.NewReplacer("-", "_", ".", "_").Replace(program)
+	var script strings.Builder
+	fmt.Fprintf(&script, "%s() {\n", function)
+	script.WriteString("    local cur=\"${COMP_WORDS[COMP_CWORD]}\"\n")
+	fmt.Fprintf(&script, "    COMPREPLY=( $(compgen -W \"%s\" -- \"$cur\") )\n", strings.Join(words, " "))
+	script.WriteString("}\n")
+	fmt.Fprintf(&script, "complete -F %s %s\n", function, program)
+	return script.String()
+}
+
+// This function generates the zsh completion script for the given program.
+func zshCompletion(program string) string {
+	var script strings.Builder
+	fmt.Fprintf(&script, "#compdef %s\n\n", program)
+	script.WriteString("_arguments")
+	for _, name := range sortedFlagNames() {
+		definition := cliFlags[name]
+		usage := strings.NewReplacer("'", "", "[", "(", "]", ")").Replace(definition.Usage)
+		if definition.Env != "" {
+			fmt.Fprintf(&script, " \\\n  '--%s[%s]:value:'", name, usage)
+		} else {
+			fmt.Fprintf(&script, " \\\n  '--%s[%s]'", name, usage)
+		}
+	}
+	script.WriteString("\n")
+	return script.String()
+}
+
+// This function generates the fish completion script for the given program.
+func fishCompletion(program string) string {
+	var script strings.Builder
+	for _, name := range sortedFlagNames() {
+		definition := cliFlags[name]
+		usage := strings.ReplaceAll(definition.Usage, "'", "\\'")
+		fmt.Fprintf(&script, "complete -c %s -l %s -d '%s'", program, name, usage)
+		if definition.Env != "" {
+			script.WriteString(" -r")
+		}
+		script.WriteString("\n")
+	}
+	return script.String()
+}
+
+// This function returns the NewRelic API key from exactly one of the given
+// sources: the key itself, a file containing the key, the name of an
+// environment variable containing the key, a HashiCorp Vault secret, an AWS
+// SSM parameter or a GCP Secret Manager secret containing the key. A key
+// fetched from Vault, SSM or GCP is masked in the logs.
+func resolveAPIKey(ctx context.Context, client HTTPDoer, sources apiKeySources) (string, error) {
+	// Count the number of sources the API key has been specified in.
+	count := 0
+	for _, source := range []string{sources.Key, sources.File, sources.Env, sources.VaultPath, sources.SSMParameter, sources.GCPSecret} {
+		if source != "" {
+			count++
+		}
+	}
+
+	// Return an error unless exactly one source has been specified.
+	if count == 0 {
+		return "", errors.New("NewRelic API key not specified.")
+	}
+	if count > 1 {
+		return "", errors.New("Only one of newrelicAPIKey, newrelicAPIKey_file, newrelicAPIKey_env, newrelicAPIKey_vault_path, newrelicAPIKey_ssm_parameter and newrelicAPIKey_gcp_secret may be specified.")
+	}
+	newrelicApiKey := sources.Key
+
+	// Read the API key from the file.
+	if sources.File != "" {
+		data, err := os.ReadFile(sources.File)
+		if err != nil {
+			return "", fmt.Errorf("reading NewRelic API key file: %w", err)
+		}
+		newrelicApiKey = strings.TrimSpace(string(data))
+	}
+
+	// Read the API key from the environment variable.
+	if sources.Env != "" {
+		newrelicApiKey = os.Getenv(sources.Env)
+	}
+
+	// Fetch the API key from Vault.
+	if sources.VaultPath != "" {
+		if sources.VaultAddr == "" || sources.VaultToken == "" {
+			return "", errors.New("Vault address and token must be specified to read the NewRelic API key from Vault.")
+		}
+		value, err := fetchVaultSecret(ctx, client, sources.VaultAddr, sources.VaultToken, sources.VaultPath)
+		if err != nil {
+			return "", err
+		}
+		fmt.Printf("::add-mask::%s\n", value)
+		newrelicApiKey = value
+	}
+
+	// Fetch the API key from the AWS SSM Parameter Store.
+	if sources.SSMParameter != "" {
+		value, err := fetchSSMParameter(ctx, sources.SSMParameter)
+		if err != nil {
+			return "", err
+		}
+		fmt.Printf("::add-mask::%s\n", value)
+		newrelicApiKey = value
+	}
+
+	// Fetch the API key from the GCP Secret Manager.
+	if sources.GCPSecret != "" {
+		value, err := fetchGCPSecret(ctx, client, sources.GCPSecret)
+		if err != nil {
+			return "", err
+		}
+		fmt.Printf("::add-mask::%s\n", value)
+		newrelicApiKey = value
+	}
+
+	// Return an error if the file or environment variable was empty.
+	if newrelicApiKey == "" {
+		return "", errors.New("NewRelic API key is empty.")
+	}
+
+	// Return an error if the key read from the file, environment variable,
+	// Vault secret, SSM parameter or GCP secret is not a user key. A key
+	// specified directly has already been checked by NewConfig.
+	if sources.Key == "" {
+		if _, err := ValidateAPIKey(newrelicApiKey); err != nil {
+			return "", err
+		}
+	}
+
+	return newrelicApiKey, nil
+}
+
+// This type describes the type of a NewRelic API key.
+type KeyType string
+
+// These constants are the NewRelic API key types that can be told apart by
+// their format.
+const (
+	KeyTypeUser           KeyType = "user"
+	KeyTypeLicense        KeyType = "license"
+	KeyTypeBrowser        KeyType = "browser"
+	KeyTypeInsightsInsert KeyType = "insights-insert"
+	KeyTypeUnknown        KeyType = "unknown"
+)
+
+// This function detects the type of the given NewRelic API key from its
+// format. User keys start with NRAK-, browser keys with NRJS- and Insights
+// insert keys with NRII-. License keys are 40 characters long and end with
+// NRAL, older license keys are 40 hexadecimal characters. An error is
+// returned for every detected type other than a user key. Keys of an unknown
+// format are accepted, so that new key formats do not break the action.
+func ValidateAPIKey(key string) (KeyType, error) {
+	keyType := KeyTypeUnknown
+	switch {
+	case strings.HasPrefix(key, "NRAK-"):
+		return KeyTypeUser, nil
+	case strings.HasPrefix(key, "NRJS-"):
+		keyType = KeyTypeBrowser
+	case strings.HasPrefix(key, "NRII-"):
+		keyType = KeyTypeInsightsInsert
+	case len(key) == 40 && strings.HasSuffix(key, "NRAL"):
+		keyType = KeyTypeLicense
+	case len(key) == 40 && strings.Trim(strings.ToLower(key), "0123456789abcdef") == "":
+		keyType = KeyTypeLicense
+	default:
+		return KeyTypeUnknown, nil
+	}
+
+	return keyType, fmt.Errorf("%w: a %s key has been specified, please create a user key (NRAK-...) in the API keys UI of NewRelic", ErrWrongAPIKeyType, keyType)
+}
+
+// This function returns the NewRelic GraphQL endpoint of the given region.
+// The New Relic GraphQL endpoint is different for each region.
+func resolveEndpoint(newrelicRegion Region) string {
+	return newrelicRegion.APIEndpoint()
+}
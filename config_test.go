@@ -0,0 +1,189 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/zaljic/newrelic-guid-fetcher-action/pkg/newrelicguid"
+)
+
+func TestResolveAPIKey(t *testing.T) {
+	keyFile := filepath.Join(t.TempDir(), "newrelic-api-key")
+	if err := os.WriteFile(keyFile, []byte("NRAK-FROM-FILE\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("TEST_NEWRELIC_API_KEY", "NRAK-FROM-ENV")
+	t.Setenv("TEST_EMPTY_NEWRELIC_API_KEY", "")
+
+	tests := []struct {
+		name    string
+		sources apiKeySources
+		want    string
+		wantErr string
+	}{
+		{name: "key", sources: apiKeySources{Key: "NRAK-TEST"}, want: "NRAK-TEST"},
+		{name: "file", sources: apiKeySources{File: keyFile}, want: "NRAK-FROM-FILE"},
+		{name: "environment variable", sources: apiKeySources{Env: "TEST_NEWRELIC_API_KEY"}, want: "NRAK-FROM-ENV"},
+		{name: "no source", wantErr: "NewRelic API key not specified."},
+		{name: "more than one source", sources: apiKeySources{Key: "NRAK-TEST", Env: "TEST_NEWRELIC_API_KEY"}, wantErr: "Only one of newrelicAPIKey"},
+		{name: "empty environment variable", sources: apiKeySources{Env: "TEST_EMPTY_NEWRELIC_API_KEY"}, wantErr: "NewRelic API key is empty."},
+		{name: "missing file", sources: apiKeySources{File: keyFile + ".missing"}, wantErr: "reading NewRelic API key file"},
+		{name: "Vault without address", sources: apiKeySources{VaultPath: "secret/data/newrelic"}, wantErr: "Vault address and token must be specified"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := resolveAPIKey(context.Background(), nil, tt.sources)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Errorf("resolveAPIKey() error = %v, want it to contain %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil || got != tt.want {
+				t.Errorf("resolveAPIKey() = %q, %v, want %q", got, err, tt.want)
+			}
+		})
+	}
+}
+
+func TestResolveEndpoint(t *testing.T) {
+	tests := []struct {
+		region Region
+		want   string
+	}{
+		{region: newrelicguid.RegionUS, want: "https://api.newrelic.com/graphql"},
+		{region: newrelicguid.RegionEU, want: "https://api.eu.newrelic.com/graphql"},
+		{region: newrelicguid.RegionGov, want: "https://gov-api.newrelic.com/graphql"},
+	}
+
+	for _, tt := range tests {
+		t.Run(string(tt.region), func(t *testing.T) {
+			if got := resolveEndpoint(tt.region); got != tt.want {
+				t.Errorf("resolveEndpoint() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestConfigValidate(t *testing.T) {
+	tests := []struct {
+		name    string
+		cfg     Config
+		wantErr bool
+	}{
+		{name: "app ID", cfg: Config{AppID: "123"}},
+		{name: "cluster", cfg: Config{ClusterName: "prod"}},
+		{name: "cluster and app ID", cfg: Config{AppID: "123", ClusterName: "prod"}, wantErr: true},
+		{name: "cluster and app IDs", cfg: Config{AppID: "123,456", AppIDs: []string{"123", "456"}, ClusterName: "prod"}, wantErr: true},
+		{name: "no search", cfg: Config{}, wantErr: true},
+		{name: "namespace without cluster", cfg: Config{AppID: "123", KubernetesNamespace: "checkout"}, wantErr: true},
+		{name: "k8s-configmap without output file", cfg: Config{AppID: "123", OutputFormat: "k8s-configmap"}, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := tt.cfg.Validate(); (err != nil) != tt.wantErr {
+				t.Errorf("Validate() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+// This function sets the input parameters required by NewConfig.
+func setRequiredInputs(t *testing.T) {
+	t.Helper()
+	t.Setenv("INPUT_NEWRELICAPPID", "123")
+	t.Setenv("INPUT_NEWRELICAPIKEY", "NRAK-TEST")
+	t.Setenv("INPUT_NEWRELICREGION", "US")
+}
+
+func TestNewConfigVaultFallback(t *testing.T) {
+	setRequiredInputs(t)
+	t.Setenv("VAULT_ADDR", "https://vault.example.com")
+	t.Setenv("VAULT_TOKEN", "s.token")
+
+	config, err := NewConfig()
+	if err != nil {
+		t.Fatalf("NewConfig() error = %v", err)
+	}
+	if config.KeySources.VaultAddr != "https://vault.example.com" || config.KeySources.VaultToken != "s.token" {
+		t.Errorf("NewConfig() Vault address and token = %q, %q, want the ones of the Vault CLI", config.KeySources.VaultAddr, config.KeySources.VaultToken)
+	}
+
+	// The input parameters take precedence over the environment variables.
+	t.Setenv("INPUT_VAULT_ADDR", "https://vault.internal")
+	if config, _ = NewConfig(); config.KeySources.VaultAddr != "https://vault.internal" {
+		t.Errorf("NewConfig() Vault address = %q, want the one of the input parameter", config.KeySources.VaultAddr)
+	}
+}
+
+func TestNewConfigReportsAllInvalidInputs(t *testing.T) {
+	setRequiredInputs(t)
+	t.Setenv("INPUT_NEWRELICREGION", "APAC")
+	t.Setenv("INPUT_ENTITY_SEARCH_LIMIT", "500")
+
+	_, err := NewConfig()
+	var validationErrors ValidationErrors
+	if !errors.As(err, &validationErrors) {
+		t.Fatalf("NewConfig() error = %v, want ValidationErrors", err)
+	}
+	if len(validationErrors) != 2 {
+		t.Errorf("NewConfig() returned %d errors, want 2: %v", len(validationErrors), err)
+	}
+	if !errors.Is(validationErrors[0], ErrInvalidRegion) {
+		t.Errorf("NewConfig() first error = %v, want ErrInvalidRegion", validationErrors[0])
+	}
+}
+
+func TestNewConfigDefaults(t *testing.T) {
+	setRequiredInputs(t)
+
+	config, err := NewConfig()
+	if err != nil {
+		t.Fatalf("NewConfig() error = %v", err)
+	}
+	tests := []struct {
+		name string
+		got  interface{}
+		want interface{}
+	}{
+		{"AppID", config.AppID, "123"},
+		{"Endpoint", config.Endpoint, "https://api.newrelic.com/graphql"},
+		{"MultiValueDelimiter", config.MultiValueDelimiter, ","},
+		{"ConfigMapName", config.ConfigMapName, "newrelic-entity"},
+		{"DeploymentsSince", config.DeploymentsSince, "7 days ago"},
+		{"TimeSeriesSince", config.TimeSeriesSince, "30 MINUTES AGO"},
+		{"TimeSeriesUntil", config.TimeSeriesUntil, "NOW"},
+		{"MaxResponseBodyBytes", config.MaxResponseBodyBytes, defaultMaxResponseBodyBytes},
+		{"QueryTimeoutMs", config.QueryTimeoutMs, defaultQueryTimeoutMs},
+		{"EntitySearchLimit", config.EntitySearchLimit, defaultEntitySearchLimit},
+		{"EntitySearchSortBy", config.EntitySearchSortBy, defaultEntitySearchSortBy},
+		{"GraphQLOperationName", config.GraphQLOperationName, defaultGraphQLOperationName},
+		{"AcceptEncoding", config.AcceptEncoding, defaultAcceptEncoding},
+		{"BatchConcurrency", config.BatchConcurrency, 5},
+	}
+	for _, tt := range tests {
+		if tt.got != tt.want {
+			t.Errorf("NewConfig() %s = %v, want %v", tt.name, tt.got, tt.want)
+		}
+	}
+}
+
+func TestConfigValidateClusterAndAppID(t *testing.T) {
+	// A single error is reported for a cluster name combined with app IDs,
+	// even in batch mode.
+	cfg := Config{AppID: "123,456", AppIDs: []string{"123", "456"}, ClusterName: "prod"}
+	err := cfg.Validate()
+	var validationErrors ValidationErrors
+	if !errors.As(err, &validationErrors) || len(validationErrors) != 1 {
+		t.Fatalf("Validate() error = %v, want a single error", err)
+	}
+	if want := "A cluster name can not be combined with a NewRelic app ID."; validationErrors[0].Error() != want {
+		t.Errorf("Validate() error = %q, want %q", validationErrors[0], want)
+	}
+}
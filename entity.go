@@ -0,0 +1,2215 @@
+package main
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/zaljic/newrelic-guid-fetcher-action/pkg/newrelicguid"
+)
+
+// These types are defined in the newrelicguid package, so that they can be
+// used by other Go programs importing it.
+type (
+	GraphQL          = newrelicguid.GraphQL
+	Entity           = newrelicguid.Entity
+	EntityTag        = newrelicguid.EntityTag
+	ProjectionOption = newrelicguid.ProjectionOption
+	Region           = newrelicguid.Region
+)
+
+// This struct is used to unmarshal the summary metrics of an entity returned
+// by the New Relic API. ApmSummary is nil for entities that are not APM
+// applications or have not reported any data yet.
+type EntitySummary struct {
+	Data struct {
+		Actor struct {
+			Entity struct {
+				ApmSummary *struct {
+					ErrorRate           float64 `json:"errorRate"`
+					ResponseTimeAverage float64 `json:"responseTimeAverage"`
+					Throughput          float64 `json:"throughput"`
+				} `json:"apmSummary"`
+			} `json:"entity"`
+		} `json:"actor"`
+	} `json:"data"`
+}
+
+// This struct holds the components a New Relic GUID is made of.
+type GUIDComponents struct {
+	AccountID  int64  `json:"accountId"`
+	Domain     string `json:"domain"`
+	EntityType string `json:"entityType"`
+	EntityID   string `json:"entityId"`
+}
+
+// This struct is used to unmarshal the response of the entityUpdate mutation
+// returned by the New Relic API.
+type EntityUpdateResponse struct {
+	Data struct {
+		EntityUpdate struct {
+			Entity Entity `json:"entity"`
+			Errors []struct {
+				Description string `json:"description"`
+			} `json:"errors"`
+		} `json:"entityUpdate"`
+	} `json:"data"`
+}
+
+// This struct holds a single tag to add to an entity.
+type Tag struct {
+	Key   string `json:"key"`
+	Value string `json:"value"`
+}
+
+// This struct is used to unmarshal the response of the tagging mutations
+// returned by the New Relic API. Only the field of the mutation that has been
+// sent is set: TaggingAddTagsToEntity when adding tags, and
+// TaggingDeleteTagValuesFromEntity when deleting the values replaced by an
+// upsert.
+type TagsUpdateResponse struct {
+	Data struct {
+		TaggingAddTagsToEntity struct {
+			Errors []struct {
+				Message string `json:"message"`
+				Type    string `json:"type"`
+			} `json:"errors"`
+		} `json:"taggingAddTagsToEntity"`
+		TaggingDeleteTagValuesFromEntity struct {
+			Errors []struct {
+				Message string `json:"message"`
+				Type    string `json:"type"`
+			} `json:"errors"`
+		} `json:"taggingDeleteTagValuesFromEntity"`
+	} `json:"data"`
+}
+
+// This struct is used to unmarshal the related entities of an entity returned
+// by the New Relic API. Each result describes a relationship between a source
+// and a target entity, one of which is the entity itself.
+type RelatedEntities struct {
+	Data struct {
+		Actor struct {
+			Entity struct {
+				RelatedEntities struct {
+					Results []struct {
+						Type   string `json:"type"`
+						Source struct {
+							Entity Entity `json:"entity"`
+						} `json:"source"`
+						Target struct {
+							Entity Entity `json:"entity"`
+						} `json:"target"`
+					} `json:"results"`
+				} `json:"relatedEntities"`
+			} `json:"entity"`
+		} `json:"actor"`
+	} `json:"data"`
+}
+
+// This struct holds the relationships between the entity and the entities of
+// other accounts it depends on or is depended on by. The nodes are the
+// entities, starting with the entity itself, and the edges the relationships
+// between them, e.g. CALLS.
+type DependencyGraph struct {
+	Nodes []DependencyNode `json:"nodes"`
+	Edges []DependencyEdge `json:"edges"`
+}
+
+// This struct holds an entity of the dependency graph and the account it is
+// reported in.
+type DependencyNode struct {
+	GUID        string `json:"guid"`
+	Name        string `json:"name"`
+	EntityType  string `json:"entityType"`
+	AccountID   int    `json:"accountId"`
+	AccountName string `json:"accountName"`
+}
+
+// This struct holds a relationship of the dependency graph from the entity
+// with the source GUID to the entity with the target GUID.
+type DependencyEdge struct {
+	Source string `json:"source"`
+	Target string `json:"target"`
+	Type   string `json:"type"`
+}
+
+// This struct holds a single workload an entity belongs to.
+type Workload struct {
+	GUID string `json:"guid"`
+	Name string `json:"name"`
+}
+
+// This struct holds an infrastructure host the entity runs on.
+type Host struct {
+	GUID string `json:"guid"`
+	Name string `json:"name"`
+}
+
+// This struct holds the Kubernetes attributes of an entity running in a
+// Kubernetes cluster. An entity can run in more than one pod and container,
+// so all of them are listed.
+type K8sMetadata struct {
+	ClusterName    string   `json:"clusterName"`
+	Namespace      string   `json:"namespace"`
+	DeploymentName string   `json:"deploymentName"`
+	Pods           []string `json:"pods"`
+	Containers     []string `json:"containers"`
+}
+
+// This struct holds the Docker images an entity runs in. An entity can run in
+// more than one container, e.g. during a rolling deployment, so all distinct
+// image names and tags are listed.
+type ContainerImages struct {
+	Names []string `json:"names"`
+	Tags  []string `json:"tags"`
+}
+
+// This struct is used to unmarshal a workload entity and the status of the
+// workload returned by the New Relic API.
+type WorkloadEntity struct {
+	Data struct {
+		Actor struct {
+			Entity struct {
+				Entity
+				WorkloadStatus struct {
+					StatusValue string `json:"statusValue"`
+				} `json:"workloadStatus"`
+			} `json:"entity"`
+		} `json:"actor"`
+	} `json:"data"`
+}
+
+// This struct holds a single dashboard an entity is visualised in.
+type Dashboard struct {
+	GUID      string `json:"guid"`
+	Name      string `json:"name"`
+	Permalink string `json:"permalink"`
+}
+
+// This struct holds a single service an entity calls or is called by. The
+// relationship is given from the perspective of the entity: CALLS means that
+// the entity calls the service, CALLED_BY means that the service calls the
+// entity.
+type ServiceMapNeighbour struct {
+	GUID         string `json:"guid"`
+	Name         string `json:"name"`
+	EntityType   string `json:"entityType"`
+	Relationship string `json:"relationship"`
+}
+
+// This struct holds the team owning an entity and the contacts of the team,
+// which are used to route incidents to it.
+type Team struct {
+	GUID                      string `json:"guid"`
+	Name                      string `json:"name"`
+	SlackChannel              string `json:"slackChannel"`
+	PagerDutyEscalationPolicy string `json:"pagerDutyEscalationPolicy"`
+}
+
+// This struct holds the settings of an APM application, mirroring the
+// settings and apmSettings fields of the ApmApplicationEntity in the New Relic
+// API. The settings are empty for entities that are not APM applications.
+type APMSettings struct {
+	Settings struct {
+		ApdexTarget        float64 `json:"apdexTarget"`
+		ServerSideConfig   bool    `json:"serverSideConfig"`
+		RealUserMonitoring bool    `json:"realUserMonitoring"`
+	} `json:"settings"`
+	ApmSettings struct {
+		ErrorCollector struct {
+			Enabled              bool     `json:"enabled"`
+			ExpectedErrorClasses []string `json:"expectedErrorClasses"`
+			IgnoredErrorClasses  []string `json:"ignoredErrorClasses"`
+		} `json:"errorCollector"`
+		TransactionTracer struct {
+			Enabled        bool `json:"enabled"`
+			ExplainEnabled bool `json:"explainEnabled"`
+		} `json:"transactionTracer"`
+		ThreadProfiler struct {
+			Enabled bool `json:"enabled"`
+		} `json:"threadProfiler"`
+	} `json:"apmSettings"`
+}
+
+// This struct holds the differences between the APM settings of two entities,
+// e.g. of the same app in two environments. Identical is true if there are no
+// differences.
+type ConfigDiff struct {
+	GUID        string             `json:"guid"`
+	CompareGUID string             `json:"compareGuid"`
+	Identical   bool               `json:"identical"`
+	Differences []ConfigDifference `json:"differences"`
+}
+
+// This struct holds a setting whose value differs between two entities. The
+// path is the path of the setting in the JSON representation of the settings,
+// e.g. settings.apdexTarget.
+type ConfigDifference struct {
+	Path         string      `json:"path"`
+	Value        interface{} `json:"value"`
+	CompareValue interface{} `json:"compareValue"`
+}
+
+// This struct holds the oldest and newest version of the APM agents reporting
+// an APM application.
+type AgentVersions struct {
+	MinVersion string `json:"minVersion"`
+	MaxVersion string `json:"maxVersion"`
+}
+
+// This type describes how far the migration of an entity from a NewRelic APM
+// agent to OpenTelemetry has progressed.
+type MigrationStatus string
+
+// These constants are the migration statuses of an entity. An entity is
+// legacy while only an APM agent reports it, in progress while both an APM
+// agent and OpenTelemetry report it, and complete once only OpenTelemetry
+// reports it.
+const (
+	MigrationStatusLegacy     MigrationStatus = "legacy"
+	MigrationStatusInProgress MigrationStatus = "in-progress"
+	MigrationStatusComplete   MigrationStatus = "complete"
+)
+
+// This struct holds the result of the last check run by a synthetic monitor,
+// e.g. SUCCESS or FAILED, and the error of the check if it failed.
+type SyntheticMonitorStatus struct {
+	MonitorName string `json:"monitorName"`
+	Status      string `json:"status"`
+	Error       string `json:"error"`
+}
+
+// This struct holds a single deployment of an entity recorded by change
+// tracking.
+type Deployment struct {
+	Version     string `json:"version"`
+	Timestamp   string `json:"timestamp"`
+	User        string `json:"user"`
+	Description string `json:"description"`
+}
+
+// This struct holds a single data point of a metric time series.
+type MetricPoint struct {
+	Timestamp string  `json:"timestamp"`
+	Value     float64 `json:"value"`
+}
+
+// This struct holds a single log line of an entity.
+type LogLine struct {
+	Timestamp string `json:"timestamp"`
+	Message   string `json:"message"`
+}
+
+// This struct holds a single open alert violation of an entity. Violations
+// are reported as issues by the New Relic API, whose priority is the severity
+// of the violation.
+type Violation struct {
+	ID       string `json:"id"`
+	Title    string `json:"title"`
+	Priority string `json:"priority"`
+	State    string `json:"state"`
+}
+
+// This struct holds a single active anomaly of an entity detected by the
+// anomaly detection of New Relic AI. Anomalies are reported as issues by the
+// New Relic API, whose priority is the severity of the anomaly.
+type Anomaly struct {
+	ID          string `json:"id"`
+	Title       string `json:"title"`
+	Description string `json:"description"`
+	Priority    string `json:"priority"`
+	ActivatedAt string `json:"activatedAt"`
+}
+
+// This struct holds a single service level objective of an entity and its
+// current attainment in percent. The attainment is nil if the indicator has no
+// data over the time window of the objective, in which case it is unknown.
+type ServiceLevelObjective struct {
+	IndicatorGUID string   `json:"indicatorGuid"`
+	IndicatorName string   `json:"indicatorName"`
+	Target        float64  `json:"target"`
+	TimeWindow    string   `json:"timeWindow"`
+	Attainment    *float64 `json:"attainment"`
+}
+
+// This struct holds the daily attainment in percent of a single service level
+// objective of an entity over a period, the average attainment over the
+// period and the number of days the objective has been breached. The average
+// attainment is nil if there is no data on any day of the period.
+type ServiceLevelHistory struct {
+	IndicatorGUID     string            `json:"indicatorGuid"`
+	IndicatorName     string            `json:"indicatorName"`
+	Target            float64           `json:"target"`
+	AverageAttainment *float64          `json:"averageAttainment"`
+	Breaches          int               `json:"breaches"`
+	Days              []DailyAttainment `json:"days"`
+}
+
+// This struct holds the attainment in percent of a service level objective on
+// a single day. Days without data are left out.
+type DailyAttainment struct {
+	Date       string  `json:"date"`
+	Attainment float64 `json:"attainment"`
+}
+
+// This struct is used to unmarshal the service level indicators of an entity
+// returned by the New Relic API.
+type ServiceLevelIndicators struct {
+	Data struct {
+		Actor struct {
+			Entity struct {
+				ServiceLevel struct {
+					Indicators []struct {
+						GUID       string `json:"guid"`
+						Name       string `json:"name"`
+						Objectives []struct {
+							Target     float64 `json:"target"`
+							TimeWindow struct {
+								Rolling struct {
+									Count int    `json:"count"`
+									Unit  string `json:"unit"`
+								} `json:"rolling"`
+							} `json:"timeWindow"`
+						} `json:"objectives"`
+						ResultQueries struct {
+							Indicator struct {
+								Nrql string `json:"nrql"`
+							} `json:"indicator"`
+						} `json:"resultQueries"`
+					} `json:"indicators"`
+				} `json:"serviceLevel"`
+			} `json:"entity"`
+		} `json:"actor"`
+	} `json:"data"`
+}
+
+// This struct holds a single alert policy returned by the New Relic API.
+type AlertPolicy struct {
+	ID   int    `json:"id,string"`
+	Name string `json:"name"`
+}
+
+// This struct holds a NRQL alert condition monitoring an entity, together
+// with the name of the policy it belongs to.
+type AlertCondition struct {
+	ID         int              `json:"id,string"`
+	Name       string           `json:"name"`
+	Enabled    bool             `json:"enabled"`
+	Query      string           `json:"query"`
+	PolicyID   int              `json:"policyId,string"`
+	PolicyName string           `json:"policyName"`
+	Thresholds []AlertThreshold `json:"thresholds"`
+}
+
+// This struct holds a threshold of an alert condition, e.g. a CRITICAL
+// threshold that opens an incident if the query returns a value ABOVE 5 for
+// 300 seconds.
+type AlertThreshold struct {
+	Priority             string  `json:"priority"`
+	Operator             string  `json:"operator"`
+	Threshold            float64 `json:"threshold"`
+	ThresholdDuration    int     `json:"thresholdDuration"`
+	ThresholdOccurrences string  `json:"thresholdOccurrences"`
+}
+
+// This struct holds a notification channel alerts of the entity are sent to.
+// The values of the configuration of the channel are masked, e.g. the URL of a
+// Slack webhook or the email address of a recipient.
+type NotificationChannel struct {
+	ID            string            `json:"id"`
+	Name          string            `json:"name"`
+	Type          string            `json:"type"`
+	Configuration map[string]string `json:"configuration"`
+}
+
+// This struct is used to unmarshal the workflows and notification channels of
+// an account returned by the New Relic API.
+type AccountNotifications struct {
+	Data struct {
+		Actor struct {
+			Account struct {
+				AiWorkflows struct {
+					Workflows struct {
+						Entities []struct {
+							DestinationConfigurations []struct {
+								ChannelID string `json:"channelId"`
+							} `json:"destinationConfigurations"`
+							IssuesFilter struct {
+								Predicates []struct {
+									Attribute string   `json:"attribute"`
+									Values    []string `json:"values"`
+								} `json:"predicates"`
+							} `json:"issuesFilter"`
+						} `json:"entities"`
+					} `json:"workflows"`
+				} `json:"aiWorkflows"`
+				AiNotifications struct {
+					Channels struct {
+						Entities []struct {
+							ID         string `json:"id"`
+							Name       string `json:"name"`
+							Type       string `json:"type"`
+							Properties []struct {
+								Key   string `json:"key"`
+								Value string `json:"value"`
+							} `json:"properties"`
+						} `json:"entities"`
+					} `json:"channels"`
+				} `json:"aiNotifications"`
+			} `json:"account"`
+		} `json:"actor"`
+	} `json:"data"`
+}
+
+// This struct is used to unmarshal the account ID and alert severity of an
+// entity returned by the New Relic API.
+type EntityAlertStatus struct {
+	Data struct {
+		Actor struct {
+			Entity struct {
+				AccountID     int    `json:"accountId"`
+				AlertSeverity string `json:"alertSeverity"`
+			} `json:"entity"`
+		} `json:"actor"`
+	} `json:"data"`
+}
+
+// This struct is used to unmarshal the alert policies and NRQL conditions of
+// an account returned by the New Relic API.
+type AccountAlerts struct {
+	Data struct {
+		Actor struct {
+			Account struct {
+				Alerts struct {
+					NrqlConditionsSearch struct {
+						NrqlConditions []struct {
+							PolicyID int `json:"policyId,string"`
+						} `json:"nrqlConditions"`
+					} `json:"nrqlConditionsSearch"`
+					PoliciesSearch struct {
+						Policies []AlertPolicy `json:"policies"`
+					} `json:"policiesSearch"`
+				} `json:"alerts"`
+			} `json:"account"`
+		} `json:"actor"`
+	} `json:"data"`
+}
+
+// This function resolves the entities of the app IDs specified in the given
+// config. If more than one app ID is specified, the entities are resolved in
+// batch mode and returned in the order of the app IDs. Otherwise, all entities
+// matching the entity search are returned. The first entity is the application
+// entity all further steps operate on.
+func (c *Client) resolveEntities(ctx context.Context, config Config) ([]Entity, error) {
+	// Resolve the entities of all app IDs in batch mode.
+	if len(config.AppIDs) > 1 {
+		metrics := &BatchMetrics{}
+		results := c.resolveAllGUIDs(ctx, config.CacheEnabled, config.criteria(""), config.AppIDs, metrics, config.FailFast, config.BatchConcurrency)
+		if err := metrics.printSummary(); err != nil {
+			fmt.Printf("::warning::Writing the step summary failed: %s\n", err)
+		}
+
+		// Abort the batch with the error of the app ID that failed first if
+		// the fail_fast input parameter is set.
+		if config.FailFast {
+			if result := firstBatchError(ctx, results); result != nil {
+				return nil, fmt.Errorf("%s: %w", result.AppID, result.Err)
+			}
+		}
+
+		// Print the error of each app ID that could not be resolved and the
+		// outcome of all app IDs as JSON output parameter.
+		failed := 0
+		var entities []Entity
+		statuses := []BatchStatus{}
+		for _, result := range results {
+			status := BatchStatus{AppID: result.AppID, GUID: result.Entity.GUID, Success: result.Err == nil}
+			if result.Err != nil {
+				fmt.Printf("%s: %s\n", result.AppID, result.Err)
+				status.Error = result.Err.Error()
+				failed++
+			}
+			entities = append(entities, result.Entity)
+			statuses = append(statuses, status)
+		}
+		if err := setJSONOutput("batchErrors", statuses); err != nil {
+			return nil, err
+		}
+		if failed > 0 {
+			return nil, fmt.Errorf("resolving %d of %d app IDs failed", failed, len(results))
+		}
+
+		return entities, nil
+	}
+
+	// Call the getGUID function to fetch the list of applications from the
+	// NewRelic GraphQL endpoint.
+	searchQuery := buildSearchQuery(config.criteria(config.AppID))
+	graphqlResponse, err := c.getGUIDCached(ctx, config.CacheEnabled, searchQuery)
+	if err != nil {
+		return nil, err
+	}
+
+	// Print the cursor of the next page of results as output parameter, so
+	// that a following run can resume the search with the
+	// entity_search_cursor input parameter. It is empty on the last page.
+	nextCursor := ""
+	if graphqlResponse.Data.Actor.EntitySearch.Results.NextCursor != nil {
+		nextCursor = *graphqlResponse.Data.Actor.EntitySearch.Results.NextCursor
+	}
+	setOutput("nextCursor", nextCursor)
+
+	// Narrow the entities down to the ones related to the parent entity
+	// specified in the parent_guid input parameter.
+	if config.ParentGUID != "" {
+		results := &graphqlResponse.Data.Actor.EntitySearch.Results
+		results.Entities, err = c.filterByParent(ctx, config.ParentGUID, results.Entities)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	// Call the getApplicationEntity function to check that an entity has been
+	// found. A warning is printed if more than one entity has been found and
+	// the allow_multiple input parameter is not set, in which case the first
+	// entity is used.
+	_, err = getApplicationEntity(graphqlResponse)
+	if errors.Is(err, ErrMultipleEntitiesFound) {
+		if !config.AllowMultiple {
+			fmt.Printf("::warning::%s, using the first one\n", err)
+		}
+	} else if err != nil {
+		return nil, err
+	}
+
+	return graphqlResponse.Data.Actor.EntitySearch.Results.Entities, nil
+}
+
+// This function returns the entities that are related to the parent entity
+// with the given GUID, such as the Kubernetes cluster or workload a service
+// runs in. The entity search does not support filtering by relationships, so
+// the related entities of the parent are fetched and matched instead.
+func (c *Client) filterByParent(ctx context.Context, parentGUID string, entities []Entity) ([]Entity, error) {
+	// Fetch all entities related to the parent entity.
+	relatedEntities, err := c.getRelatedEntities(ctx, parentGUID, "")
+	if err != nil {
+		return nil, err
+	}
+
+	// Collect the GUID of the entity on the other side of each relationship.
+	related := map[string]bool{}
+	for _, result := range relatedEntities.Data.Actor.Entity.RelatedEntities.Results {
+		related[result.Source.Entity.GUID] = true
+		related[result.Target.Entity.GUID] = true
+	}
+
+	// Keep the entities related to the parent entity, in their original order.
+	filtered := []Entity{}
+	for _, entity := range entities {
+		if entity.GUID != parentGUID && related[entity.GUID] {
+			filtered = append(filtered, entity)
+		}
+	}
+
+	return filtered, nil
+}
+
+// This function fetches the summary metrics of the entity with the given GUID
+// from the NewRelic GraphQL endpoint.
+func (c *Client) getEntitySummary(ctx context.Context, guid string) (EntitySummary, error) {
+	// Specify the query to be sent to the NewRelic GraphQL endpoint.
+	query := fmt.Sprintf(`{ actor { entity(guid: %s) { ... on ApmApplicationEntity { apmSummary { errorRate responseTimeAverage throughput } } } } }`, graphqlString(guid))
+
+	// Send the query and unmarshal the response into the EntitySummary struct.
+	var summary EntitySummary
+	err := c.queryNerdGraph(ctx, query, &summary)
+	if err != nil {
+		return EntitySummary{}, err
+	}
+
+	// Return the entity summary.
+	return summary, nil
+}
+
+// This struct holds the performance metrics of a browser application entity.
+// The page load time is in seconds and the throughput in page views per
+// minute. The JavaScript error rate is a ratio, like the error rate of APM
+// applications.
+type BrowserSummary struct {
+	PageLoadTimeAverage     float64 `json:"pageLoadTimeAverage"`
+	PageLoadThroughput      float64 `json:"pageLoadThroughput"`
+	JsErrorRate             float64 `json:"jsErrorRate"`
+	AjaxResponseTimeAverage float64 `json:"ajaxResponseTimeAverage"`
+}
+
+// This variable holds the event types whose data ingest is attributed to an
+// entity by GetEntityUsageMetrics.
+var usageEventTypes = []string{"Transaction", "TransactionError", "Span", "Log", "Metric", "PageView", "PageViewTiming", "JavaScriptError", "AjaxRequest", "SystemSample", "ProcessSample", "NetworkSample", "StorageSample", "SyntheticCheck", "MobileSession", "MobileCrash"}
+
+// This function estimates the data ingest of the entity over the last 30
+// days in gigabytes, based on the size of the events of the entity stored in
+// its account. Data not attributed to an entity, e.g. custom events without
+// entity GUID, is not included.
+func (c *Client) GetEntityUsageMetrics(ctx context.Context, entity Entity) (float64, error) {
+	// Run the NRQL query estimating the size of the events of the entity.
+	// Depending on the event type, the GUID is stored in entity.guid or
+	// entityGuid.
+	guid := searchValue(entity.GUID)
+	nrql := fmt.Sprintf("SELECT bytecountestimate() / 10e8 AS 'dataIngestGB' FROM %s WHERE entity.guid = %s OR entityGuid = %s SINCE 30 DAYS AGO", strings.Join(usageEventTypes, ", "), guid, guid)
+	rows, err := c.GetNRQLQueryResult(ctx, entity.AccountID, nrql)
+	if err != nil {
+		return 0, err
+	}
+
+	// Return an error if NewRelic did not return the estimate.
+	if len(rows) == 0 {
+		return 0, fmt.Errorf("no usage metrics available for NewRelic entity %s", entity.GUID)
+	}
+	dataIngestGB, _ := rows[0]["dataIngestGB"].(float64)
+	return dataIngestGB, nil
+}
+
+// This function fetches the performance metrics of the browser application
+// entity. An error is returned if the entity is not a browser application or
+// NewRelic did not return any metrics for it.
+func (c *Client) GetEntityBrowserSummary(ctx context.Context, entity Entity) (BrowserSummary, error) {
+	// Return an error if the entity is not a browser application.
+	if entity.EntityType != "BROWSER_APPLICATION_ENTITY" {
+		return BrowserSummary{}, fmt.Errorf("NewRelic entity %s is not a browser application but %s", entity.GUID, entity.EntityType)
+	}
+
+	// Specify the query to be sent to the NewRelic GraphQL endpoint.
+	query := fmt.Sprintf(`{ actor { entity(guid: %s) { ... on BrowserApplicationEntity { browserSummary { pageLoadTimeAverage pageLoadThroughput jsErrorRate ajaxResponseTimeAverage } } } } }`, graphqlString(entity.GUID))
+
+	// Send the query and unmarshal the browser summary of the entity.
+	var summaryResponse struct {
+		Data struct {
+			Actor struct {
+				Entity struct {
+					BrowserSummary *BrowserSummary `json:"browserSummary"`
+				} `json:"entity"`
+			} `json:"actor"`
+		} `json:"data"`
+	}
+	err := c.queryNerdGraph(ctx, query, &summaryResponse)
+	if err != nil {
+		return BrowserSummary{}, err
+	}
+
+	// Return an error if NewRelic did not return any metrics.
+	summary := summaryResponse.Data.Actor.Entity.BrowserSummary
+	if summary == nil {
+		return BrowserSummary{}, fmt.Errorf("no browser summary metrics available for the NewRelic entity %s", entity.Name)
+	}
+
+	return *summary, nil
+}
+
+// This function checks the health of an entity based on its previously
+// fetched summary metrics. It returns an error if the error rate of the entity
+// exceeds maxErrorRatePercent or if the entity has no summary metrics. The
+// error rate reported by NewRelic is a ratio and is converted to a percentage
+// before comparing it.
+func CheckEntityHealth(summary EntitySummary, maxErrorRatePercent float64) error {
+	// Return an error if NewRelic did not return any summary metrics.
+	apmSummary := summary.Data.Actor.Entity.ApmSummary
+	if apmSummary == nil {
+		return errors.New("no summary metrics available for the NewRelic entity")
+	}
+
+	// Return an error if the error rate exceeds the threshold.
+	errorRatePercent := apmSummary.ErrorRate * 100
+	if errorRatePercent > maxErrorRatePercent {
+		return fmt.Errorf("NewRelic entity is unhealthy: error rate is %.2f%%, maximum allowed is %.2f%%", errorRatePercent, maxErrorRatePercent)
+	}
+
+	return nil
+}
+
+// This function checks whether the given entity is stale, i.e. has stopped
+// reporting more than maxAgeHours hours before now. It returns an error if it
+// is. Entities whose reporting status is unknown are not considered stale.
+func CheckEntityAge(entity Entity, maxAgeHours int, now time.Time) error {
+	if entity.Reporting == nil || *entity.Reporting || entity.LastReportingChangeAt == 0 {
+		return nil
+	}
+
+	// Return an error if the entity stopped reporting before the window.
+	stoppedReportingAt := time.UnixMilli(entity.LastReportingChangeAt)
+	if now.Sub(stoppedReportingAt) > time.Duration(maxAgeHours)*time.Hour {
+		return fmt.Errorf("NewRelic entity %s is stale: it stopped reporting at %s, more than %d hours ago", entity.GUID, stoppedReportingAt.UTC().Format(time.RFC3339), maxAgeHours)
+	}
+
+	return nil
+}
+
+// This function returns the application GUID of the previously fetched
+// GraphQL response. It is assumed that the entities list only contains one
+// GUID.
+func getApplicationGUID(graphqlResponse GraphQL) (string, error) {
+	// Return the application GUID.
+	entity, err := getApplicationEntity(graphqlResponse)
+	return entity.GUID, err
+}
+
+// This function returns the application entity of the previously fetched
+// GraphQL response. It returns ErrNoEntityFound if the entities list is empty.
+// If the list contains more than one entity, the first entity is returned
+// together with ErrMultipleEntitiesFound.
+func getApplicationEntity(graphqlResponse GraphQL) (Entity, error) {
+	entities := graphqlResponse.Data.Actor.EntitySearch.Results.Entities
+	query := graphqlResponse.Data.Actor.EntitySearch.Query
+
+	// Return an error if no entity has been found.
+	if len(entities) == 0 {
+		return Entity{}, fmt.Errorf("%w for query %q", ErrNoEntityFound, query)
+	}
+
+	// Return the first entity and an error if more than one entity has been
+	// found.
+	if len(entities) > 1 {
+		return entities[0], fmt.Errorf("%w for query %q: %d entities", ErrMultipleEntitiesFound, query, len(entities))
+	}
+
+	// Return the application entity.
+	return entities[0], nil
+}
+
+// This function decodes the given New Relic GUID into its components. A GUID
+// is the base64 encoding of "<accountId>|<domain>|<entityType>|<entityId>",
+// usually without padding. The entity ID is the last component and may itself
+// contain the separator.
+func DecodeGUID(guid string) (GUIDComponents, error) {
+	// Decode the GUID, ignoring any padding.
+	decoded, err := base64.RawStdEncoding.DecodeString(strings.TrimRight(guid, "="))
+	if err != nil {
+		return GUIDComponents{}, fmt.Errorf("invalid GUID %q: %w", guid, err)
+	}
+
+	// Split the decoded GUID into its components.
+	parts := strings.SplitN(string(decoded), "|", 4)
+	if len(parts) != 4 {
+		return GUIDComponents{}, fmt.Errorf("invalid GUID %q: expected 4 components, got %d", guid, len(parts))
+	}
+
+	// Parse the account ID.
+	accountID, err := strconv.ParseInt(parts[0], 10, 64)
+	if err != nil {
+		return GUIDComponents{}, fmt.Errorf("invalid GUID %q: invalid account ID %q", guid, parts[0])
+	}
+
+	return GUIDComponents{
+		AccountID:  accountID,
+		Domain:     parts[1],
+		EntityType: parts[2],
+		EntityID:   parts[3],
+	}, nil
+}
+
+// This function encodes the given components into a New Relic GUID, the
+// inverse of DecodeGUID. The account ID must be positive and the domain,
+// entity type and entity ID must not be empty. Only the entity ID may contain
+// the separator, as it is the last component.
+func EncodeGUID(components GUIDComponents) (string, error) {
+	// Validate the components.
+	if components.AccountID <= 0 {
+		return "", fmt.Errorf("invalid account ID %d", components.AccountID)
+	}
+	for _, field := range [][2]string{{"domain", components.Domain}, {"entity type", components.EntityType}} {
+		if field[1] == "" || strings.Contains(field[1], "|") {
+			return "", fmt.Errorf("invalid %s %q", field[0], field[1])
+		}
+	}
+	if components.EntityID == "" {
+		return "", errors.New("entity ID is empty")
+	}
+
+	// Join the components and encode them without padding, like New Relic.
+	decoded := fmt.Sprintf("%d|%s|%s|%s", components.AccountID, components.Domain, components.EntityType, components.EntityID)
+	return base64.RawStdEncoding.EncodeToString([]byte(decoded)), nil
+}
+
+// This function fetches the names of the account with the given ID and of all
+// its parent accounts, starting with the root account. Accounts without a
+// parent are root accounts. The hierarchy is walked at most 10 levels up, so
+// that a cycle in the hierarchy can not loop forever.
+func (c *Client) GetEntityAccountHierarchy(ctx context.Context, accountID int) ([]string, error) {
+	var names []string
+	visited := map[int]bool{}
+	for accountID != 0 && !visited[accountID] && len(names) < 10 {
+		visited[accountID] = true
+
+		// Specify the query to be sent to the NewRelic GraphQL endpoint.
+		query := fmt.Sprintf(`{ actor { account(id: %d) { name parentId } } }`, accountID)
+
+		// Send the query and unmarshal the name and parent of the account.
+		var accountResponse struct {
+			Data struct {
+				Actor struct {
+					Account struct {
+						Name     string `json:"name"`
+						ParentID int    `json:"parentId"`
+					} `json:"account"`
+				} `json:"actor"`
+			} `json:"data"`
+		}
+		err := c.queryNerdGraph(ctx, query, &accountResponse)
+		if err != nil {
+			return nil, err
+		}
+
+		// Prepend the account, so that the root account comes first.
+		account := accountResponse.Data.Actor.Account
+		names = append([]string{account.Name}, names...)
+		accountID = account.ParentID
+	}
+
+	return names, nil
+}
+
+// This function fetches the account ID and the alert severity of the entity
+// with the given GUID. The alert severity is CRITICAL, WARNING, NOT_ALERTING
+// or NOT_CONFIGURED.
+func (c *Client) getEntityAlertStatus(ctx context.Context, guid string) (EntityAlertStatus, error) {
+	// Specify the query to be sent to the NewRelic GraphQL endpoint.
+	query := fmt.Sprintf(`{ actor { entity(guid: %s) { accountId ... on AlertableEntity { alertSeverity } } } }`, graphqlString(guid))
+
+	// Send the query and unmarshal the response into the EntityAlertStatus
+	// struct.
+	var alertStatus EntityAlertStatus
+	err := c.queryNerdGraph(ctx, query, &alertStatus)
+	if err != nil {
+		return EntityAlertStatus{}, err
+	}
+
+	return alertStatus, nil
+}
+
+// This function fetches the alert policies that monitor the given entity. A
+// policy is considered to monitor the entity if it contains a NRQL condition
+// whose query references the name of the entity.
+func (c *Client) GetAlertPolicies(ctx context.Context, entity Entity) ([]AlertPolicy, error) {
+	// Fetch the account ID and alert severity of the entity.
+	alertStatus, err := c.getEntityAlertStatus(ctx, entity.GUID)
+	if err != nil {
+		return nil, err
+	}
+
+	// Return an empty list if no alerts are configured for the entity.
+	policies := []AlertPolicy{}
+	if alertStatus.Data.Actor.Entity.AlertSeverity == "NOT_CONFIGURED" {
+		return policies, nil
+	}
+
+	// Fetch the alert policies and the NRQL conditions referencing the entity
+	// from the account the entity is reported in.
+	query := fmt.Sprintf(`{ actor { account(id: %d) { alerts { nrqlConditionsSearch(searchCriteria: {queryLike: %s}) { nrqlConditions { policyId } } policiesSearch { policies { id name } } } } } }`, alertStatus.Data.Actor.Entity.AccountID, graphqlString(entity.Name))
+	var accountAlerts AccountAlerts
+	err = c.queryNerdGraph(ctx, query, &accountAlerts)
+	if err != nil {
+		return nil, err
+	}
+
+	// Collect the IDs of the policies containing a condition for the entity.
+	alerts := accountAlerts.Data.Actor.Account.Alerts
+	policyIDs := map[int]bool{}
+	for _, condition := range alerts.NrqlConditionsSearch.NrqlConditions {
+		policyIDs[condition.PolicyID] = true
+	}
+
+	// Return the policies containing a condition for the entity.
+	for _, policy := range alerts.PoliciesSearch.Policies {
+		if policyIDs[policy.ID] {
+			policies = append(policies, policy)
+		}
+	}
+
+	return policies, nil
+}
+
+// This function fetches the NRQL alert conditions monitoring the given entity
+// with their thresholds and the names of their policies. Like in
+// GetAlertPolicies, a condition is considered to monitor the entity if its
+// query references the name of the entity.
+func (c *Client) GetRelatedAlertConditions(ctx context.Context, entity Entity) ([]AlertCondition, error) {
+	// Fetch the account ID and alert severity of the entity.
+	alertStatus, err := c.getEntityAlertStatus(ctx, entity.GUID)
+	if err != nil {
+		return nil, err
+	}
+
+	// Return an empty list if no alerts are configured for the entity.
+	conditions := []AlertCondition{}
+	if alertStatus.Data.Actor.Entity.AlertSeverity == "NOT_CONFIGURED" {
+		return conditions, nil
+	}
+
+	// Fetch the NRQL conditions referencing the entity and the alert policies
+	// from the account the entity is reported in.
+	query := fmt.Sprintf(`{ actor { account(id: %d) { alerts { nrqlConditionsSearch(searchCriteria: {queryLike: %s}) { nrqlConditions { id name enabled policyId nrql { query } terms { priority operator threshold thresholdDuration thresholdOccurrences } } } policiesSearch { policies { id name } } } } } }`, alertStatus.Data.Actor.Entity.AccountID, graphqlString(entity.Name))
+	var accountAlerts struct {
+		Data struct {
+			Actor struct {
+				Account struct {
+					Alerts struct {
+						NrqlConditionsSearch struct {
+							NrqlConditions []struct {
+								ID       int    `json:"id,string"`
+								Name     string `json:"name"`
+								Enabled  bool   `json:"enabled"`
+								PolicyID int    `json:"policyId,string"`
+								Nrql     struct {
+									Query string `json:"query"`
+								} `json:"nrql"`
+								Terms []AlertThreshold `json:"terms"`
+							} `json:"nrqlConditions"`
+						} `json:"nrqlConditionsSearch"`
+						PoliciesSearch struct {
+							Policies []AlertPolicy `json:"policies"`
+						} `json:"policiesSearch"`
+					} `json:"alerts"`
+				} `json:"account"`
+			} `json:"actor"`
+		} `json:"data"`
+	}
+	err = c.queryNerdGraph(ctx, query, &accountAlerts)
+	if err != nil {
+		return nil, err
+	}
+
+	// Look up the names of the policies by ID.
+	alerts := accountAlerts.Data.Actor.Account.Alerts
+	policyNames := map[int]string{}
+	for _, policy := range alerts.PoliciesSearch.Policies {
+		policyNames[policy.ID] = policy.Name
+	}
+
+	// Return the conditions with the names of their policies.
+	for _, condition := range alerts.NrqlConditionsSearch.NrqlConditions {
+		thresholds := condition.Terms
+		if thresholds == nil {
+			thresholds = []AlertThreshold{}
+		}
+		conditions = append(conditions, AlertCondition{
+			ID:         condition.ID,
+			Name:       condition.Name,
+			Enabled:    condition.Enabled,
+			Query:      condition.Nrql.Query,
+			PolicyID:   condition.PolicyID,
+			PolicyName: policyNames[condition.PolicyID],
+			Thresholds: thresholds,
+		})
+	}
+
+	return conditions, nil
+}
+
+// This function fetches the notification channels alerts of the given entity
+// are sent to. Alerts of a policy are sent to the channels of the workflows
+// filtering issues by the ID of the policy, so the channels are collected from
+// the workflows of the alert policies monitoring the entity.
+func (c *Client) GetEntityNotificationChannels(ctx context.Context, entity Entity) ([]NotificationChannel, error) {
+	// Fetch the alert policies monitoring the entity.
+	policies, err := c.GetAlertPolicies(ctx, entity)
+	if err != nil {
+		return nil, err
+	}
+	channels := []NotificationChannel{}
+	if len(policies) == 0 {
+		return channels, nil
+	}
+	policyIDs := map[string]bool{}
+	for _, policy := range policies {
+		policyIDs[strconv.Itoa(policy.ID)] = true
+	}
+
+	// Fetch the workflows and notification channels from the account the
+	// entity is reported in.
+	query := fmt.Sprintf(`{ actor { account(id: %d) { aiWorkflows { workflows { entities { destinationConfigurations { channelId } issuesFilter { predicates { attribute values } } } } } aiNotifications { channels { entities { id name type properties { key value } } } } } } }`, entity.AccountID)
+	var notifications AccountNotifications
+	err = c.queryNerdGraph(ctx, query, &notifications)
+	if err != nil {
+		return nil, err
+	}
+
+	// Collect the IDs of the channels of the workflows filtering issues by the
+	// ID of one of the policies.
+	account := notifications.Data.Actor.Account
+	channelIDs := map[string]bool{}
+	for _, workflow := range account.AiWorkflows.Workflows.Entities {
+		matches := false
+		for _, predicate := range workflow.IssuesFilter.Predicates {
+			if predicate.Attribute != "labels.policyIds" {
+				continue
+			}
+			for _, value := range predicate.Values {
+				if policyIDs[value] {
+					matches = true
+				}
+			}
+		}
+		if !matches {
+			continue
+		}
+		for _, destination := range workflow.DestinationConfigurations {
+			channelIDs[destination.ChannelID] = true
+		}
+	}
+
+	// Return the channels of the workflows with their configuration masked.
+	for _, channel := range account.AiNotifications.Channels.Entities {
+		if !channelIDs[channel.ID] {
+			continue
+		}
+		configuration := map[string]string{}
+		for _, property := range channel.Properties {
+			configuration[property.Key] = maskValue(property.Value)
+		}
+		channels = append(channels, NotificationChannel{
+			ID:            channel.ID,
+			Name:          channel.Name,
+			Type:          channel.Type,
+			Configuration: configuration,
+		})
+	}
+
+	return channels, nil
+}
+
+// This function masks all but the last four characters of the given value, so
+// that configuration values can be told apart without being disclosed. Values
+// of up to four characters are masked completely.
+func maskValue(value string) string {
+	characters := []rune(value)
+	if len(characters) <= 4 {
+		return strings.Repeat("*", len(characters))
+	}
+	return strings.Repeat("*", len(characters)-4) + string(characters[len(characters)-4:])
+}
+
+// This function fetches the entities related to the entity with the given
+// GUID. The filter is passed as is to the relatedEntities field and may be
+// empty to fetch all related entities.
+func (c *Client) getRelatedEntities(ctx context.Context, guid string, filter string) (RelatedEntities, error) {
+	// Add the filter argument, if any.
+	arguments := ""
+	if filter != "" {
+		arguments = fmt.Sprintf("(filter: %s)", filter)
+	}
+
+	// Specify the query to be sent to the NewRelic GraphQL endpoint.
+	query := fmt.Sprintf(`{ actor { entity(guid: %s) { relatedEntities%s { results { type source { entity { accountId entityType name guid permalink } } target { entity { accountId entityType name guid permalink } } } } } } }`, graphqlString(guid), arguments)
+
+	// Send the query and unmarshal the response into the RelatedEntities
+	// struct.
+	var relatedEntities RelatedEntities
+	err := c.queryNerdGraph(ctx, query, &relatedEntities)
+	if err != nil {
+		return RelatedEntities{}, err
+	}
+
+	return relatedEntities, nil
+}
+
+// This function fetches the Docker images the entity with the given GUID runs
+// in. NewRelic adds them as docker.imageName and docker.imageTag tags to the
+// entities reported by agents running in a container, so the images of other
+// entities are empty.
+func (c *Client) GetEntityContainerImages(ctx context.Context, guid string) (ContainerImages, error) {
+	// Fetch the tags of the entity.
+	tags, err := c.getEntityTags(ctx, guid)
+	if err != nil {
+		return ContainerImages{}, err
+	}
+
+	// Read the distinct image names and tags from the tags.
+	images := ContainerImages{Names: []string{}, Tags: []string{}}
+	seen := map[string]bool{}
+	for _, tag := range tags {
+		for _, value := range tag.Values {
+			if seen[tag.Key+"="+value] {
+				continue
+			}
+			seen[tag.Key+"="+value] = true
+			switch tag.Key {
+			case "docker.imageName":
+				images.Names = append(images.Names, value)
+			case "docker.imageTag":
+				images.Tags = append(images.Tags, value)
+			}
+		}
+	}
+
+	return images, nil
+}
+
+// This function checks that the entity runs the Docker image with the given
+// tag only. It returns an error if the entity has no image tag or runs any
+// other image tag, e.g. because a rolling deployment has not finished yet.
+func CheckContainerImageTag(images ContainerImages, expectedTag string) error {
+	if len(images.Tags) == 0 {
+		return fmt.Errorf("no Docker image tag available for the NewRelic entity, expected %s", expectedTag)
+	}
+	for _, tag := range images.Tags {
+		if tag != expectedTag {
+			return fmt.Errorf("NewRelic entity runs Docker image tag %s, expected %s", strings.Join(images.Tags, ", "), expectedTag)
+		}
+	}
+	return nil
+}
+
+// This function fetches the Kubernetes attributes of the entity with the given
+// GUID. NewRelic adds them as k8s.* tags to the entities reported by agents
+// running in a Kubernetes cluster, so the attributes of other entities are
+// empty.
+func (c *Client) GetEntityK8sMetadata(ctx context.Context, guid string) (K8sMetadata, error) {
+	// Fetch the tags of the entity.
+	tags, err := c.getEntityTags(ctx, guid)
+	if err != nil {
+		return K8sMetadata{}, err
+	}
+
+	// Read the Kubernetes attributes from the tags.
+	metadata := K8sMetadata{Pods: []string{}, Containers: []string{}}
+	for _, tag := range tags {
+		if len(tag.Values) == 0 {
+			continue
+		}
+		switch tag.Key {
+		case "k8s.clusterName":
+			metadata.ClusterName = tag.Values[0]
+		case "k8s.namespaceName":
+			metadata.Namespace = tag.Values[0]
+		case "k8s.deploymentName":
+			metadata.DeploymentName = tag.Values[0]
+		case "k8s.podName":
+			metadata.Pods = append(metadata.Pods, tag.Values...)
+		case "k8s.containerName":
+			metadata.Containers = append(metadata.Containers, tag.Values...)
+		}
+	}
+
+	return metadata, nil
+}
+
+// This function fetches the workloads the entity with the given GUID belongs
+// to. Workloads are related to the entities they contain, so the workloads
+// are the related entities of the WORKLOAD type.
+func (c *Client) GetEntityWorkloads(ctx context.Context, guid string) ([]Workload, error) {
+	// Fetch the related workload entities.
+	relatedEntities, err := c.getRelatedEntities(ctx, guid, `{entityDomainTypes: {include: [{domain: "NR1", type: "WORKLOAD"}]}}`)
+	if err != nil {
+		return nil, err
+	}
+
+	// Collect the workload of each relationship, which is the entity on the
+	// other side of the relationship.
+	workloads := []Workload{}
+	for _, result := range relatedEntities.Data.Actor.Entity.RelatedEntities.Results {
+		workload := result.Source.Entity
+		if workload.GUID == guid {
+			workload = result.Target.Entity
+		}
+		if workload.EntityType == "WORKLOAD_ENTITY" {
+			workloads = append(workloads, Workload{GUID: workload.GUID, Name: workload.Name})
+		}
+	}
+
+	return workloads, nil
+}
+
+// This function fetches the infrastructure hosts the entity with the given
+// GUID runs on. Hosts are related to the applications running on them by the
+// HOSTS relationship, so the hosts are the sources of these relationships.
+func (c *Client) GetEntityHosts(ctx context.Context, guid string) ([]Host, error) {
+	// Fetch the related entities hosting the entity.
+	relatedEntities, err := c.getRelatedEntities(ctx, guid, `{relationshipTypes: {include: [HOSTS]}}`)
+	if err != nil {
+		return nil, err
+	}
+
+	// Collect the host of each relationship the entity is the target of.
+	hosts := []Host{}
+	for _, result := range relatedEntities.Data.Actor.Entity.RelatedEntities.Results {
+		if result.Type == "HOSTS" && result.Target.Entity.GUID == guid {
+			hosts = append(hosts, Host{GUID: result.Source.Entity.GUID, Name: result.Source.Entity.Name})
+		}
+	}
+
+	return hosts, nil
+}
+
+// This function fetches the team owning the entity with the given GUID. Teams
+// are related to the entities they own by the OWNS relationship. The contacts
+// of the team are read from its slackChannel and pagerDutyEscalationPolicy
+// tags. If the entity is not owned by a team, nil is returned.
+func (c *Client) GetEntityTeam(ctx context.Context, guid string) (*Team, error) {
+	// Fetch the related entities owning the entity.
+	relatedEntities, err := c.getRelatedEntities(ctx, guid, `{relationshipTypes: {include: [OWNS]}}`)
+	if err != nil {
+		return nil, err
+	}
+
+	// Find the owner of the entity, which is the source of the relationship.
+	var team *Team
+	for _, result := range relatedEntities.Data.Actor.Entity.RelatedEntities.Results {
+		if result.Type == "OWNS" && result.Target.Entity.GUID == guid {
+			team = &Team{GUID: result.Source.Entity.GUID, Name: result.Source.Entity.Name}
+			break
+		}
+	}
+	if team == nil {
+		return nil, nil
+	}
+
+	// Read the contacts of the team from its tags.
+	tags, err := c.getEntityTags(ctx, team.GUID)
+	if err != nil {
+		return nil, err
+	}
+	for _, tag := range tags {
+		if len(tag.Values) == 0 {
+			continue
+		}
+		switch tag.Key {
+		case "slackChannel":
+			team.SlackChannel = tag.Values[0]
+		case "pagerDutyEscalationPolicy":
+			team.PagerDutyEscalationPolicy = tag.Values[0]
+		}
+	}
+
+	return team, nil
+}
+
+// This function fetches the status of the workload with the given GUID, e.g.
+// OPERATIONAL, DEGRADED or DISRUPTED.
+func (c *Client) GetWorkloadStatus(ctx context.Context, guid string) (string, error) {
+	// Specify the query to be sent to the NewRelic GraphQL endpoint.
+	query := fmt.Sprintf(`{ actor { entity(guid: %s) { accountId entityType name guid ... on WorkloadEntity { workloadStatus { statusValue } } } } }`, graphqlString(guid))
+
+	// Send the query and unmarshal the response into the WorkloadEntity struct.
+	var workload WorkloadEntity
+	err := c.queryNerdGraph(ctx, query, &workload)
+	if err != nil {
+		return "", err
+	}
+
+	return workload.Data.Actor.Entity.WorkloadStatus.StatusValue, nil
+}
+
+// This function fetches the dashboards that visualise the entity with the
+// given GUID. Dashboards are related to the entities they visualise, so the
+// dashboards are the related entities of the DASHBOARD type.
+func (c *Client) GetEntityDashboards(ctx context.Context, guid string) ([]Dashboard, error) {
+	// Fetch the related dashboard entities.
+	relatedEntities, err := c.getRelatedEntities(ctx, guid, `{entityDomainTypes: {include: [{domain: "VIZ", type: "DASHBOARD"}]}}`)
+	if err != nil {
+		return nil, err
+	}
+
+	// Collect the dashboard of each relationship, which is the entity on the
+	// other side of the relationship.
+	dashboards := []Dashboard{}
+	for _, result := range relatedEntities.Data.Actor.Entity.RelatedEntities.Results {
+		dashboard := result.Source.Entity
+		if dashboard.GUID == guid {
+			dashboard = result.Target.Entity
+		}
+		if dashboard.EntityType == "DASHBOARD_ENTITY" {
+			dashboards = append(dashboards, Dashboard{GUID: dashboard.GUID, Name: dashboard.Name, Permalink: dashboard.Permalink})
+		}
+	}
+
+	return dashboards, nil
+}
+
+// This function fetches the service map of the entity with the given GUID,
+// which are the services the entity calls (downstream) and the services that
+// call the entity (upstream).
+func (c *Client) GetServiceMap(ctx context.Context, guid string) ([]ServiceMapNeighbour, error) {
+	// Fetch the related entities of the CALLS and CALLED_BY relationships.
+	relatedEntities, err := c.getRelatedEntities(ctx, guid, `{relationshipTypes: {include: [CALLS, CALLED_BY]}}`)
+	if err != nil {
+		return nil, err
+	}
+
+	// Collect the service on the other side of each relationship. NewRelic
+	// describes each relationship from its source to its target, so the
+	// relationship is inverted if the entity is the target.
+	inverse := map[string]string{"CALLS": "CALLED_BY", "CALLED_BY": "CALLS"}
+	neighbours := []ServiceMapNeighbour{}
+	for _, result := range relatedEntities.Data.Actor.Entity.RelatedEntities.Results {
+		neighbour, relationship := result.Target.Entity, result.Type
+		if neighbour.GUID == guid {
+			neighbour, relationship = result.Source.Entity, inverse[result.Type]
+		}
+		if relationship == "" {
+			continue
+		}
+		neighbours = append(neighbours, ServiceMapNeighbour{
+			GUID:         neighbour.GUID,
+			Name:         neighbour.Name,
+			EntityType:   neighbour.EntityType,
+			Relationship: relationship,
+		})
+	}
+
+	return neighbours, nil
+}
+
+// This function fetches the relationships between the given entity and the
+// entities reported in the accounts with the given IDs. The API key must have
+// access to all of these accounts, otherwise an error is returned, as the
+// relationships to entities of inaccessible accounts are not returned by
+// NewRelic.
+func (c *Client) GetEntityCrossAccountDependencies(ctx context.Context, entity Entity, accountIDs []int) (DependencyGraph, error) {
+	// Fetch the names of the accounts the API key has access to.
+	var accounts struct {
+		Data struct {
+			Actor struct {
+				Accounts []struct {
+					ID   int    `json:"id"`
+					Name string `json:"name"`
+				} `json:"accounts"`
+			} `json:"actor"`
+		} `json:"data"`
+	}
+	err := c.queryNerdGraph(ctx, `{ actor { accounts { id name } } }`, &accounts)
+	if err != nil {
+		return DependencyGraph{}, err
+	}
+	accountNames := map[int]string{}
+	for _, account := range accounts.Data.Actor.Accounts {
+		accountNames[account.ID] = account.Name
+	}
+
+	// Return an error if the API key has no access to one of the accounts.
+	crossAccount := map[int]bool{}
+	for _, accountID := range accountIDs {
+		if _, ok := accountNames[accountID]; !ok {
+			return DependencyGraph{}, fmt.Errorf("NewRelic API key has no access to account %d", accountID)
+		}
+		crossAccount[accountID] = true
+	}
+
+	// Fetch all entities related to the entity.
+	relatedEntities, err := c.getRelatedEntities(ctx, entity.GUID, "")
+	if err != nil {
+		return DependencyGraph{}, err
+	}
+
+	// Add the entity itself and each entity on the other side of a
+	// relationship that is reported in one of the accounts to the graph.
+	graph := DependencyGraph{Nodes: []DependencyNode{}, Edges: []DependencyEdge{}}
+	addNode := func(node Entity) {
+		graph.Nodes = append(graph.Nodes, DependencyNode{
+			GUID:        node.GUID,
+			Name:        node.Name,
+			EntityType:  node.EntityType,
+			AccountID:   node.AccountID,
+			AccountName: accountNames[node.AccountID],
+		})
+	}
+	addNode(entity)
+	seen := map[string]bool{entity.GUID: true}
+	for _, result := range relatedEntities.Data.Actor.Entity.RelatedEntities.Results {
+		other := result.Target.Entity
+		if other.GUID == entity.GUID {
+			other = result.Source.Entity
+		}
+		if !crossAccount[other.AccountID] {
+			continue
+		}
+		if !seen[other.GUID] {
+			seen[other.GUID] = true
+			addNode(other)
+		}
+		graph.Edges = append(graph.Edges, DependencyEdge{
+			Source: result.Source.Entity.GUID,
+			Target: result.Target.Entity.GUID,
+			Type:   result.Type,
+		})
+	}
+
+	return graph, nil
+}
+
+// This function fetches the APM settings of the entity with the given GUID,
+// such as the Apdex target, error collection and transaction tracing.
+func (c *Client) GetEntityApplicationSettings(ctx context.Context, guid string) (APMSettings, error) {
+	// Specify the query to be sent to the NewRelic GraphQL endpoint.
+	query := fmt.Sprintf(`{ actor { entity(guid: %s) { ... on ApmApplicationEntity { settings { apdexTarget serverSideConfig realUserMonitoring } apmSettings { errorCollector { enabled expectedErrorClasses ignoredErrorClasses } transactionTracer { enabled explainEnabled } threadProfiler { enabled } } } } } }`, graphqlString(guid))
+
+	// Send the query and unmarshal the settings of the entity.
+	var settingsResponse struct {
+		Data struct {
+			Actor struct {
+				Entity APMSettings `json:"entity"`
+			} `json:"actor"`
+		} `json:"data"`
+	}
+	err := c.queryNerdGraph(ctx, query, &settingsResponse)
+	if err != nil {
+		return APMSettings{}, err
+	}
+
+	return settingsResponse.Data.Actor.Entity, nil
+}
+
+// This function fetches the APM settings of the entities with the given GUIDs
+// and returns the settings whose values differ between them, sorted by path.
+// Lists, e.g. of ignored error classes, are compared as a whole.
+func (c *Client) CompareEntityConfigs(ctx context.Context, guid1 string, guid2 string) (ConfigDiff, error) {
+	// Fetch the settings of both entities and flatten them into their paths.
+	var flattened [2]map[string]interface{}
+	for i, guid := range []string{guid1, guid2} {
+		settings, err := c.GetEntityApplicationSettings(ctx, guid)
+		if err != nil {
+			return ConfigDiff{}, err
+		}
+		data, err := json.Marshal(settings)
+		if err != nil {
+			return ConfigDiff{}, err
+		}
+		var document interface{}
+		if err := json.Unmarshal(data, &document); err != nil {
+			return ConfigDiff{}, err
+		}
+		flattened[i] = map[string]interface{}{}
+		flattenJSON("", document, flattened[i])
+	}
+
+	// Collect the paths whose values differ.
+	diff := ConfigDiff{GUID: guid1, CompareGUID: guid2, Differences: []ConfigDifference{}}
+	paths := make([]string, 0, len(flattened[0]))
+	for path := range flattened[0] {
+		paths = append(paths, path)
+	}
+	sort.Strings(paths)
+	for _, path := range paths {
+		if !equalJSONValues(flattened[0][path], flattened[1][path]) {
+			diff.Differences = append(diff.Differences, ConfigDifference{
+				Path:         path,
+				Value:        flattened[0][path],
+				CompareValue: flattened[1][path],
+			})
+		}
+	}
+	diff.Identical = len(diff.Differences) == 0
+
+	return diff, nil
+}
+
+// This function adds the values of the given decoded JSON document to the
+// given map, keyed by their path below the given prefix. Objects are
+// descended into, while all other values, including lists, are added as is.
+func flattenJSON(prefix string, value interface{}, paths map[string]interface{}) {
+	object, ok := value.(map[string]interface{})
+	if !ok {
+		paths[prefix] = value
+		return
+	}
+	for key, field := range object {
+		path := key
+		if prefix != "" {
+			path = prefix + "." + key
+		}
+		flattenJSON(path, field, paths)
+	}
+}
+
+// This function fetches the oldest and newest version of the APM agents
+// reporting the entity with the given GUID. The versions are empty for
+// entities that are not APM applications.
+func (c *Client) GetEntityLanguageAgentVersion(ctx context.Context, guid string) (AgentVersions, error) {
+	// Specify the query to be sent to the NewRelic GraphQL endpoint.
+	query := fmt.Sprintf(`{ actor { entity(guid: %s) { ... on ApmApplicationEntity { runningAgentVersions { minVersion maxVersion } } } } }`, graphqlString(guid))
+
+	// Send the query and unmarshal the agent versions of the entity.
+	var versionsResponse struct {
+		Data struct {
+			Actor struct {
+				Entity struct {
+					RunningAgentVersions AgentVersions `json:"runningAgentVersions"`
+				} `json:"entity"`
+			} `json:"actor"`
+		} `json:"data"`
+	}
+	err := c.queryNerdGraph(ctx, query, &versionsResponse)
+	if err != nil {
+		return AgentVersions{}, err
+	}
+
+	return versionsResponse.Data.Actor.Entity.RunningAgentVersions, nil
+}
+
+// This function determines the migration status of the entity with the given
+// GUID from the versions of the APM agents reporting it and its data source
+// tags. NewRelic tags entities reported by OpenTelemetry with
+// instrumentation.provider opentelemetry or the telemetry.sdk.* attributes.
+func (c *Client) GetEntityMigrationStatus(ctx context.Context, guid string) (MigrationStatus, error) {
+	// Fetch the versions of the APM agents reporting the entity.
+	versions, err := c.GetEntityLanguageAgentVersion(ctx, guid)
+	if err != nil {
+		return "", err
+	}
+	apmAgent := versions.MinVersion != "" || versions.MaxVersion != ""
+
+	// Fetch the tags of the entity and check whether OpenTelemetry reports it.
+	tags, err := c.getEntityTags(ctx, guid)
+	if err != nil {
+		return "", err
+	}
+	openTelemetry := false
+	for _, tag := range tags {
+		switch {
+		case tag.Key == "instrumentation.provider":
+			for _, value := range tag.Values {
+				if strings.EqualFold(value, "opentelemetry") {
+					openTelemetry = true
+				}
+			}
+		case strings.HasPrefix(tag.Key, "telemetry.sdk."):
+			openTelemetry = true
+		}
+	}
+
+	switch {
+	case openTelemetry && apmAgent:
+		return MigrationStatusInProgress, nil
+	case openTelemetry:
+		return MigrationStatusComplete, nil
+	}
+	return MigrationStatusLegacy, nil
+}
+
+// This function compares the dotted version numbers a and b, e.g. 8.10.1 and
+// 8.9.0. It returns a negative number if a is older than b, zero if both are
+// equal and a positive number if a is newer than b. Missing parts count as 0
+// and a non-numeric suffix of a part, e.g. -beta, is ignored.
+func compareVersions(a string, b string) int {
+	partsA := strings.Split(a, ".")
+	partsB := strings.Split(b, ".")
+	for i := 0; i < len(partsA) || i < len(partsB); i++ {
+		var numberA, numberB int
+		if i < len(partsA) {
+			numberA = leadingNumber(partsA[i])
+		}
+		if i < len(partsB) {
+			numberB = leadingNumber(partsB[i])
+		}
+		if numberA != numberB {
+			return numberA - numberB
+		}
+	}
+	return 0
+}
+
+// This function returns the number the given string starts with, or 0 if it
+// does not start with a digit.
+func leadingNumber(s string) int {
+	end := 0
+	for end < len(s) && s[end] >= '0' && s[end] <= '9' {
+		end++
+	}
+	number, _ := strconv.Atoi(s[:end])
+	return number
+}
+
+// This function fetches the result of the last check run by the given
+// synthetic monitor entity in the last day. The results of the checks are
+// stored as SyntheticCheck events in the account of the monitor, so they are
+// fetched with a NRQL query. An error is returned if the entity is not a
+// synthetic monitor or has not run any check in the last day.
+func (c *Client) GetSyntheticMonitorStatus(ctx context.Context, entity Entity) (SyntheticMonitorStatus, error) {
+	// Return an error if the entity is not a synthetic monitor.
+	if entity.EntityType != "SYNTHETIC_MONITOR_ENTITY" {
+		return SyntheticMonitorStatus{}, fmt.Errorf("NewRelic entity %s is not a synthetic monitor but %s", entity.GUID, entity.EntityType)
+	}
+
+	// Run the NRQL query for the last SyntheticCheck event of the monitor.
+	nrql := fmt.Sprintf("SELECT latest(result) AS 'result', latest(error) AS 'error' FROM SyntheticCheck WHERE entityGuid = %s SINCE 1 DAY AGO", searchValue(entity.GUID))
+	rows, err := c.GetNRQLQueryResult(ctx, entity.AccountID, nrql)
+	if err != nil {
+		return SyntheticMonitorStatus{}, err
+	}
+
+	// Return an error if the monitor has not run any check.
+	status := SyntheticMonitorStatus{MonitorName: entity.Name}
+	if len(rows) > 0 {
+		status.Status, _ = rows[0]["result"].(string)
+		status.Error, _ = rows[0]["error"].(string)
+	}
+	if status.Status == "" {
+		return SyntheticMonitorStatus{}, fmt.Errorf("synthetic monitor %s has not run any check in the last day", entity.Name)
+	}
+
+	return status, nil
+}
+
+// This function fetches the deployments of the given entity since the given
+// time, e.g. "7 days ago", newest first. Deployments recorded by change
+// tracking are stored as Deployment events in the account of the entity, so
+// they are fetched with a NRQL query.
+func (c *Client) GetEntityDeployments(ctx context.Context, entity Entity, since string) ([]Deployment, error) {
+	// Run the NRQL query for the Deployment events of the entity.
+	nrql := fmt.Sprintf("SELECT timestamp, version, user, description FROM Deployment WHERE entity.guid = %s SINCE %s LIMIT MAX", searchValue(entity.GUID), since)
+	rows, err := c.GetNRQLQueryResult(ctx, entity.AccountID, nrql)
+	if err != nil {
+		return nil, err
+	}
+
+	// Convert the result rows into deployments. The timestamp is returned in
+	// milliseconds since the epoch.
+	deployments := []Deployment{}
+	for _, row := range rows {
+		deployment := Deployment{}
+		if timestamp, ok := row["timestamp"].(float64); ok {
+			deployment.Timestamp = time.UnixMilli(int64(timestamp)).UTC().Format(time.RFC3339)
+		}
+		deployment.Version, _ = row["version"].(string)
+		deployment.User, _ = row["user"].(string)
+		deployment.Description, _ = row["description"].(string)
+		deployments = append(deployments, deployment)
+	}
+
+	return deployments, nil
+}
+
+// This function fetches the time series of the golden metrics of the given
+// entity, i.e. the response time in milliseconds, the throughput in requests
+// per minute and the error rate in percent, between since and until, e.g. "30
+// MINUTES AGO" and "NOW". The time series are keyed by the name of the metric.
+func (c *Client) GetEntityMetricTimeSeries(ctx context.Context, entity Entity, since string, until string) (map[string][]MetricPoint, error) {
+	// Run the NRQL query for the Transaction events of the entity.
+	metrics := []string{"responseTime", "throughput", "errorRate"}
+	nrql := fmt.Sprintf("SELECT average(duration) * 1000 AS 'responseTime', rate(count(*), 1 minute) AS 'throughput', percentage(count(*), WHERE error IS true) AS 'errorRate' FROM Transaction WHERE entity.guid = %s SINCE %s UNTIL %s TIMESERIES", searchValue(entity.GUID), since, until)
+	rows, err := c.GetNRQLQueryResult(ctx, entity.AccountID, nrql)
+	if err != nil {
+		return nil, err
+	}
+
+	// Convert the result rows into a time series per metric. Each row holds
+	// one time bucket, whose start is returned in seconds since the epoch.
+	timeSeries := map[string][]MetricPoint{}
+	for _, metric := range metrics {
+		timeSeries[metric] = []MetricPoint{}
+	}
+	for _, row := range rows {
+		beginTimeSeconds, _ := row["beginTimeSeconds"].(float64)
+		timestamp := time.Unix(int64(beginTimeSeconds), 0).UTC().Format(time.RFC3339)
+		for _, metric := range metrics {
+			value, _ := row[metric].(float64)
+			timeSeries[metric] = append(timeSeries[metric], MetricPoint{Timestamp: timestamp, Value: value})
+		}
+	}
+
+	return timeSeries, nil
+}
+
+// This function fetches the log lines the given entity reported in the last 10
+// minutes, at most 20 of them, newest first. Logs in context are stored as Log
+// events in the account of the entity, so they are fetched with a NRQL query.
+func (c *Client) GetEntityLogs(ctx context.Context, entity Entity) ([]LogLine, error) {
+	// Run the NRQL query for the Log events of the entity.
+	nrql := fmt.Sprintf("FROM Log SELECT message, timestamp WHERE entity.guid = %s SINCE 10 MINUTES AGO LIMIT 20", searchValue(entity.GUID))
+	rows, err := c.GetNRQLQueryResult(ctx, entity.AccountID, nrql)
+	if err != nil {
+		return nil, err
+	}
+
+	// Convert the result rows into log lines. The timestamp is returned in
+	// milliseconds since the epoch.
+	logs := []LogLine{}
+	for _, row := range rows {
+		logLine := LogLine{}
+		if timestamp, ok := row["timestamp"].(float64); ok {
+			logLine.Timestamp = time.UnixMilli(int64(timestamp)).UTC().Format(time.RFC3339)
+		}
+		logLine.Message, _ = row["message"].(string)
+		logs = append(logs, logLine)
+	}
+
+	return logs, nil
+}
+
+// This function fetches the open alert violations of the given entity. The
+// violations are searched in the account the entity is reported in.
+func (c *Client) GetEntityViolations(ctx context.Context, entity Entity) ([]Violation, error) {
+	// Specify the query to be sent to the NewRelic GraphQL endpoint.
+	query := fmt.Sprintf(`{ actor { account(id: %d) { aiIssues { issues(filter: {entityGuids: [%s], states: [ACTIVATED, CREATED]}) { issues { issueId title priority state } } } } } }`, entity.AccountID, graphqlString(entity.GUID))
+
+	// Send the query and unmarshal the open issues of the entity.
+	var issuesResponse struct {
+		Data struct {
+			Actor struct {
+				Account struct {
+					AiIssues struct {
+						Issues struct {
+							Issues []struct {
+								IssueID  string   `json:"issueId"`
+								Title    []string `json:"title"`
+								Priority string   `json:"priority"`
+								State    string   `json:"state"`
+							} `json:"issues"`
+						} `json:"issues"`
+					} `json:"aiIssues"`
+				} `json:"account"`
+			} `json:"actor"`
+		} `json:"data"`
+	}
+	err := c.queryNerdGraph(ctx, query, &issuesResponse)
+	if err != nil {
+		return nil, err
+	}
+
+	// Collect the violations. An issue can have more than one title, which
+	// are joined into one.
+	violations := []Violation{}
+	for _, issue := range issuesResponse.Data.Actor.Account.AiIssues.Issues.Issues {
+		violations = append(violations, Violation{
+			ID:       issue.IssueID,
+			Title:    strings.Join(issue.Title, "; "),
+			Priority: issue.Priority,
+			State:    issue.State,
+		})
+	}
+
+	return violations, nil
+}
+
+// This function fetches the active anomalies of the given entity. Anomalies
+// are the issues whose source is the anomaly detection of New Relic AI. They
+// are searched in the account the entity is reported in.
+func (c *Client) GetEntityAnomalies(ctx context.Context, entity Entity) ([]Anomaly, error) {
+	// Specify the query to be sent to the NewRelic GraphQL endpoint.
+	query := fmt.Sprintf(`{ actor { account(id: %d) { aiIssues { issues(filter: {entityGuids: [%s], states: [ACTIVATED]}) { issues { issueId title description priority sources activatedAt } } } } } }`, entity.AccountID, graphqlString(entity.GUID))
+
+	// Send the query and unmarshal the active issues of the entity.
+	var issuesResponse struct {
+		Data struct {
+			Actor struct {
+				Account struct {
+					AiIssues struct {
+						Issues struct {
+							Issues []struct {
+								IssueID     string   `json:"issueId"`
+								Title       []string `json:"title"`
+								Description []string `json:"description"`
+								Priority    string   `json:"priority"`
+								Sources     []string `json:"sources"`
+								ActivatedAt *int64   `json:"activatedAt"`
+							} `json:"issues"`
+						} `json:"issues"`
+					} `json:"aiIssues"`
+				} `json:"account"`
+			} `json:"actor"`
+		} `json:"data"`
+	}
+	err := c.queryNerdGraph(ctx, query, &issuesResponse)
+	if err != nil {
+		return nil, err
+	}
+
+	// Collect the issues detected by anomaly detection. The activation time is
+	// returned in milliseconds since the epoch.
+	anomalies := []Anomaly{}
+	for _, issue := range issuesResponse.Data.Actor.Account.AiIssues.Issues.Issues {
+		isAnomaly := false
+		for _, source := range issue.Sources {
+			if strings.Contains(strings.ToLower(source), "anomal") {
+				isAnomaly = true
+			}
+		}
+		if !isAnomaly {
+			continue
+		}
+		anomaly := Anomaly{
+			ID:          issue.IssueID,
+			Title:       strings.Join(issue.Title, "; "),
+			Description: strings.Join(issue.Description, "; "),
+			Priority:    issue.Priority,
+		}
+		if issue.ActivatedAt != nil {
+			anomaly.ActivatedAt = time.UnixMilli(*issue.ActivatedAt).UTC().Format(time.RFC3339)
+		}
+		anomalies = append(anomalies, anomaly)
+	}
+
+	return anomalies, nil
+}
+
+// This function runs the given NRQL query in the account with the given ID
+// and returns the result rows.
+func (c *Client) GetNRQLQueryResult(ctx context.Context, accountID int, nrql string) ([]map[string]interface{}, error) {
+	// Specify the query to be sent to the NewRelic GraphQL endpoint.
+	query := fmt.Sprintf(`{ actor { account(id: %d) { nrql(query: %s) { results } } } }`, accountID, graphqlString(nrql))
+
+	// Send the query and unmarshal the result rows.
+	var nrqlResponse struct {
+		Data struct {
+			Actor struct {
+				Account struct {
+					Nrql struct {
+						Results []map[string]interface{} `json:"results"`
+					} `json:"nrql"`
+				} `json:"account"`
+			} `json:"actor"`
+		} `json:"data"`
+	}
+	err := c.queryNerdGraph(ctx, query, &nrqlResponse)
+	if err != nil {
+		return nil, err
+	}
+
+	// Return an empty list instead of nil if the query has no results.
+	rows := nrqlResponse.Data.Actor.Account.Nrql.Results
+	if rows == nil {
+		rows = []map[string]interface{}{}
+	}
+
+	return rows, nil
+}
+
+// This function runs the given GraphQL query with the given values of its
+// variables and returns the data of the response as is.
+func (c *Client) RunGraphQLQuery(ctx context.Context, query string, variables map[string]interface{}) (json.RawMessage, error) {
+	// Send the query and keep the data of the response.
+	var graphqlResponse struct {
+		Data json.RawMessage `json:"data"`
+	}
+	err := c.queryNerdGraphWithVariables(ctx, query, variables, &graphqlResponse)
+	if err != nil {
+		return nil, err
+	}
+
+	return graphqlResponse.Data, nil
+}
+
+// This constant holds the alias of the attainment in the result query of a
+// service level indicator, which NewRelic generates as SELECT
+// clamp_max(sum(newrelic.sli.good) / sum(newrelic.sli.valid) * 100, 100) AS
+// 'SLI' FROM Metric WHERE ...
+const sliAttainmentKey = "SLI"
+
+// This function fetches the service level objectives of the entity with the
+// given GUID and their current attainment. The attainment is calculated by
+// running the indicator's result query over the objective's time window in
+// the account the indicator belongs to, which is encoded in its GUID.
+func (c *Client) GetEntitySLOs(ctx context.Context, guid string) ([]ServiceLevelObjective, error) {
+	// Fetch the service level indicators and objectives of the entity.
+	indicators, err := c.getServiceLevelIndicators(ctx, guid)
+	if err != nil {
+		return nil, err
+	}
+
+	objectives := []ServiceLevelObjective{}
+	for _, indicator := range indicators.Data.Actor.Entity.ServiceLevel.Indicators {
+		// Get the account the indicator belongs to.
+		components, err := DecodeGUID(indicator.GUID)
+		if err != nil {
+			return nil, err
+		}
+
+		for _, objective := range indicator.Objectives {
+			// Run the indicator's result query over the objective's time window.
+			rolling := objective.TimeWindow.Rolling
+			nrql := fmt.Sprintf("%s SINCE %d %s AGO", indicator.ResultQueries.Indicator.Nrql, rolling.Count, rolling.Unit)
+			rows, err := c.GetNRQLQueryResult(ctx, int(components.AccountID), nrql)
+			if err != nil {
+				return nil, err
+			}
+
+			// The result query returns the attainment as the value aliased
+			// SLI of the only row. Without data, the value is null and the
+			// attainment is left unknown rather than reported as 0%.
+			var attainment *float64
+			if len(rows) == 1 {
+				value, ok := rows[0][sliAttainmentKey]
+				if !ok {
+					return nil, fmt.Errorf("%w: the result of the service level indicator %s has no %s value", ErrUnexpectedResponse, indicator.Name, sliAttainmentKey)
+				}
+				if value != nil {
+					number, ok := value.(float64)
+					if !ok {
+						return nil, fmt.Errorf("%w: the %s value %v of the service level indicator %s is not a number", ErrUnexpectedResponse, sliAttainmentKey, value, indicator.Name)
+					}
+					attainment = &number
+				}
+			}
+
+			objectives = append(objectives, ServiceLevelObjective{
+				IndicatorGUID: indicator.GUID,
+				IndicatorName: indicator.Name,
+				Target:        objective.Target,
+				TimeWindow:    fmt.Sprintf("%d %s", rolling.Count, rolling.Unit),
+				Attainment:    attainment,
+			})
+		}
+	}
+
+	return objectives, nil
+}
+
+// This function fetches the service level indicators of the entity with the
+// given GUID, including their objectives and result queries.
+func (c *Client) getServiceLevelIndicators(ctx context.Context, guid string) (ServiceLevelIndicators, error) {
+	// Specify the query to be sent to the NewRelic GraphQL endpoint.
+	query := fmt.Sprintf(`{ actor { entity(guid: %s) { serviceLevel { indicators { guid name objectives { target timeWindow { rolling { count unit } } } resultQueries { indicator { nrql } } } } } } }`, graphqlString(guid))
+
+	// Send the query and unmarshal the response into the
+	// ServiceLevelIndicators struct.
+	var indicators ServiceLevelIndicators
+	err := c.queryNerdGraph(ctx, query, &indicators)
+	if err != nil {
+		return ServiceLevelIndicators{}, err
+	}
+
+	return indicators, nil
+}
+
+// This function fetches the daily attainment of the service level objectives
+// of the entity with the given GUID over the last periodDays days. The
+// attainment is calculated by running the indicator's result query as a daily
+// time series in the account the indicator belongs to. A day on which the
+// attainment is below the target of the objective counts as a breach.
+func (c *Client) GetEntityServiceLevelHistory(ctx context.Context, guid string, periodDays int) ([]ServiceLevelHistory, error) {
+	// Fetch the service level indicators and objectives of the entity.
+	indicators, err := c.getServiceLevelIndicators(ctx, guid)
+	if err != nil {
+		return nil, err
+	}
+
+	history := []ServiceLevelHistory{}
+	for _, indicator := range indicators.Data.Actor.Entity.ServiceLevel.Indicators {
+		// Get the account the indicator belongs to.
+		components, err := DecodeGUID(indicator.GUID)
+		if err != nil {
+			return nil, err
+		}
+
+		// Run the indicator's result query as a daily time series over the
+		// period. The time series is the same for all objectives of the
+		// indicator, only the targets differ.
+		nrql := fmt.Sprintf("%s SINCE %d DAYS AGO TIMESERIES 1 day", indicator.ResultQueries.Indicator.Nrql, periodDays)
+		rows, err := c.GetNRQLQueryResult(ctx, int(components.AccountID), nrql)
+		if err != nil {
+			return nil, err
+		}
+
+		// Each row holds one day, whose start is returned in seconds since the
+		// epoch, and the attainment as the only other number. Days without
+		// data have no attainment.
+		days := []DailyAttainment{}
+		for _, row := range rows {
+			beginTimeSeconds, _ := row["beginTimeSeconds"].(float64)
+			for key, value := range row {
+				if number, ok := value.(float64); ok && key != "beginTimeSeconds" && key != "endTimeSeconds" {
+					days = append(days, DailyAttainment{
+						Date:       time.Unix(int64(beginTimeSeconds), 0).UTC().Format("2006-01-02"),
+						Attainment: number,
+					})
+					break
+				}
+			}
+		}
+
+		for _, objective := range indicator.Objectives {
+			// Average the attainment and count the breaches of the objective.
+			objectiveHistory := ServiceLevelHistory{
+				IndicatorGUID: indicator.GUID,
+				IndicatorName: indicator.Name,
+				Target:        objective.Target,
+				Days:          days,
+			}
+			// The average attainment is left unknown if there is no data on
+			// any day of the period.
+			if len(days) > 0 {
+				average := 0.0
+				for _, day := range days {
+					average += day.Attainment / float64(len(days))
+					if day.Attainment < objective.Target {
+						objectiveHistory.Breaches++
+					}
+				}
+				objectiveHistory.AverageAttainment = &average
+			}
+			history = append(history, objectiveHistory)
+		}
+	}
+
+	return history, nil
+}
+
+// This function adds the given tags to the entity with the given GUID using
+// the taggingAddTagsToEntity mutation. Values of the same key are added
+// together, existing tags of the entity are kept.
+//
+// The taggingAddTagsToEntity mutation accumulates values: if the entity
+// already has a tag with the same key, the new values are appended to the
+// existing ones, so tagging every deployment with its version results in a
+// tag holding all versions ever deployed. If upsert is true, the values are
+// assigned instead: only the given values the entity does not have yet are
+// added, and afterwards the current values of the given keys that are not
+// given are deleted using the taggingDeleteTagValuesFromEntity mutation, so
+// that each given key only holds the given values. Tags with other keys are
+// never sent, so tags added by another writer in the meantime are kept.
+func (c *Client) updateEntityTags(ctx context.Context, guid string, tags []Tag, upsert bool) error {
+	// Group the values by key in the order the keys first appear.
+	var keys []string
+	values := map[string][]string{}
+	for _, tag := range tags {
+		if _, ok := values[tag.Key]; !ok {
+			keys = append(keys, tag.Key)
+		}
+		values[tag.Key] = append(values[tag.Key], tag.Value)
+	}
+
+	// To upsert the tags, compare the given values with the current values of
+	// the given keys. Keys whose values do not change are left alone.
+	var staleValueInputs []string
+	if upsert {
+		currentTags, err := c.getEntityTags(ctx, guid)
+		if err != nil {
+			return err
+		}
+		currentValues := map[string]map[string]bool{}
+		for _, tag := range currentTags {
+			currentValues[tag.Key] = map[string]bool{}
+			for _, value := range tag.Values {
+				currentValues[tag.Key][value] = true
+			}
+		}
+
+		var changedKeys []string
+		for _, key := range keys {
+			givenValues := map[string]bool{}
+			var missingValues []string
+			for _, value := range values[key] {
+				givenValues[value] = true
+				if !currentValues[key][value] {
+					missingValues = append(missingValues, value)
+				}
+			}
+			for _, tag := range currentTags {
+				if tag.Key != key {
+					continue
+				}
+				for _, value := range tag.Values {
+					if !givenValues[value] {
+						staleValueInputs = append(staleValueInputs, fmt.Sprintf("{key: %s, value: %s}", graphqlString(key), graphqlString(value)))
+					}
+				}
+			}
+			if len(missingValues) > 0 {
+				changedKeys = append(changedKeys, key)
+				values[key] = missingValues
+			}
+		}
+		keys = changedKeys
+	}
+
+	// Add the values, unless the entity already has all of them.
+	if len(keys) > 0 {
+		// Build the list of tags argument of the mutation.
+		var tagInputs []string
+		for _, key := range keys {
+			var quotedValues []string
+			for _, value := range values[key] {
+				quotedValues = append(quotedValues, graphqlString(value))
+			}
+			tagInputs = append(tagInputs, fmt.Sprintf("{key: %s, values: [%s]}", graphqlString(key), strings.Join(quotedValues, ", ")))
+		}
+
+		// Send the mutation and unmarshal the response into the
+		// TagsUpdateResponse struct.
+		query := fmt.Sprintf(`mutation { taggingAddTagsToEntity(guid: %s, tags: [%s]) { errors { message type } } }`, graphqlString(guid), strings.Join(tagInputs, ", "))
+		var updateResponse TagsUpdateResponse
+		err := c.queryNerdGraph(ctx, query, &updateResponse)
+		if err != nil {
+			return err
+		}
+
+		// Return an error if NewRelic rejected the mutation.
+		if errs := updateResponse.Data.TaggingAddTagsToEntity.Errors; len(errs) > 0 {
+			return fmt.Errorf("tagging NewRelic entity %s failed: %s", guid, errs[0].Message)
+		}
+	}
+
+	// Delete the values replaced by the upsert. They are only deleted once the
+	// new values have been added, so that the keys are never missing.
+	if len(staleValueInputs) > 0 {
+		query := fmt.Sprintf(`mutation { taggingDeleteTagValuesFromEntity(guid: %s, tagValues: [%s]) { errors { message type } } }`, graphqlString(guid), strings.Join(staleValueInputs, ", "))
+		var updateResponse TagsUpdateResponse
+		err := c.queryNerdGraph(ctx, query, &updateResponse)
+		if err != nil {
+			return err
+		}
+
+		// Return an error if NewRelic rejected the mutation.
+		if errs := updateResponse.Data.TaggingDeleteTagValuesFromEntity.Errors; len(errs) > 0 {
+			return fmt.Errorf("deleting replaced tag values of NewRelic entity %s failed: %s", guid, errs[0].Message)
+		}
+	}
+
+	return nil
+}
+
+// This function fetches all tags of the entity with the given GUID.
+func (c *Client) getEntityTags(ctx context.Context, guid string) ([]EntityTag, error) {
+	// Specify the query to be sent to the NewRelic GraphQL endpoint.
+	query := fmt.Sprintf(`{ actor { entity(guid: %s) { tags { key values } } } }`, graphqlString(guid))
+
+	// Send the query and unmarshal the tags of the entity.
+	var tagsResponse struct {
+		Data struct {
+			Actor struct {
+				Entity struct {
+					Tags []EntityTag `json:"tags"`
+				} `json:"entity"`
+			} `json:"actor"`
+		} `json:"data"`
+	}
+	err := c.queryNerdGraph(ctx, query, &tagsResponse)
+	if err != nil {
+		return nil, err
+	}
+
+	// Return an empty list instead of nil if the entity has no tags.
+	tags := tagsResponse.Data.Actor.Entity.Tags
+	if tags == nil {
+		tags = []EntityTag{}
+	}
+
+	return tags, nil
+}
+
+// This function renames the entity with the given GUID to newName using the
+// entityUpdate mutation.
+func (c *Client) renameEntity(ctx context.Context, guid string, newName string) error {
+	// Specify the mutation to be sent to the NewRelic GraphQL endpoint.
+	query := fmt.Sprintf(`mutation { entityUpdate(guid: %s, entity: {name: %s}) { entity { accountId entityType name guid } errors { description } } }`, graphqlString(guid), graphqlString(newName))
+
+	// Send the mutation and unmarshal the response into the
+	// EntityUpdateResponse struct.
+	var updateResponse EntityUpdateResponse
+	err := c.queryNerdGraph(ctx, query, &updateResponse)
+	if err != nil {
+		return err
+	}
+
+	// Return an error if NewRelic rejected the mutation.
+	if errs := updateResponse.Data.EntityUpdate.Errors; len(errs) > 0 {
+		return fmt.Errorf("renaming NewRelic entity %s failed: %s", guid, errs[0].Description)
+	}
+
+	return nil
+}
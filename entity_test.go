@@ -0,0 +1,402 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"sync"
+	"testing"
+)
+
+// This struct records the GraphQL queries received by a fixture server.
+type recordedQueries struct {
+	mutex   sync.Mutex
+	queries []string
+}
+
+// This function returns the queries received so far.
+func (r *recordedQueries) all() []string {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	return append([]string(nil), r.queries...)
+}
+
+// This function starts a fake NewRelic GraphQL endpoint that responds to each
+// query with the fixture file of the first rule whose field the query
+// contains. The rules are pairs of a field, e.g. "taggingAddTagsToEntity", and
+// the path of the fixture file. Queries matching no rule are answered with a
+// GraphQL error.
+func newFixtureServer(t *testing.T, rules [][2]string) (*httptest.Server, *recordedQueries) {
+	t.Helper()
+
+	recorded := &recordedQueries{}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var request graphQLRequest
+		if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		recorded.mutex.Lock()
+		recorded.queries = append(recorded.queries, request.Query)
+		recorded.mutex.Unlock()
+
+		w.Header().Set("Content-Type", "application/json")
+		for _, rule := range rules {
+			if strings.Contains(request.Query, rule[0]) {
+				fixture, err := os.ReadFile(rule[1])
+				if err != nil {
+					http.Error(w, err.Error(), http.StatusInternalServerError)
+					return
+				}
+				w.Write(fixture)
+				return
+			}
+		}
+		w.Write([]byte(`{"errors":[{"message":"unexpected query"}]}`))
+	}))
+	t.Cleanup(server.Close)
+
+	return server, recorded
+}
+
+func TestUpdateEntityTags(t *testing.T) {
+	// The entity has the tags team=checkout and version=1.0.0,1.1.0.
+	tags := []Tag{{Key: "version", Value: "1.2.0"}, {Key: "env", Value: "prod"}, {Key: "env", Value: "eu"}}
+	tests := []struct {
+		name          string
+		tags          []Tag
+		upsert        bool
+		addFixture    string
+		deleteFixture string
+		wantQuery     []string
+		wantErr       string
+	}{
+		{
+			name:       "add",
+			tags:       tags,
+			addFixture: "testdata/tagging/add_tags_to_entity.json",
+			wantQuery: []string{
+				`mutation { taggingAddTagsToEntity(guid: "MXxBUE18QVBQTElDQVRJT058MQ", tags: [{key: "version", values: ["1.2.0"]}, {key: "env", values: ["prod", "eu"]}]) { errors { message type } } }`,
+			},
+		},
+		{
+			name:       "add rejected",
+			tags:       tags,
+			addFixture: "testdata/tagging/add_tags_to_entity_error.json",
+			wantQuery: []string{
+				`taggingAddTagsToEntity(`,
+			},
+			wantErr: "tagging NewRelic entity MXxBUE18QVBQTElDQVRJT058MQ failed: Too many tags",
+		},
+		{
+			name:          "upsert",
+			tags:          tags,
+			upsert:        true,
+			addFixture:    "testdata/tagging/add_tags_to_entity.json",
+			deleteFixture: "testdata/tagging/delete_tag_values_from_entity.json",
+			wantQuery: []string{
+				`{ actor { entity(guid: "MXxBUE18QVBQTElDQVRJT058MQ") { tags { key values } } } }`,
+				`mutation { taggingAddTagsToEntity(guid: "MXxBUE18QVBQTElDQVRJT058MQ", tags: [{key: "version", values: ["1.2.0"]}, {key: "env", values: ["prod", "eu"]}]) { errors { message type } } }`,
+				`mutation { taggingDeleteTagValuesFromEntity(guid: "MXxBUE18QVBQTElDQVRJT058MQ", tagValues: [{key: "version", value: "1.0.0"}, {key: "version", value: "1.1.0"}]) { errors { message type } } }`,
+			},
+		},
+		{
+			name:          "upsert keeping a current value",
+			tags:          []Tag{{Key: "version", Value: "1.1.0"}, {Key: "version", Value: "1.2.0"}},
+			upsert:        true,
+			addFixture:    "testdata/tagging/add_tags_to_entity.json",
+			deleteFixture: "testdata/tagging/delete_tag_values_from_entity.json",
+			wantQuery: []string{
+				`tags { key values }`,
+				`taggingAddTagsToEntity(guid: "MXxBUE18QVBQTElDQVRJT058MQ", tags: [{key: "version", values: ["1.2.0"]}])`,
+				`taggingDeleteTagValuesFromEntity(guid: "MXxBUE18QVBQTElDQVRJT058MQ", tagValues: [{key: "version", value: "1.0.0"}])`,
+			},
+		},
+		{
+			name:   "upsert of unchanged values",
+			tags:   []Tag{{Key: "team", Value: "checkout"}},
+			upsert: true,
+			wantQuery: []string{
+				`tags { key values }`,
+			},
+		},
+		{
+			name:       "upsert rejected",
+			tags:       tags,
+			upsert:     true,
+			addFixture: "testdata/tagging/add_tags_to_entity_error.json",
+			wantQuery: []string{
+				`tags { key values }`,
+				`taggingAddTagsToEntity(`,
+			},
+			wantErr: "tagging NewRelic entity MXxBUE18QVBQTElDQVRJT058MQ failed: Too many tags",
+		},
+		{
+			name:          "deleting replaced values rejected",
+			tags:          tags,
+			upsert:        true,
+			addFixture:    "testdata/tagging/add_tags_to_entity.json",
+			deleteFixture: "testdata/tagging/delete_tag_values_from_entity_error.json",
+			wantQuery: []string{
+				`tags { key values }`,
+				`taggingAddTagsToEntity(`,
+				`taggingDeleteTagValuesFromEntity(`,
+			},
+			wantErr: "deleting replaced tag values of NewRelic entity MXxBUE18QVBQTElDQVRJT058MQ failed: Tag key 'version' is reserved",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server, recorded := newFixtureServer(t, [][2]string{
+				{"taggingAddTagsToEntity", tt.addFixture},
+				{"taggingDeleteTagValuesFromEntity", tt.deleteFixture},
+				{"tags { key values }", "testdata/tagging/entity_tags.json"},
+			})
+
+			client := NewClient(server.Client(), server.URL, "NRAK-TEST", stdoutLogger{})
+			err := client.updateEntityTags(context.Background(), "MXxBUE18QVBQTElDQVRJT058MQ", tt.tags, tt.upsert)
+			if tt.wantErr == "" && err != nil {
+				t.Fatalf("updateEntityTags() error = %v", err)
+			}
+			if tt.wantErr != "" && (err == nil || err.Error() != tt.wantErr) {
+				t.Fatalf("updateEntityTags() error = %v, want %q", err, tt.wantErr)
+			}
+
+			// The current tags must have been fetched before upserting, and
+			// no tags of other keys must have been sent.
+			queries := recorded.all()
+			if len(queries) != len(tt.wantQuery) {
+				t.Fatalf("updateEntityTags() sent %d queries, want %d: %q", len(queries), len(tt.wantQuery), queries)
+			}
+			for i, want := range tt.wantQuery {
+				if !strings.Contains(queries[i], want) {
+					t.Errorf("query %d = %q, want it to contain %q", i, queries[i], want)
+				}
+			}
+		})
+	}
+}
+
+func TestGUIDRoundTrip(t *testing.T) {
+	tests := []struct {
+		name       string
+		guid       string
+		components GUIDComponents
+	}{
+		{
+			name:       "APM application",
+			guid:       "MXxBUE18QVBQTElDQVRJT058MQ",
+			components: GUIDComponents{AccountID: 1, Domain: "APM", EntityType: "APPLICATION", EntityID: "1"},
+		},
+		{
+			name:       "entity ID containing the separator",
+			guid:       "MzQ1Njc4OXxJTkZSQXxBV1NMQU1CREFGVU5DVElPTnx1cy1lYXN0LTF8Y2hlY2tvdXR8djI",
+			components: GUIDComponents{AccountID: 3456789, Domain: "INFRA", EntityType: "AWSLAMBDAFUNCTION", EntityID: "us-east-1|checkout|v2"},
+		},
+		{
+			name:       "entity ID ending with the separator",
+			guid:       "N3xFWFR8U0VSVklDRV9MRVZFTHxNamsyfA",
+			components: GUIDComponents{AccountID: 7, Domain: "EXT", EntityType: "SERVICE_LEVEL", EntityID: "Mjk2|"},
+		},
+		{
+			name:       "UUID entity ID",
+			guid:       "MTJ8U1lOVEh8TU9OSVRPUnxhMWIyYzNkNC1lNWY2LTc4OTAtYWJjZC1lZjAxMjM0NTY3ODk",
+			components: GUIDComponents{AccountID: 12, Domain: "SYNTH", EntityType: "MONITOR", EntityID: "a1b2c3d4-e5f6-7890-abcd-ef0123456789"},
+		},
+		{
+			name:       "non-ASCII entity ID",
+			guid:       "NDJ8QlJPV1NFUnxBUFBMSUNBVElPTnzDvG7Dr2NvZGU",
+			components: GUIDComponents{AccountID: 42, Domain: "BROWSER", EntityType: "APPLICATION", EntityID: "ünïcode"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// Encode(Decode(guid)) must return the GUID.
+			components, err := DecodeGUID(tt.guid)
+			if err != nil {
+				t.Fatalf("DecodeGUID() error = %v", err)
+			}
+			if components != tt.components {
+				t.Errorf("DecodeGUID() = %+v, want %+v", components, tt.components)
+			}
+			guid, err := EncodeGUID(components)
+			if err != nil {
+				t.Fatalf("EncodeGUID() error = %v", err)
+			}
+			if guid != tt.guid {
+				t.Errorf("EncodeGUID(DecodeGUID(%s)) = %s", tt.guid, guid)
+			}
+
+			// Decode(Encode(components)) must return the components.
+			guid, err = EncodeGUID(tt.components)
+			if err != nil {
+				t.Fatalf("EncodeGUID() error = %v", err)
+			}
+			if components, err = DecodeGUID(guid); err != nil || components != tt.components {
+				t.Errorf("DecodeGUID(EncodeGUID(%+v)) = %+v, %v", tt.components, components, err)
+			}
+		})
+	}
+}
+
+func TestDecodeGUIDPadded(t *testing.T) {
+	// Padded GUIDs are accepted and encoded without padding, like New Relic.
+	components, err := DecodeGUID("MXxBUE18QVBQTElDQVRJT058MQ==")
+	if err != nil {
+		t.Fatalf("DecodeGUID() error = %v", err)
+	}
+	if guid, err := EncodeGUID(components); err != nil || guid != "MXxBUE18QVBQTElDQVRJT058MQ" {
+		t.Errorf("EncodeGUID() = %s, %v, want MXxBUE18QVBQTElDQVRJT058MQ", guid, err)
+	}
+}
+
+func TestGUIDErrors(t *testing.T) {
+	for _, guid := range []string{"not base64!", "MXxBUE18QVBQTElDQVRJT04", "eHxBUE18QVBQTElDQVRJT058MQ"} {
+		t.Run("decode "+guid, func(t *testing.T) {
+			if _, err := DecodeGUID(guid); err == nil {
+				t.Errorf("DecodeGUID(%q) error = nil, want an error", guid)
+			}
+		})
+	}
+
+	for _, components := range []GUIDComponents{
+		{AccountID: 0, Domain: "APM", EntityType: "APPLICATION", EntityID: "1"},
+		{AccountID: 1, Domain: "", EntityType: "APPLICATION", EntityID: "1"},
+		{AccountID: 1, Domain: "APM|X", EntityType: "APPLICATION", EntityID: "1"},
+		{AccountID: 1, Domain: "APM", EntityType: "APP|LICATION", EntityID: "1"},
+		{AccountID: 1, Domain: "APM", EntityType: "APPLICATION", EntityID: ""},
+	} {
+		t.Run(fmt.Sprintf("encode %+v", components), func(t *testing.T) {
+			if _, err := EncodeGUID(components); err == nil {
+				t.Errorf("EncodeGUID(%+v) error = nil, want an error", components)
+			}
+		})
+	}
+}
+
+func TestDecodeGUID(t *testing.T) {
+	tests := []struct {
+		name    string
+		guid    string
+		want    GUIDComponents
+		wantErr bool
+	}{
+		{
+			name: "APM application",
+			guid: "MXxBUE18QVBQTElDQVRJT058MQ",
+			want: GUIDComponents{AccountID: 1, Domain: "APM", EntityType: "APPLICATION", EntityID: "1"},
+		},
+		{
+			name: "padded",
+			guid: "MXxBUE18QVBQTElDQVRJT058MQ==",
+			want: GUIDComponents{AccountID: 1, Domain: "APM", EntityType: "APPLICATION", EntityID: "1"},
+		},
+		{name: "not base64", guid: "not base64!", wantErr: true},
+		{name: "three components", guid: "MXxBUE18QVBQTElDQVRJT04", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := DecodeGUID(tt.guid)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("DecodeGUID() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Errorf("DecodeGUID() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestCheckEntityHealth(t *testing.T) {
+	tests := []struct {
+		name      string
+		summary   string
+		maxErrors float64
+		wantErr   bool
+	}{
+		{name: "healthy", summary: `{"data":{"actor":{"entity":{"apmSummary":{"errorRate":0.01}}}}}`, maxErrors: 5},
+		{name: "at the maximum", summary: `{"data":{"actor":{"entity":{"apmSummary":{"errorRate":0.05}}}}}`, maxErrors: 5},
+		{name: "unhealthy", summary: `{"data":{"actor":{"entity":{"apmSummary":{"errorRate":0.06}}}}}`, maxErrors: 5, wantErr: true},
+		{name: "no summary metrics", summary: `{"data":{"actor":{"entity":{"apmSummary":null}}}}`, maxErrors: 5, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var summary EntitySummary
+			if err := json.Unmarshal([]byte(tt.summary), &summary); err != nil {
+				t.Fatal(err)
+			}
+			if err := CheckEntityHealth(summary, tt.maxErrors); (err != nil) != tt.wantErr {
+				t.Errorf("CheckEntityHealth() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestGetApplicationGUID(t *testing.T) {
+	var graphqlResponse GraphQL
+	graphqlResponse.Data.Actor.EntitySearch.Results.Entities = []Entity{{GUID: "MXxBUE18QVBQTElDQVRJT058MQ"}}
+	guid, err := getApplicationGUID(graphqlResponse)
+	if err != nil || guid != "MXxBUE18QVBQTElDQVRJT058MQ" {
+		t.Errorf("getApplicationGUID() = %q, %v, want MXxBUE18QVBQTElDQVRJT058MQ", guid, err)
+	}
+}
+
+func TestGetEntitySLOs(t *testing.T) {
+	tests := []struct {
+		name           string
+		result         string
+		wantAttainment interface{}
+		wantErr        string
+	}{
+		{name: "attainment", result: "testdata/slo/result_attainment.json", wantAttainment: 99.8},
+		{name: "no data", result: "testdata/slo/result_no_data.json", wantAttainment: nil},
+		{name: "missing", result: "testdata/slo/result_missing.json", wantErr: "has no SLI value"},
+		{name: "not a number", result: "testdata/slo/result_not_a_number.json", wantErr: "is not a number"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server, recorded := newFixtureServer(t, [][2]string{
+				{"serviceLevel", "testdata/slo/indicators.json"},
+				{"nrql(query:", tt.result},
+			})
+			client := NewClient(server.Client(), server.URL, "NRAK-TEST", stdoutLogger{})
+
+			objectives, err := client.GetEntitySLOs(context.Background(), "MXxBUE18QVBQTElDQVRJT058MQ")
+			if tt.wantErr != "" {
+				if !errors.Is(err, ErrUnexpectedResponse) || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Errorf("GetEntitySLOs() error = %v, want ErrUnexpectedResponse containing %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("GetEntitySLOs() error = %v", err)
+			}
+			if len(objectives) != 1 {
+				t.Fatalf("GetEntitySLOs() returned %d objectives, want 1", len(objectives))
+			}
+			var attainment interface{}
+			if objectives[0].Attainment != nil {
+				attainment = *objectives[0].Attainment
+			}
+			if attainment != tt.wantAttainment || objectives[0].TimeWindow != "7 DAY" {
+				t.Errorf("GetEntitySLOs() attainment = %v over %s, want %v over 7 DAY", attainment, objectives[0].TimeWindow, tt.wantAttainment)
+			}
+
+			// The result query is run over the time window of the objective.
+			if queries := recorded.all(); len(queries) != 2 || !strings.Contains(queries[1], `SINCE 7 DAY AGO`) {
+				t.Errorf("GetEntitySLOs() sent %q, want the result query over 7 days", queries)
+			}
+		})
+	}
+}
@@ -0,0 +1,44 @@
+package main
+
+import (
+	"errors"
+
+	"github.com/zaljic/newrelic-guid-fetcher-action/pkg/newrelicguid"
+)
+
+// These errors describe the failure modes of the action. They are wrapped
+// with additional context where they occur, so callers should compare them
+// using errors.Is.
+var (
+	// This error is returned if the entity search did not return any entity.
+	ErrNoEntityFound = errors.New("no NewRelic entity found")
+
+	// This error is returned if the entity search returned more than one
+	// entity where only one was expected.
+	ErrMultipleEntitiesFound = errors.New("multiple NewRelic entities found")
+
+	// This error is returned if the app ID is not a numeric NewRelic app ID.
+	ErrInvalidAppID = errors.New("invalid NewRelic app ID")
+
+	// This error is returned if the NewRelic API rejected the API key.
+	ErrAuthenticationFailed = errors.New("NewRelic authentication failed")
+
+	// This error is returned if the NewRelic API rejected the request because
+	// too many requests have been sent.
+	ErrRateLimitExceeded = errors.New("NewRelic rate limit exceeded")
+
+	// This error is returned if the region is not supported by NewRelic.
+	ErrInvalidRegion = newrelicguid.ErrInvalidRegion
+
+	// This error is returned if the API key is not a user key, which is the only
+	// key type accepted by the NewRelic GraphQL API.
+	ErrWrongAPIKeyType = errors.New("NewRelic API key is not a user key")
+
+	// This error is returned if the NewRelic API responded with GraphQL errors.
+	ErrGraphQLError = errors.New("NewRelic GraphQL error")
+
+	// This error is returned if the response of an entity search does not
+	// have the structure the action expects, e.g. because the NerdGraph schema
+	// has changed.
+	ErrUnexpectedResponse = errors.New("unexpected NewRelic API response")
+)
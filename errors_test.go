@@ -0,0 +1,143 @@
+package main
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestGetGUIDErrors(t *testing.T) {
+	tests := []struct {
+		name       string
+		statusCode int
+		body       string
+		want       error
+	}{
+		{name: "unauthorized", statusCode: http.StatusUnauthorized, want: ErrAuthenticationFailed},
+		{name: "forbidden", statusCode: http.StatusForbidden, want: ErrAuthenticationFailed},
+		{name: "rate limited", statusCode: http.StatusTooManyRequests, want: ErrRateLimitExceeded},
+		{name: "GraphQL error", statusCode: http.StatusOK, body: `{"errors":[{"message":"Invalid query"}]}`, want: ErrGraphQLError},
+		{name: "unexpected response", statusCode: http.StatusOK, body: `{"data":{"actor":{"search":{}}}}`, want: ErrUnexpectedResponse},
+		{name: "malformed response", statusCode: http.StatusOK, body: `{"data":{"actor":{"entitySearch":[]}}}`, want: ErrUnexpectedResponse},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				w.WriteHeader(tt.statusCode)
+				w.Write([]byte(tt.body))
+			}))
+			defer server.Close()
+
+			_, err := getGUID(server.Client(), "NRAK-TEST", server.URL, "domainId = '1'")
+			if !errors.Is(err, tt.want) {
+				t.Errorf("getGUID() error = %v, want %v", err, tt.want)
+			}
+		})
+	}
+}
+
+func TestGetApplicationEntityErrors(t *testing.T) {
+	tests := []struct {
+		name     string
+		entities []Entity
+		want     error
+	}{
+		{name: "no entity", want: ErrNoEntityFound},
+		{name: "one entity", entities: []Entity{{GUID: "MXxBUE18QVBQTElDQVRJT058MQ"}}},
+		{name: "multiple entities", entities: []Entity{{GUID: "MXxBUE18QVBQTElDQVRJT058MQ"}, {GUID: "MXxBUE18QVBQTElDQVRJT058Mg"}}, want: ErrMultipleEntitiesFound},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var graphqlResponse GraphQL
+			graphqlResponse.Data.Actor.EntitySearch.Results.Entities = tt.entities
+			_, err := getApplicationEntity(graphqlResponse)
+			if tt.want == nil && err != nil {
+				t.Fatalf("getApplicationEntity() error = %v", err)
+			}
+			if !errors.Is(err, tt.want) {
+				t.Errorf("getApplicationEntity() error = %v, want %v", err, tt.want)
+			}
+		})
+	}
+}
+
+func TestValidateAPIKeyErrors(t *testing.T) {
+	tests := []struct {
+		name         string
+		key          string
+		wantKeyType  KeyType
+		wantWrongKey bool
+	}{
+		{name: "user key", key: "NRAK-0123456789ABCDEFGHIJKLMNOPQ", wantKeyType: KeyTypeUser},
+		{name: "browser key", key: "NRJS-0123456789abcdef012", wantKeyType: KeyTypeBrowser, wantWrongKey: true},
+		{name: "insights insert key", key: "NRII-0123456789abcdef0123456789abcdef", wantKeyType: KeyTypeInsightsInsert, wantWrongKey: true},
+		{name: "license key", key: "0123456789abcdef0123456789abcdef0123NRAL", wantKeyType: KeyTypeLicense, wantWrongKey: true},
+		{name: "hexadecimal license key", key: "0123456789abcdef0123456789abcdef01234567", wantKeyType: KeyTypeLicense, wantWrongKey: true},
+		{name: "unknown key", key: "NRXX-future-key-format", wantKeyType: KeyTypeUnknown},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			keyType, err := ValidateAPIKey(tt.key)
+			if keyType != tt.wantKeyType {
+				t.Errorf("ValidateAPIKey() key type = %q, want %q", keyType, tt.wantKeyType)
+			}
+			if errors.Is(err, ErrWrongAPIKeyType) != tt.wantWrongKey {
+				t.Errorf("ValidateAPIKey() error = %v, want ErrWrongAPIKeyType %v", err, tt.wantWrongKey)
+			}
+		})
+	}
+}
+
+func TestValidateRegionErrors(t *testing.T) {
+	tests := []struct {
+		region string
+		want   error
+	}{
+		{region: "US"},
+		{region: "eu"},
+		{region: "GOV"},
+		{region: "APAC", want: ErrInvalidRegion},
+		{region: "U S", want: ErrInvalidRegion},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.region, func(t *testing.T) {
+			t.Setenv("INPUT_NEWRELICREGION", tt.region)
+			err := validateRegion(&Config{})
+			if tt.want == nil && err != nil {
+				t.Fatalf("validateRegion() error = %v", err)
+			}
+			if !errors.Is(err, tt.want) {
+				t.Errorf("validateRegion() error = %v, want %v", err, tt.want)
+			}
+		})
+	}
+}
+
+func TestValidateAppIDFormatErrors(t *testing.T) {
+	tests := []struct {
+		appID string
+		want  error
+	}{
+		{appID: "123"},
+		{appID: "123,456"},
+		{appID: "abc", want: ErrInvalidAppID},
+		{appID: "123,-1", want: ErrInvalidAppID},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.appID, func(t *testing.T) {
+			err := validateAppIDFormat(&Config{AppID: tt.appID})
+			if tt.want == nil && err != nil {
+				t.Fatalf("validateAppIDFormat() error = %v", err)
+			}
+			if !errors.Is(err, tt.want) {
+				t.Errorf("validateAppIDFormat() error = %v, want %v", err, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,58 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+)
+
+// This function fetches the feature flags associated with the entity with the
+// given GUID from the given feature flag service. The GUID is sent as guid
+// query parameter, and the service is expected to respond with a JSON object
+// mapping the keys of the flags to their values, e.g. {"new-checkout": true}.
+// If a token is given, it is sent as bearer token.
+func GetEntityFeatureFlags(ctx context.Context, client HTTPDoer, serviceURL string, token string, guid string) (map[string]interface{}, error) {
+	// Add the GUID to the query parameters of the service URL.
+	requestURL, err := url.Parse(serviceURL)
+	if err != nil {
+		return nil, err
+	}
+	query := requestURL.Query()
+	query.Set("guid", guid)
+	requestURL.RawQuery = query.Encode()
+
+	// Create a HTTP GET request for the flags of the entity.
+	req, err := http.NewRequestWithContext(ctx, "GET", requestURL.String(), nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Accept", "application/json")
+	if token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+
+	// Send the HTTP request using the given client.
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	// Return an error if the HTTP status code is not 200.
+	if resp.StatusCode != 200 {
+		return nil, fmt.Errorf("fetching feature flags of NewRelic entity %s: HTTP status code %d", guid, resp.StatusCode)
+	}
+
+	// Unmarshal the flags.
+	flags := map[string]interface{}{}
+	if err := json.NewDecoder(resp.Body).Decode(&flags); err != nil {
+		return nil, fmt.Errorf("fetching feature flags of NewRelic entity %s: %w", guid, err)
+	}
+	if flags == nil {
+		flags = map[string]interface{}{}
+	}
+
+	return flags, nil
+}
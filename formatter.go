@@ -0,0 +1,131 @@
+package main
+
+import (
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// This interface describes a format the resolved entities can be written in.
+// The first entity is the application entity. The OutputFile of the given
+// config is the file the formatted entities are written to, or empty if they
+// are printed to stdout, see writeEntityOutputs.
+type Formatter interface {
+	Format(entities []Entity, cfg Config) ([]byte, error)
+}
+
+// This map holds the formatters of the formats supported by the output_format
+// input parameter, keyed by the name of the format. A new format only needs to
+// be added here.
+var outputFormatters = map[string]Formatter{
+	"gha":   GHAFormatter{},
+	"json":  JSONFormatter{},
+	"csv":   CSVFormatter{},
+	"shell": ShellFormatter{},
+}
+
+// This constant holds the name of the output format that writes a Kubernetes
+// ConfigMap to the output file in addition to the output parameters of the
+// action. It is not a Formatter, see writeConfigMapFile.
+const configMapOutputFormat = "k8s-configmap"
+
+// This function returns the names of the supported output formats, including
+// the k8s-configmap format, in alphabetical order.
+func sortedOutputFormats() []string {
+	names := []string{configMapOutputFormat}
+	for name := range outputFormatters {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// This struct formats the resolved entities as the output parameters of the
+// action.
+type GHAFormatter struct{}
+
+// This function formats the output parameters of the resolved entities as
+// workflow commands, which set them when printed to stdout. If an output file
+// is specified, e.g. the GITHUB_OUTPUT or GITHUB_ENV file, they are formatted
+// as name=value lines instead, and values containing a line break are written
+// with a delimiter.
+func (GHAFormatter) Format(entities []Entity, cfg Config) ([]byte, error) {
+	parameters, err := entityOutputParameters(cfg, entities)
+	if err != nil {
+		return nil, err
+	}
+
+	var output bytes.Buffer
+	for _, parameter := range parameters {
+		switch {
+		case cfg.OutputFile == "":
+			output.WriteString(formatOutput(parameter[0], parameter[1]))
+		case strings.ContainsAny(parameter[1], "\r\n"):
+			fmt.Fprintf(&output, "%s<<NEWRELIC_GUID_FETCHER_EOF\n%s\nNEWRELIC_GUID_FETCHER_EOF\n", parameter[0], parameter[1])
+		default:
+			fmt.Fprintf(&output, "%s=%s\n", parameter[0], parameter[1])
+		}
+	}
+	return output.Bytes(), nil
+}
+
+// This struct formats the entities as an indented JSON list.
+type JSONFormatter struct{}
+
+// This function formats the entities as an indented JSON list.
+func (JSONFormatter) Format(entities []Entity, cfg Config) ([]byte, error) {
+	data, err := json.MarshalIndent(entities, "", "  ")
+	if err != nil {
+		return nil, err
+	}
+	return append(data, '\n'), nil
+}
+
+// This struct formats the entities as CSV with a header row.
+type CSVFormatter struct{}
+
+// This function formats the entities as CSV with one row per entity, after a
+// header row naming the columns.
+func (CSVFormatter) Format(entities []Entity, cfg Config) ([]byte, error) {
+	var output bytes.Buffer
+	writer := csv.NewWriter(&output)
+	writer.Write([]string{"accountId", "entityType", "name", "guid"})
+	for _, entity := range entities {
+		writer.Write([]string{strconv.Itoa(entity.AccountID), entity.EntityType, entity.Name, entity.GUID})
+	}
+	writer.Flush()
+	return output.Bytes(), writer.Error()
+}
+
+// This struct formats the application entity as shell export statements,
+// which can be sourced by a shell script.
+type ShellFormatter struct{}
+
+// This function formats the application entity as export statements of
+// NEWRELIC_* variables. If more than one entity has been resolved, the GUIDs
+// of all entities are exported as well, joined by multi_value_delimiter.
+func (ShellFormatter) Format(entities []Entity, cfg Config) ([]byte, error) {
+	// Quote a value so that the shell does not expand it.
+	quote := func(value string) string {
+		return "'" + strings.ReplaceAll(value, "'", `'\''`) + "'"
+	}
+
+	entity := entities[0]
+	var output bytes.Buffer
+	fmt.Fprintf(&output, "export NEWRELIC_GUID=%s\n", quote(entity.GUID))
+	fmt.Fprintf(&output, "export NEWRELIC_ENTITY_NAME=%s\n", quote(entity.Name))
+	fmt.Fprintf(&output, "export NEWRELIC_ENTITY_TYPE=%s\n", quote(entity.EntityType))
+	fmt.Fprintf(&output, "export NEWRELIC_ACCOUNT_ID=%s\n", quote(strconv.Itoa(entity.AccountID)))
+	if len(entities) > 1 {
+		var guids []string
+		for _, entity := range entities {
+			guids = append(guids, entity.GUID)
+		}
+		fmt.Fprintf(&output, "export NEWRELIC_GUIDS=%s\n", quote(strings.Join(guids, cfg.MultiValueDelimiter)))
+	}
+	return output.Bytes(), nil
+}
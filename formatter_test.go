@@ -0,0 +1,203 @@
+package main
+
+import (
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/zaljic/newrelic-guid-fetcher-action/pkg/newrelicguid"
+)
+
+// This variable holds the entities formatted by the tests, the first one being
+// the application entity.
+var formatterTestEntities = []Entity{
+	{AccountID: 1, EntityType: "APM_APPLICATION_ENTITY", Name: "checkout", GUID: "MXxBUE18QVBQTElDQVRJT058MQ"},
+	{AccountID: 1, EntityType: "APM_APPLICATION_ENTITY", Name: "checkout's worker", GUID: "MXxBUE18QVBQTElDQVRJT058Mg"},
+}
+
+func TestFormatters(t *testing.T) {
+	tests := []struct {
+		name       string
+		format     string
+		outputFile string
+		want       []string
+	}{
+		{
+			name:   "gha",
+			format: "gha",
+			want: []string{
+				"::set-output name=appGUID::MXxBUE18QVBQTElDQVRJT058MQ\n",
+				`::set-output name=entityJSON::{"schemaVersion":1,"accountId":1,`,
+				"::set-output name=permalink::https://",
+			},
+		},
+		{
+			name:       "gha to output file",
+			format:     "gha",
+			outputFile: "github_output",
+			want: []string{
+				"appGUID=MXxBUE18QVBQTElDQVRJT058MQ\n",
+				`entityJSON={"schemaVersion":1,"accountId":1,`,
+			},
+		},
+		{
+			name:   "json",
+			format: "json",
+			want:   []string{"[\n  {\n    \"accountId\": 1,", `"guid": "MXxBUE18QVBQTElDQVRJT058Mg"`},
+		},
+		{
+			name:   "csv",
+			format: "csv",
+			want:   []string{"accountId,entityType,name,guid\n1,APM_APPLICATION_ENTITY,checkout,MXxBUE18QVBQTElDQVRJT058MQ\n"},
+		},
+		{
+			name:   "shell",
+			format: "shell",
+			want: []string{
+				"export NEWRELIC_GUID='MXxBUE18QVBQTElDQVRJT058MQ'\n",
+				`export NEWRELIC_GUIDS='MXxBUE18QVBQTElDQVRJT058MQ,MXxBUE18QVBQTElDQVRJT058Mg'`,
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := Config{OutputFormat: tt.format, OutputFile: tt.outputFile, MultiValueDelimiter: ",", PermalinkType: newrelicguid.PageSummary}
+			data, err := outputFormatters[tt.format].Format(formatterTestEntities, cfg)
+			if err != nil {
+				t.Fatalf("Format() error = %v", err)
+			}
+			for _, want := range tt.want {
+				if !strings.Contains(string(data), want) {
+					t.Errorf("Format() = %q, want it to contain %q", data, want)
+				}
+			}
+		})
+	}
+}
+
+// This function calls the given function and returns everything it printed to
+// stdout.
+func captureStdout(t *testing.T, f func()) string {
+	t.Helper()
+
+	reader, writer, err := os.Pipe()
+	if err != nil {
+		t.Fatal(err)
+	}
+	stdout := os.Stdout
+	os.Stdout = writer
+	defer func() { os.Stdout = stdout }()
+
+	// Read the pipe concurrently, so that large outputs do not block.
+	output := make(chan string)
+	go func() {
+		data, _ := io.ReadAll(reader)
+		output <- string(data)
+	}()
+	f()
+	writer.Close()
+	return <-output
+}
+
+func TestWriteEntityOutputsToOutputFile(t *testing.T) {
+	tests := []struct {
+		name          string
+		format        string
+		wantFile      string
+		wantStdout    string
+		notWantStdout string
+	}{
+		{
+			name:          "gha",
+			format:        "gha",
+			wantFile:      "appGUID=MXxBUE18QVBQTElDQVRJT058MQ\n",
+			notWantStdout: "::set-output",
+		},
+		{
+			name:          "csv",
+			format:        "csv",
+			wantFile:      "accountId,entityType,name,guid\n",
+			wantStdout:    "::set-output name=appGUID::MXxBUE18QVBQTElDQVRJT058MQ\n",
+			notWantStdout: "accountId,entityType,name,guid",
+		},
+		{
+			name:          "k8s-configmap",
+			format:        "k8s-configmap",
+			wantFile:      "kind: ConfigMap\n",
+			wantStdout:    "::set-output name=appGUID::MXxBUE18QVBQTElDQVRJT058MQ\n",
+			notWantStdout: "appGUID=",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := Config{OutputFormat: tt.format, OutputFile: filepath.Join(t.TempDir(), "output"), ConfigMapName: "newrelic-entity", PermalinkType: newrelicguid.PageSummary}
+			var err error
+			stdout := captureStdout(t, func() {
+				if err = writeEntityOutputs(cfg, formatterTestEntities); err == nil && tt.format == configMapOutputFormat {
+					err = writeConfigMapFile(cfg, formatterTestEntities)
+				}
+			})
+			if err != nil {
+				t.Fatalf("writeEntityOutputs() error = %v", err)
+			}
+
+			// The ConfigMap must not be overwritten by the output parameters.
+			data, err := os.ReadFile(cfg.OutputFile)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !strings.Contains(string(data), tt.wantFile) {
+				t.Errorf("output file = %q, want it to contain %q", data, tt.wantFile)
+			}
+
+			// The output parameters must be set whatever the output format.
+			if !strings.Contains(stdout, tt.wantStdout) {
+				t.Errorf("stdout = %q, want it to contain %q", stdout, tt.wantStdout)
+			}
+			if strings.Contains(stdout, tt.notWantStdout) {
+				t.Errorf("stdout = %q, want it not to contain %q", stdout, tt.notWantStdout)
+			}
+		})
+	}
+}
+
+func TestWriteEntityOutputsToStdout(t *testing.T) {
+	cfg := Config{OutputFormat: "json", PermalinkType: newrelicguid.PageSummary}
+	var err error
+	stdout := captureStdout(t, func() {
+		err = writeEntityOutputs(cfg, formatterTestEntities)
+	})
+	if err != nil {
+		t.Fatalf("writeEntityOutputs() error = %v", err)
+	}
+	for _, want := range []string{"::set-output name=appGUID::MXxBUE18QVBQTElDQVRJT058MQ\n", "[\n  {\n    \"accountId\": 1,"} {
+		if !strings.Contains(stdout, want) {
+			t.Errorf("stdout = %q, want it to contain %q", stdout, want)
+		}
+	}
+}
+
+func TestValidateOutputFile(t *testing.T) {
+	tests := []struct {
+		format  string
+		wantErr bool
+	}{
+		{format: ""},
+		{format: "gha"},
+		{format: "json"},
+		{format: "k8s-configmap", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.format, func(t *testing.T) {
+			err := validateOutputFile(&Config{OutputFormat: tt.format})
+			if (err != nil) != tt.wantErr {
+				t.Errorf("validateOutputFile() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
@@ -0,0 +1,131 @@
+package main
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"regexp"
+	"sync"
+)
+
+// This variable holds the pattern of the full resource name of a version of a
+// GCP Secret Manager secret.
+var gcpSecretNamePattern = regexp.MustCompile(`^projects/[^/]+/secrets/[^/]+/versions/[^/]+$`)
+
+// This variable holds the values of the GCP secrets fetched during the run,
+// keyed by resource name, so that each secret is only fetched once.
+var gcpSecretCache = struct {
+	sync.Mutex
+	values map[string]string
+}{values: map[string]string{}}
+
+// This function fetches the value of the version of the GCP Secret Manager
+// secret with the given resource name, e.g.
+// projects/my-project/secrets/newrelic-api-key/versions/latest. The access
+// token is requested from the metadata server of the runner's VM, so the
+// service account of the VM must be allowed to access the secret. The value
+// is cached for the rest of the run.
+func fetchGCPSecret(ctx context.Context, client HTTPDoer, name string) (string, error) {
+	// Return an error if the resource name is not the name of a secret version.
+	if !gcpSecretNamePattern.MatchString(name) {
+		return "", fmt.Errorf("invalid GCP secret %q, expected projects/*/secrets/*/versions/*", name)
+	}
+
+	// Return the cached value if the secret has already been fetched.
+	gcpSecretCache.Lock()
+	defer gcpSecretCache.Unlock()
+	if value, ok := gcpSecretCache.values[name]; ok {
+		return value, nil
+	}
+
+	// Request an access token for the service account of the VM.
+	accessToken, err := fetchGCPAccessToken(ctx, client)
+	if err != nil {
+		return "", err
+	}
+
+	// Create a HTTP GET request accessing the secret version.
+	req, err := http.NewRequestWithContext(ctx, "GET", "https://secretmanager.googleapis.com/v1/"+name+":access", nil)
+	if err != nil {
+		return "", err
+	}
+	req.Header.Set("Authorization", "Bearer "+accessToken)
+
+	// Send the HTTP request using the given client.
+	resp, err := client.Do(req)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+
+	// Return an error if the HTTP status code is not 200.
+	if resp.StatusCode != 200 {
+		return "", fmt.Errorf("fetching GCP secret %s: HTTP status code %d", name, resp.StatusCode)
+	}
+
+	// Unmarshal the secret, whose payload is base64-encoded.
+	var secret struct {
+		Payload struct {
+			Data string `json:"data"`
+		} `json:"payload"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&secret); err != nil {
+		return "", err
+	}
+	data, err := base64.StdEncoding.DecodeString(secret.Payload.Data)
+	if err != nil {
+		return "", fmt.Errorf("decoding GCP secret %s: %w", name, err)
+	}
+	if len(data) == 0 {
+		return "", fmt.Errorf("GCP secret %s is empty", name)
+	}
+
+	gcpSecretCache.values[name] = string(data)
+	return string(data), nil
+}
+
+// This function requests an access token for the default service account of
+// the VM from the GCP metadata server. The host of the metadata server can be
+// overridden with the GCE_METADATA_HOST environment variable.
+func fetchGCPAccessToken(ctx context.Context, client HTTPDoer) (string, error) {
+	host := os.Getenv("GCE_METADATA_HOST")
+	if host == "" {
+		host = "metadata.google.internal"
+	}
+
+	// Create a HTTP GET request for the token. The metadata server only
+	// responds to requests with the Metadata-Flavor header.
+	req, err := http.NewRequestWithContext(ctx, "GET", "http://"+host+"/computeMetadata/v1/instance/service-accounts/default/token", nil)
+	if err != nil {
+		return "", err
+	}
+	req.Header.Set("Metadata-Flavor", "Google")
+
+	// Send the HTTP request using the given client.
+	resp, err := client.Do(req)
+	if err != nil {
+		return "", fmt.Errorf("requesting GCP access token: %w", err)
+	}
+	defer resp.Body.Close()
+
+	// Return an error if the HTTP status code is not 200.
+	if resp.StatusCode != 200 {
+		return "", fmt.Errorf("requesting GCP access token: HTTP status code %d", resp.StatusCode)
+	}
+
+	// Unmarshal the access token.
+	var token struct {
+		AccessToken string `json:"access_token"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&token); err != nil {
+		return "", err
+	}
+	if token.AccessToken == "" {
+		return "", fmt.Errorf("requesting GCP access token: no access token returned")
+	}
+
+	return token.AccessToken, nil
+}
@@ -0,0 +1,68 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"strings"
+)
+
+// This constant holds the context of the commit statuses created by the
+// action, which identifies them among the other statuses of a commit.
+const commitStatusContext = "newrelic-guid-fetcher"
+
+// This function creates a successful commit status with the GUID of the given
+// entity on the commit the workflow runs for, so that the commit is linked to
+// the entity in the history of the repository. The status links to the given
+// URL. The repository and commit are taken from the GITHUB_REPOSITORY and
+// GITHUB_SHA environment variables set by GitHub Actions, the API URL from
+// GITHUB_API_URL, which is set on GitHub Enterprise Server.
+func createCommitStatus(ctx context.Context, client HTTPDoer, githubToken string, entity Entity, targetURL string) error {
+	repository := os.Getenv("GITHUB_REPOSITORY")
+	sha := os.Getenv("GITHUB_SHA")
+	if repository == "" || sha == "" {
+		return fmt.Errorf("creating commit status: GITHUB_REPOSITORY and GITHUB_SHA must be set")
+	}
+	apiURL := strings.TrimSuffix(os.Getenv("GITHUB_API_URL"), "/")
+	if apiURL == "" {
+		apiURL = "https://api.github.com"
+	}
+
+	// Marshal the commit status. The description of a commit status is limited
+	// to 140 characters, which the GUID always fits in.
+	data, err := json.Marshal(map[string]string{
+		"state":       "success",
+		"context":     commitStatusContext,
+		"description": "NewRelic entity GUID: " + entity.GUID,
+		"target_url":  targetURL,
+	})
+	if err != nil {
+		return err
+	}
+
+	// Create a HTTP POST request to the statuses endpoint of the commit.
+	req, err := http.NewRequestWithContext(ctx, "POST", fmt.Sprintf("%s/repos/%s/statuses/%s", apiURL, repository, sha), bytes.NewReader(data))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Authorization", "Bearer "+githubToken)
+	req.Header.Set("Accept", "application/vnd.github+json")
+	req.Header.Set("Content-Type", "application/json")
+
+	// Send the HTTP request using the given client.
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	// Return an error if the commit status has not been created.
+	if resp.StatusCode != http.StatusCreated {
+		return fmt.Errorf("creating commit status on %s: HTTP status code %d", sha, resp.StatusCode)
+	}
+
+	return nil
+}
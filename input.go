@@ -0,0 +1,720 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/url"
+	"os"
+	"regexp"
+	"strconv"
+	"strings"
+	"text/template"
+
+	"github.com/zaljic/newrelic-guid-fetcher-action/pkg/newrelicguid"
+)
+
+// This type is a step of the pipeline the input parameters are parsed and
+// validated by. Each step checks a single concern, e.g. the format of the app
+// IDs, and stores the parsed value in the config.
+type InputValidator func(*Config) error
+
+// This type holds the errors of all steps of a pipeline that failed, so that
+// all invalid input parameters are reported at once instead of one per run.
+type ValidationErrors []error
+
+// This function returns the error of a single failed step as is, and the
+// errors of several failed steps one per line.
+func (e ValidationErrors) Error() string {
+	if len(e) == 1 {
+		return e[0].Error()
+	}
+	messages := make([]string, len(e))
+	for i, err := range e {
+		messages[i] = err.Error()
+	}
+	return fmt.Sprintf("%d input parameters are invalid:\n  %s", len(e), strings.Join(messages, "\n  "))
+}
+
+// This function returns the errors of the failed steps, so that they can be
+// compared using errors.Is.
+func (e ValidationErrors) Unwrap() []error {
+	return e
+}
+
+// This variable holds the steps NewConfig parses and validates the input
+// parameters with, in the order they are applied.
+var inputValidators = []InputValidator{
+	validateTelemetry,
+	validateAPIKeyFormat,
+	validateRegion,
+	validatePermalinkType,
+	validatePermalinkFormat,
+	validateAppIDFormat,
+	validateEntityDomainType,
+	validateEntitySynthesisRuleType,
+	validateAccountID,
+	validateCrossAccountIDs,
+	validateFeatureFlagService,
+	validateCompareGUID,
+	validateMaxEntityAge,
+	validateMaxResponseBodyBytes,
+	validateQueryTimeout,
+	validateRequestsPerSecond,
+	validateBatchConcurrency,
+	validateMaxQueryComplexity,
+	validateEntitySearchLimit,
+	validateEntitySearchSortBy,
+	validateGraphQLOperationName,
+	validateAcceptEncoding,
+	validateOutputFormat,
+	validateSetTags,
+	validatePersistGUIDAsAnnotation,
+	validateSlackMessage,
+	validateGraphQLVariables,
+	validateMaxErrorRate,
+	validateMaxJSErrorRate,
+	validateMaxIngestGBWarning,
+	validateMinSLOAttainment,
+	validateSLOPeriodDays,
+	validateNRQLTimeRange,
+}
+
+// This variable holds the steps Validate checks the input parameters required
+// to fetch a GUID with, in the order they are applied.
+var searchValidators = []InputValidator{
+	validateSearchInput,
+	validateFullTextSearch,
+	validateWorkloadSearch,
+	validateClusterSearch,
+	validateKubernetesNamespace,
+	validateBatchMode,
+	validateNRQLQuery,
+	validateOutputFile,
+}
+
+// This function applies the given steps to the config in order. All steps are
+// applied even if one fails, and the errors of all failed steps are returned
+// as ValidationErrors.
+func applyValidators(config *Config, validators []InputValidator) error {
+	var errs ValidationErrors
+	for _, validate := range validators {
+		if err := validate(config); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	if len(errs) > 0 {
+		return errs
+	}
+	return nil
+}
+
+// This function returns an error if telemetry is enabled but no endpoint is
+// specified.
+func validateTelemetry(config *Config) error {
+	if config.TelemetryEnabled && config.TelemetryEndpoint == "" {
+		return errors.New("Telemetry endpoint not specified.")
+	}
+	return nil
+}
+
+// This function returns an error if the API key specified directly is not a
+// user key. Keys read from other sources are checked once they have been
+// resolved.
+func validateAPIKeyFormat(config *Config) error {
+	if config.KeySources.Key != "" {
+		if _, err := ValidateAPIKey(config.KeySources.Key); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// This function sets the NewRelic GraphQL endpoint based on the region
+// specified in the newrelicRegion input parameter.
+func validateRegion(config *Config) error {
+	region, err := newrelicguid.ParseRegion(os.Getenv("INPUT_NEWRELICREGION"))
+	if err != nil {
+		return err
+	}
+	config.Region = region
+	config.Endpoint = resolveEndpoint(region)
+	return nil
+}
+
+// This function parses the page of the NewRelic UI the permalink output
+// parameter links to, which defaults to the summary page of the entity.
+func validatePermalinkType(config *Config) error {
+	permalinkTypeInput := os.Getenv("INPUT_PERMALINK_TYPE")
+	if permalinkTypeInput == "" {
+		permalinkTypeInput = string(newrelicguid.PageSummary)
+	}
+	permalinkType, err := newrelicguid.ParsePageType(permalinkTypeInput)
+	if err != nil {
+		return err
+	}
+	config.PermalinkType = permalinkType
+	return nil
+}
+
+// This function parses the optional template of the entityPermalink output
+// parameter and renders it once with sample data, so that unknown fields are
+// reported before the NewRelic API is called.
+func validatePermalinkFormat(config *Config) error {
+	permalinkFormatInput := os.Getenv("INPUT_ENTITY_PERMALINK_FORMAT")
+	if permalinkFormatInput == "" {
+		return nil
+	}
+	permalinkTemplate, err := template.New("entity_permalink_format").Option("missingkey=error").Parse(permalinkFormatInput)
+	if err == nil {
+		err = permalinkTemplate.Execute(io.Discard, permalinkData{})
+	}
+	if err != nil {
+		return fmt.Errorf("Invalid entity permalink format specified: %w", err)
+	}
+	config.PermalinkTemplate = permalinkTemplate
+	return nil
+}
+
+// This function splits the comma-separated list of app IDs and returns an
+// error if an app ID is not a numeric NewRelic app ID. If more than one app ID
+// is specified, the app IDs are resolved in batch mode.
+func validateAppIDFormat(config *Config) error {
+	config.AppIDs = splitAppIDs(config.AppID)
+	if len(config.AppIDs) == 1 {
+		config.AppID = config.AppIDs[0]
+	}
+
+	for _, appID := range config.AppIDs {
+		if _, err := strconv.ParseUint(appID, 10, 64); err != nil {
+			return fmt.Errorf("%w: %q", ErrInvalidAppID, appID)
+		}
+	}
+	return nil
+}
+
+// This function splits the domain and type the entity search is narrowed down
+// to.
+func validateEntityDomainType(config *Config) error {
+	entityDomainType := os.Getenv("INPUT_ENTITY_DOMAIN_TYPE")
+	if entityDomainType == "" {
+		return nil
+	}
+	var ok bool
+	config.EntityDomain, config.EntityType, ok = strings.Cut(entityDomainType, "/")
+	if !ok || config.EntityDomain == "" || config.EntityType == "" || strings.Contains(config.EntityType, "/") {
+		return errors.New("Invalid entity domain type specified, expected DOMAIN/TYPE.")
+	}
+	return nil
+}
+
+// This variable holds the DOMAIN_TYPE pattern entity types follow, e.g.
+// APM_APPLICATION_ENTITY or EXT_SERVICE.
+var entityTypePattern = regexp.MustCompile(`^[A-Z0-9]+(_[A-Z0-9]+)+$`)
+
+// This function parses the optional custom entity type defined by an entity
+// synthesis rule. It is passed to the entity search as is, so that entity
+// types added to NewRelic after this action work as well. A warning is
+// printed if the type does not follow the DOMAIN_TYPE pattern.
+func validateEntitySynthesisRuleType(config *Config) error {
+	entityType := strings.TrimSpace(os.Getenv("INPUT_ENTITY_SYNTHESIS_RULE_TYPE"))
+	if entityType == "" {
+		return nil
+	}
+	if os.Getenv("INPUT_ENTITY_DOMAIN_TYPE") != "" {
+		return errors.New("A custom entity type can not be combined with an entity domain type.")
+	}
+	if !entityTypePattern.MatchString(entityType) {
+		fmt.Printf("::warning::The entity type %s does not follow the DOMAIN_TYPE pattern, the entity search may not match any entity\n", entityType)
+	}
+	config.EntityType = entityType
+	return nil
+}
+
+// This function parses the optional account ID the entity must be reported
+// in.
+func validateAccountID(config *Config) error {
+	accountIDInput := os.Getenv("INPUT_NEWRELICACCOUNTID")
+	if accountIDInput == "" {
+		return nil
+	}
+	value, err := strconv.Atoi(accountIDInput)
+	if err != nil || value <= 0 {
+		return errors.New("Invalid NewRelic account ID specified.")
+	}
+	config.AccountID = value
+	return nil
+}
+
+// This function parses the comma-separated list of accounts the cross-account
+// dependencies of the entity are fetched from, which is required if the
+// fetch_cross_account_deps input parameter is set.
+func validateCrossAccountIDs(config *Config) error {
+	for _, accountID := range splitAppIDs(os.Getenv("INPUT_CROSS_ACCOUNT_IDS")) {
+		value, err := strconv.Atoi(accountID)
+		if err != nil || value <= 0 {
+			return fmt.Errorf("Invalid cross-account ID %q specified.", accountID)
+		}
+		config.CrossAccountIDs = append(config.CrossAccountIDs, value)
+	}
+	if config.FetchCrossAccountDeps && len(config.CrossAccountIDs) == 0 {
+		return errors.New("Cross-account IDs not specified, they are required to fetch cross-account dependencies.")
+	}
+	return nil
+}
+
+// This function returns an error if the feature flags are to be fetched
+// without the URL of the feature flag service, or if the URL is not an HTTP
+// URL.
+func validateFeatureFlagService(config *Config) error {
+	if !config.FetchFeatureFlags {
+		return nil
+	}
+	serviceURL, err := url.Parse(config.FeatureFlagServiceURL)
+	if err != nil || (serviceURL.Scheme != "https" && serviceURL.Scheme != "http") || serviceURL.Host == "" {
+		return errors.New("Invalid feature flag service URL specified, expected an http or https URL.")
+	}
+	return nil
+}
+
+// This function returns an error if the GUID of the entity the settings of
+// the entity are compared with is not a NewRelic GUID.
+func validateCompareGUID(config *Config) error {
+	if config.CompareGUID == "" {
+		return nil
+	}
+	if _, err := DecodeGUID(config.CompareGUID); err != nil {
+		return fmt.Errorf("Invalid compare GUID specified: %w", err)
+	}
+	return nil
+}
+
+// This function parses the optional maximum number of hours since the entity
+// stopped reporting.
+func validateMaxEntityAge(config *Config) error {
+	maxEntityAgeInput := os.Getenv("INPUT_MAX_ENTITY_AGE_HOURS")
+	if maxEntityAgeInput == "" {
+		return nil
+	}
+	value, err := strconv.Atoi(maxEntityAgeInput)
+	if err != nil || value < 1 {
+		return errors.New("Invalid maximum entity age specified.")
+	}
+	config.MaxEntityAgeHours = value
+	return nil
+}
+
+// This function parses the optional maximum size of HTTP response bodies.
+func validateMaxResponseBodyBytes(config *Config) error {
+	maxResponseBodyBytesInput := os.Getenv("INPUT_MAX_RESPONSE_BODY_BYTES")
+	if maxResponseBodyBytesInput == "" {
+		return nil
+	}
+	value, err := strconv.ParseInt(maxResponseBodyBytesInput, 10, 64)
+	if err != nil || value <= 0 {
+		return errors.New("Invalid maximum response body size specified.")
+	}
+	config.MaxResponseBodyBytes = value
+	return nil
+}
+
+// This function parses the optional server-side query timeout.
+func validateQueryTimeout(config *Config) error {
+	queryTimeoutMsInput := os.Getenv("INPUT_QUERY_TIMEOUT_MS")
+	if queryTimeoutMsInput == "" {
+		return nil
+	}
+	value, err := strconv.Atoi(queryTimeoutMsInput)
+	if err != nil || value <= 0 {
+		return errors.New("Invalid query timeout specified.")
+	}
+	config.QueryTimeoutMs = value
+	return nil
+}
+
+// This function parses the optional number of requests per second sent to the
+// NewRelic API, which must be between 1 and 50.
+func validateRequestsPerSecond(config *Config) error {
+	requestsPerSecondInput := os.Getenv("INPUT_REQUESTS_PER_SECOND")
+	if requestsPerSecondInput == "" {
+		return nil
+	}
+	value, err := strconv.Atoi(requestsPerSecondInput)
+	if err != nil || value < 1 || value > 50 {
+		return errors.New("Invalid number of requests per second specified, it must be between 1 and 50.")
+	}
+	config.RequestsPerSecond = value
+	return nil
+}
+
+// This function parses the optional number of app IDs resolved at the same
+// time in batch mode, which must be between 1 and 50.
+func validateBatchConcurrency(config *Config) error {
+	batchConcurrencyInput := os.Getenv("INPUT_BATCH_CONCURRENCY")
+	if batchConcurrencyInput == "" {
+		return nil
+	}
+	value, err := strconv.Atoi(batchConcurrencyInput)
+	if err != nil || value < 1 || value > 50 {
+		return errors.New("Invalid batch concurrency specified, it must be between 1 and 50.")
+	}
+	config.BatchConcurrency = value
+	return nil
+}
+
+// This function parses the optional complexity score above which a warning is
+// printed for a search query, which must be positive.
+func validateMaxQueryComplexity(config *Config) error {
+	maxQueryComplexityInput := os.Getenv("INPUT_MAX_QUERY_COMPLEXITY")
+	if maxQueryComplexityInput == "" {
+		return nil
+	}
+	value, err := strconv.Atoi(maxQueryComplexityInput)
+	if err != nil || value <= 0 {
+		return errors.New("Invalid maximum query complexity specified, it must be a positive number.")
+	}
+	config.MaxQueryComplexity = value
+	return nil
+}
+
+// This function parses the optional maximum number of entities returned by an
+// entity search, which must be between 1 and 200.
+func validateEntitySearchLimit(config *Config) error {
+	entitySearchLimitInput := os.Getenv("INPUT_ENTITY_SEARCH_LIMIT")
+	if entitySearchLimitInput == "" {
+		return nil
+	}
+	value, err := strconv.Atoi(entitySearchLimitInput)
+	if err != nil || value < 1 || value > 200 {
+		return errors.New("Invalid entity search limit specified, it must be between 1 and 200.")
+	}
+	config.EntitySearchLimit = value
+	return nil
+}
+
+// This function parses the optional criterion the results of an entity search
+// are sorted by, which must be NAME, MOST_RELEVANT or
+// LAST_REPORTING_CHANGE_TIME.
+func validateEntitySearchSortBy(config *Config) error {
+	entitySearchSortByInput := strings.ToUpper(os.Getenv("INPUT_ENTITY_SEARCH_SORTBY"))
+	if entitySearchSortByInput == "" {
+		return nil
+	}
+	if !entitySearchSortCriteria[entitySearchSortByInput] {
+		return fmt.Errorf("Invalid entity search sort criterion %q specified, it must be NAME, MOST_RELEVANT or LAST_REPORTING_CHANGE_TIME.", entitySearchSortByInput)
+	}
+	config.EntitySearchSortBy = entitySearchSortByInput
+	return nil
+}
+
+// This function parses the optional name of the operation of the queries
+// sent to the NewRelic API.
+func validateGraphQLOperationName(config *Config) error {
+	graphqlOperationNameInput := strings.TrimSpace(os.Getenv("INPUT_GRAPHQL_OPERATION_NAME"))
+	if graphqlOperationNameInput == "" {
+		return nil
+	}
+	if !graphqlNamePattern.MatchString(graphqlOperationNameInput) {
+		return fmt.Errorf("Invalid GraphQL operation name %q specified, it must only contain letters, digits and underscores.", graphqlOperationNameInput)
+	}
+	config.GraphQLOperationName = graphqlOperationNameInput
+	return nil
+}
+
+// This function returns an error if a content encoding is accepted that can
+// not be decoded. Quality values, e.g. gzip;q=0.8, are ignored.
+func validateAcceptEncoding(config *Config) error {
+	acceptEncodingInput := os.Getenv("INPUT_ACCEPT_ENCODING")
+	if acceptEncodingInput == "" {
+		return nil
+	}
+	for _, item := range strings.Split(acceptEncodingInput, ",") {
+		encoding, _, _ := strings.Cut(item, ";")
+		encoding = strings.ToLower(strings.TrimSpace(encoding))
+		if _, ok := contentDecoders[encoding]; ok || encoding == "identity" {
+			continue
+		}
+		if encoding == "br" {
+			return errors.New("Brotli content encoding is not supported by this build, it requires the brotli build tag.")
+		}
+		return fmt.Errorf("Unsupported content encoding %q specified.", encoding)
+	}
+	config.AcceptEncoding = acceptEncodingInput
+	return nil
+}
+
+// This function returns an error if the output format is not supported. The
+// default output format sets the output parameters of the action.
+func validateOutputFormat(config *Config) error {
+	if _, ok := outputFormatters[config.OutputFormat]; config.OutputFormat != "" && config.OutputFormat != configMapOutputFormat && !ok {
+		return fmt.Errorf("Invalid output format %q specified, it must be one of %s.", config.OutputFormat, strings.Join(sortedOutputFormats(), ", "))
+	}
+	return nil
+}
+
+// This function parses the optional JSON list of tags to add to the entity.
+func validateSetTags(config *Config) error {
+	setTagsInput := os.Getenv("INPUT_SET_TAGS")
+	if setTagsInput == "" {
+		return nil
+	}
+	if err := json.Unmarshal([]byte(setTagsInput), &config.SetTags); err != nil {
+		return fmt.Errorf("Invalid tags specified: %w", err)
+	}
+	for _, tag := range config.SetTags {
+		if tag.Key == "" {
+			return errors.New("Invalid tags specified: every tag must have a key.")
+		}
+	}
+	return nil
+}
+
+// This function returns an error if the GUID is to be persisted as commit
+// status without a GitHub token to create it with.
+func validatePersistGUIDAsAnnotation(config *Config) error {
+	if config.PersistGUIDAsAnnotation && config.GitHubToken == "" {
+		return errors.New("A GitHub token is required to persist the GUID as commit status.")
+	}
+	return nil
+}
+
+// This function parses the optional template of the Slack message and
+// renders it once with sample data, so that unknown fields are reported before
+// the NewRelic API is called. The webhook URL is not included in the errors,
+// as it grants access to the channel.
+func validateSlackMessage(config *Config) error {
+	slackMessageTemplateInput := os.Getenv("INPUT_SLACK_MESSAGE_TEMPLATE")
+	if config.SlackWebhookURL == "" {
+		if slackMessageTemplateInput != "" {
+			return errors.New("A Slack webhook URL is required to post the Slack message.")
+		}
+		return nil
+	}
+	if webhookURL, err := url.Parse(config.SlackWebhookURL); err != nil || webhookURL.Scheme != "https" || webhookURL.Host == "" {
+		return errors.New("Invalid Slack webhook URL specified, expected an https URL.")
+	}
+	if slackMessageTemplateInput == "" {
+		slackMessageTemplateInput = defaultSlackMessageTemplate
+	}
+	slackMessageTemplate, err := template.New("slack_message_template").Option("missingkey=error").Parse(slackMessageTemplateInput)
+	if err == nil {
+		err = slackMessageTemplate.Execute(io.Discard, slackMessageData{})
+	}
+	if err != nil {
+		return fmt.Errorf("Invalid Slack message template specified: %w", err)
+	}
+	config.SlackMessageTemplate = slackMessageTemplate
+	return nil
+}
+
+// This function parses the optional JSON object holding the values of the
+// variables of the GraphQL query specified in the graphql_query input
+// parameter.
+func validateGraphQLVariables(config *Config) error {
+	graphqlVariablesInput := os.Getenv("INPUT_GRAPHQL_VARIABLES")
+	if graphqlVariablesInput == "" {
+		return nil
+	}
+	if config.GraphQLQuery == "" {
+		return errors.New("GraphQL variables specified without GraphQL query.")
+	}
+	if err := json.Unmarshal([]byte(graphqlVariablesInput), &config.GraphQLVariables); err != nil || config.GraphQLVariables == nil {
+		return errors.New("Invalid GraphQL variables specified, expected a JSON object.")
+	}
+	return nil
+}
+
+// This function parses the optional maximum error rate the entity may have.
+func validateMaxErrorRate(config *Config) error {
+	maxErrorRatePercentInput := os.Getenv("INPUT_MAX_ERROR_RATE_PERCENT")
+	if maxErrorRatePercentInput == "" {
+		return nil
+	}
+	value, err := strconv.ParseFloat(maxErrorRatePercentInput, 64)
+	if err != nil || value < 0 {
+		return errors.New("Invalid maximum error rate percent specified.")
+	}
+	config.MaxErrorRatePercent = value
+	return nil
+}
+
+// This function parses the optional maximum JavaScript error rate the browser
+// application entity may have.
+func validateMaxJSErrorRate(config *Config) error {
+	maxJSErrorRateInput := os.Getenv("INPUT_MAX_JS_ERROR_RATE")
+	if maxJSErrorRateInput == "" {
+		return nil
+	}
+	value, err := strconv.ParseFloat(maxJSErrorRateInput, 64)
+	if err != nil || value < 0 {
+		return errors.New("Invalid maximum JavaScript error rate specified.")
+	}
+	config.MaxJSErrorRatePercent = value
+	return nil
+}
+
+// This function parses the optional data ingest in gigabytes above which a
+// warning is printed.
+func validateMaxIngestGBWarning(config *Config) error {
+	maxIngestGBWarningInput := os.Getenv("INPUT_MAX_INGEST_GB_WARNING")
+	if maxIngestGBWarningInput == "" {
+		return nil
+	}
+	value, err := strconv.ParseFloat(maxIngestGBWarningInput, 64)
+	if err != nil || value < 0 {
+		return errors.New("Invalid maximum data ingest specified.")
+	}
+	config.MaxIngestGBWarning = value
+	return nil
+}
+
+// This function parses the optional minimum attainment the service level
+// objectives of the entity must have.
+func validateMinSLOAttainment(config *Config) error {
+	minSLOAttainmentPercentInput := os.Getenv("INPUT_MIN_SLO_ATTAINMENT_PERCENT")
+	if minSLOAttainmentPercentInput == "" {
+		return nil
+	}
+	value, err := strconv.ParseFloat(minSLOAttainmentPercentInput, 64)
+	if err != nil || value < 0 || value > 100 {
+		return errors.New("Invalid minimum SLO attainment percent specified.")
+	}
+	config.MinSLOAttainmentPercent = value
+	return nil
+}
+
+// This function parses the optional number of days the history of the
+// service level objectives is fetched for.
+func validateSLOPeriodDays(config *Config) error {
+	sloPeriodDaysInput := os.Getenv("INPUT_SLO_PERIOD_DAYS")
+	if sloPeriodDaysInput == "" {
+		return nil
+	}
+	value, err := strconv.Atoi(sloPeriodDaysInput)
+	if err != nil || value < 1 {
+		return errors.New("Invalid SLO period days specified.")
+	}
+	config.SLOPeriodDays = value
+	return nil
+}
+
+// This variable holds the pattern of the relative times accepted by the
+// deployments_since, timeseries_since and timeseries_until input parameters,
+// e.g. "7 days ago".
+var nrqlRelativeTimePattern = regexp.MustCompile(`(?i)^\d+ (minutes?|hours?|days?|weeks?) ago$`)
+
+// This variable holds the pattern of the points in time in milliseconds since
+// the epoch accepted by the same input parameters.
+var nrqlEpochTimePattern = regexp.MustCompile(`^\d+$`)
+
+// This function returns an error if the start or end of the time range of the
+// deployments or the time series is neither a relative time nor a point in
+// time since the epoch. The values are embedded in NRQL queries, so anything
+// else would change the query. The end may also be NOW.
+func validateNRQLTimeRange(config *Config) error {
+	isTime := func(value string) bool {
+		return nrqlRelativeTimePattern.MatchString(value) || nrqlEpochTimePattern.MatchString(value)
+	}
+	if !isTime(config.DeploymentsSince) {
+		return fmt.Errorf("Invalid deployments since specified, expected e.g. 7 days ago or milliseconds since the epoch: %s", config.DeploymentsSince)
+	}
+	if !isTime(config.TimeSeriesSince) {
+		return fmt.Errorf("Invalid time series since specified, expected e.g. 30 minutes ago or milliseconds since the epoch: %s", config.TimeSeriesSince)
+	}
+	if !isTime(config.TimeSeriesUntil) && !strings.EqualFold(config.TimeSeriesUntil, "NOW") {
+		return fmt.Errorf("Invalid time series until specified, expected NOW, e.g. 5 minutes ago or milliseconds since the epoch: %s", config.TimeSeriesUntil)
+	}
+	return nil
+}
+
+// This function returns an error if none of the newrelicAppID, cluster_name,
+// workload_name and fulltext_search_term input parameters is set.
+func validateSearchInput(config *Config) error {
+	if config.AppID == "" && config.ClusterName == "" && config.WorkloadName == "" && config.FullTextSearchTerm == "" {
+		return errors.New("NewRelic app ID not specified.")
+	}
+	return nil
+}
+
+// This function returns an error if a full-text search term is combined with
+// any other way of searching for the entity. It warns about searches that are
+// not narrowed down to an entity type, as they may match many entities.
+func validateFullTextSearch(config *Config) error {
+	if config.FullTextSearchTerm == "" {
+		return nil
+	}
+	if config.AppID != "" || config.ClusterName != "" || config.WorkloadName != "" {
+		return errors.New("A full-text search term can not be combined with a NewRelic app ID, cluster name or workload name.")
+	}
+	if config.EntityType == "" {
+		fmt.Println("::warning::The full-text search is not narrowed down to an entity type, please set entity_domain_type to avoid matching too many entities")
+	}
+	return nil
+}
+
+// This function returns an error if a workload name is combined with an app
+// ID or cluster name, or specified without the account ID. Workload names are
+// only unique within an account.
+func validateWorkloadSearch(config *Config) error {
+	if config.WorkloadName == "" {
+		return nil
+	}
+	if config.AppID != "" || config.ClusterName != "" {
+		return errors.New("A workload name can not be combined with a NewRelic app ID or cluster name.")
+	}
+	if config.AccountID == 0 {
+		return errors.New("NewRelic account ID not specified, it is required to search for a workload.")
+	}
+	return nil
+}
+
+// This function returns an error if a cluster name is combined with an app
+// ID. The cluster is searched for instead of the app, so the app ID would be
+// ignored.
+func validateClusterSearch(config *Config) error {
+	if config.ClusterName != "" && config.AppID != "" {
+		return errors.New("A cluster name can not be combined with a NewRelic app ID.")
+	}
+	return nil
+}
+
+// This function returns an error if the kubernetes_namespace input parameter
+// is set without the cluster_name input parameter.
+func validateKubernetesNamespace(config *Config) error {
+	if config.KubernetesNamespace != "" && config.ClusterName == "" {
+		return errors.New("Kubernetes namespace specified without cluster name.")
+	}
+	return nil
+}
+
+// This function returns an error if more than one app ID is combined with a
+// parent GUID or an entity search cursor.
+func validateBatchMode(config *Config) error {
+	if len(config.AppIDs) > 1 && config.ParentGUID != "" {
+		return errors.New("A parent GUID can only be combined with a single NewRelic app ID.")
+	}
+	if len(config.AppIDs) > 1 && config.EntitySearchCursor != "" {
+		return errors.New("An entity search cursor can only be combined with a single NewRelic app ID.")
+	}
+	return nil
+}
+
+// This function returns an error if a NRQL query is specified without the
+// account ID to run it in.
+func validateNRQLQuery(config *Config) error {
+	if config.NRQLQuery != "" && config.AccountID == 0 {
+		return errors.New("NewRelic account ID not specified, it is required to run a NRQL query.")
+	}
+	return nil
+}
+
+// This function returns an error if the k8s-configmap output format has been
+// specified but no output file to write the ConfigMap to. The other formats
+// are printed to stdout without an output file.
+func validateOutputFile(config *Config) error {
+	if config.OutputFormat == configMapOutputFormat && config.OutputFile == "" {
+		return errors.New("Output file not specified.")
+	}
+	return nil
+}
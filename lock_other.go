@@ -0,0 +1,17 @@
+//go:build !unix
+
+package main
+
+import "os"
+
+// This function always succeeds, as file locking is not supported on this
+// platform. The cache is used without locking.
+func tryLockFile(f *os.File) (bool, error) {
+	return true, nil
+}
+
+// This function does nothing, as file locking is not supported on this
+// platform.
+func unlockFile(f *os.File) error {
+	return nil
+}
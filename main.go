@@ -1,142 +1,914 @@
 package main
 
 import (
-	"encoding/json"
-	"errors"
+	"context"
 	"fmt"
-	"log"
-	"net/http"
 	"os"
+	"os/signal"
+	"runtime"
+	"strconv"
 	"strings"
+	"syscall"
+	"time"
+
+	"github.com/zaljic/newrelic-guid-fetcher-action/pkg/newrelicguid"
 )
 
-// This struct is used to unmarshal the JSON returned by the New Relic API.
-type GraphQL struct {
-	Data struct {
-		Actor struct {
-			EntitySearch struct {
-				Count   int    `json:"count"`
-				Query   string `json:"query"`
-				Results struct {
-					Entities []struct {
-						EntityType string `json:"entityType"`
-						GUID       string `json:"guid"`
-						Name       string `json:"name"`
-					} `json:"entities"`
-				} `json:"results"`
-			} `json:"entitySearch"`
-		} `json:"actor"`
-	} `json:"data"`
-}
+// This variable holds the version of the action. It is set at build time
+// using -ldflags "-X main.actionVersion=<version>".
+var actionVersion = "dev"
+
+// These constants are the exit codes the action exits with. Any failure that
+// does not have a dedicated exit code exits with exitCodeFailure.
+const (
+	exitCodeFailure            = 1
+	exitCodeEntityTypeMismatch = 2
+	exitCodeUnhealthyEntity    = 8
+	exitCodeOpenViolations     = 9
+	exitCodeCriticalAlert      = 10
+	exitCodeActiveAnomaly      = 11
+	exitCodeOutdatedAgent      = 12
+	exitCodeSyntheticFailed    = 13
+)
 
 // This function is the entry point for the action. It is responsible for
 // parsing the input parameters, calling the functions that fetch the
 // application ID from the New Relic API, and setting the output parameter.
 func main() {
+	// Parse the command-line flags, which override the input parameters from
+	// the environment variables.
+	options := parseFlags()
+
+	// Start profiling if the binary has been built with the debug build tag
+	// and the cpuprofile or memprofile flags are set.
+	stopProfiling := startProfiling()
+
+	// Cancel all in-flight requests to the NewRelic API when the runner
+	// cancels the run, which sends SIGTERM, or the binary is interrupted.
+	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGTERM, os.Interrupt)
+	defer stop()
+
 	// Get the input parameters from the environment variables.
-	newrelicApiKey := os.Getenv("INPUT_NEWRELICAPIKEY")
-	newrelicRegion := os.Getenv("INPUT_NEWRELICREGION")
-	newrelicAppID := os.Getenv("INPUT_NEWRELICAPPID")
-
-	// Return an error if the newrelicApiKey input parameter is not set.
-	if newrelicApiKey == "" {
-		fmt.Println("NewRelic API key not specified.")
-		os.Exit(1)
-	}
-
-	// Return an error if the newrelicAppID input parameter is not set.
-	if newrelicAppID == "" {
-		fmt.Println("NewRelic app ID not specified.")
-		os.Exit(1)
-	}
-
-	// Set the NewRelic GraphQL endpoint based on the region specified in the
-	// newrelicRegion input parameter.
-	newrelicApiEndpoint := ""
-	// The New Relic GraphQL endpoint is different for US and EU regions.
-	if newrelicRegion == "US" {
-		newrelicApiEndpoint = "https://api.newrelic.com/graphql"
-	} else if newrelicRegion == "EU" {
-		newrelicApiEndpoint = "https://api.eu.newrelic.com/graphql"
-		// If the region is not US or EU, exit with an error.
-	} else {
-		fmt.Println("Invalid NewRelic region specified.")
-		os.Exit(1)
-	}
-
-	// Call the getGUID function to fetch the list of applications from
-	// the NewRelic GraphQL endpoint.
-	graphqlResponse, err := getGUID(newrelicApiKey, newrelicApiEndpoint, newrelicAppID)
-	if err != nil {
+	config, configErr := NewConfig()
+
+	// Exit the action with the given exit code. If the run has been cancelled,
+	// the status file is written for a post step to pick up. If telemetry is
+	// enabled, the outcome of the run is reported to the telemetry endpoint
+	// before exiting.
+	entityType := ""
+	exit := func(code int) {
+		if ctx.Err() != nil {
+			fmt.Println("Run cancelled.")
+			if err := writeStatusFile("cancelled", config.AppID); err != nil {
+				fmt.Printf("::warning::Writing the status file failed: %s\n", err)
+			}
+		}
+		if config.TelemetryEnabled && config.TelemetryEndpoint != "" {
+			<-sendTelemetry(config.TelemetryEndpoint, telemetryPayload{
+				ActionVersion: actionVersion,
+				GoVersion:     runtime.Version(),
+				OS:            runtime.GOOS,
+				Arch:          runtime.GOARCH,
+				Region:        config.Region.String(),
+				EntityType:    entityType,
+				Success:       code == 0,
+			})
+		}
+		stopProfiling()
+		os.Exit(code)
+	}
+
+	// Warn about input parameters that have not been expanded by the runner,
+	// before they are reported as invalid.
+	warnUnexpandedExpressions()
+
+	// Return an error if an input parameter is invalid.
+	if configErr != nil {
+		fmt.Println(configErr)
+		if options.SelfTest {
+			printSelfTestChecklist()
+		}
+		exit(exitCodeFailure)
+	}
+
+	// Open the audit log every call to the NewRelic API is recorded in if the
+	// audit_log_file input parameter is set.
+	if config.AuditLogFile != "" {
+		logger, err := NewAuditLogger(config.AuditLogFile)
+		if err != nil {
+			fmt.Println(err)
+			exit(exitCodeFailure)
+		}
+		auditLogger = logger
+	}
+
+	// Mask the Vault token, so that it never shows up in the logs.
+	if config.KeySources.VaultToken != "" {
+		fmt.Printf("::add-mask::%s\n", config.KeySources.VaultToken)
+	}
+
+	// Mask the token of the feature flag service.
+	if config.FeatureFlagServiceToken != "" {
+		fmt.Printf("::add-mask::%s\n", config.FeatureFlagServiceToken)
+	}
+
+	// Mask the Slack webhook URL, which grants access to the channel.
+	if config.SlackWebhookURL != "" {
+		fmt.Printf("::add-mask::%s\n", config.SlackWebhookURL)
+	}
+
+	// Run the self-test instead of fetching the GUID if the --self-test flag
+	// has been set.
+	if options.SelfTest {
+		err := runSelfTest(ctx, config)
+		if err != nil {
+			fmt.Println(err)
+			printSelfTestChecklist()
+			exit(exitCodeFailure)
+		}
+		exit(0)
+	}
+
+	// Return an error if an input parameter required to fetch the GUID is
+	// missing.
+	if err := config.Validate(); err != nil {
 		fmt.Println(err)
-		os.Exit(1)
+		exit(exitCodeFailure)
 	}
 
-	// Call the getApplicationGUID function to get the application GUID from the
-	// GraphQL response.
-	applicationGUID := getApplicationGUID(graphqlResponse)
+	// Return an error if the input parameter the tag or healthcheck subcommand
+	// acts on is missing. The validate subcommand exits here, as all input
+	// parameters have been checked.
+	switch options.Command {
+	case "validate":
+		fmt.Println("Input parameters are valid.")
+		exit(0)
+	case "tag":
+		if len(config.SetTags) == 0 {
+			fmt.Println("Tags not specified, they are required by the tag subcommand.")
+			exit(exitCodeFailure)
+		}
+	case "healthcheck":
+		if config.MaxErrorRatePercent < 0 {
+			fmt.Println("Maximum error rate percent not specified, it is required by the healthcheck subcommand.")
+			exit(exitCodeFailure)
+		}
+	}
 
-	// Print the output parameter to stdout.
-	fmt.Printf(`::set-output name=appGUID::%s`, applicationGUID)
-}
+	// Create the client used for all requests to the NewRelic API and Vault.
+	// Its HTTP client trusts the CA certificates specified in the ca_cert_file
+	// and ca_cert_dir input parameters in addition to the system CA
+	// certificates, and is limited to the number of requests per second
+	// specified in the requests_per_second input parameter. The rate limiter is
+	// shared by all goroutines of batch mode. The API key is resolved from the
+	// input parameter, file, environment variable, Vault secret or SSM
+	// parameter it has been specified in.
+	newrelicClient, err := newClientFromConfig(ctx, config)
+	if err != nil {
+		fmt.Println(err)
+		exit(exitCodeFailure)
+	}
 
-// This function sends a HTTP POST request to the endpoint specified in the
-// newrelicApiEndpoint input parameter and returns the GraphQL response
-// returned by the NewRelic API. It is assumed that the GraphQL response
-// contains a list of applications.
-func getGUID(newrelicApiKey string, newrelicApiEndpoint string, newrelicAppID string) (GraphQL, error) {
-	// Create a new net/http client.
-	client := &http.Client{}
-
-	// Specify data to be sent in the HTTP request body.
-	dataString := fmt.Sprintf(`{"query":"{ actor { entitySearch(query: \"domainId=%s\") { count query results { entities { entityType name guid } } } } }\n","variables":null}`, newrelicAppID)
-	data := strings.NewReader(dataString)
-
-	// Send a HTTP GET request using net/http to the NewRelic GraphQL endpoint
-	// specified in the newrelicApiEndpoint input parameter.
-	req, err := http.NewRequest("POST", newrelicApiEndpoint, data)
+	// Resolve the entities of the app IDs. The first entity is the application
+	// entity all further steps operate on.
+	entities, err := newrelicClient.resolveEntities(ctx, config)
 	if err != nil {
-		log.Fatal(err)
+		fmt.Println(err)
+		exit(exitCodeFailure)
+	}
+	applicationEntity := entities[0]
+	applicationGUID := applicationEntity.GUID
+	entityType = applicationEntity.EntityType
+
+	// Return an error if the entity is reported in a different account than the
+	// one specified in the newrelicAccountID input parameter.
+	if config.AccountID != 0 && applicationEntity.AccountID != config.AccountID {
+		fmt.Printf("::error::NewRelic entity %s is reported in account %d, expected account %d.\n", applicationGUID, applicationEntity.AccountID, config.AccountID)
+		exit(exitCodeFailure)
+	}
+
+	// Return an error if the entity is of a different type than the one
+	// specified in the assert_entity_type input parameter, e.g. because the
+	// entity type changed after an agent migration.
+	if config.AssertEntityType != "" && !strings.EqualFold(applicationEntity.EntityType, config.AssertEntityType) {
+		fmt.Printf("::error::NewRelic entity %s is of type %s, expected type %s.\n", applicationGUID, applicationEntity.EntityType, config.AssertEntityType)
+		exit(exitCodeEntityTypeMismatch)
+	}
+
+	// Return an error if the entity has stopped reporting longer ago than
+	// specified in the max_entity_age_hours input parameter, so that nothing
+	// is attached to a stale entity. Entities that are still reporting are
+	// never stale, no matter when they started reporting.
+	if config.MaxEntityAgeHours > 0 {
+		if err := CheckEntityAge(applicationEntity, config.MaxEntityAgeHours, time.Now()); err != nil {
+			fmt.Printf("::error::%s\n", err)
+			exit(exitCodeFailure)
+		}
+	}
+
+	// Print the output parameters of the resolved entities to stdout.
+	if err := writeEntityOutputs(config, entities); err != nil {
+		fmt.Println(err)
+		exit(exitCodeFailure)
+	}
+
+	// Fail the action if the error rate of the entity exceeds the maximum error
+	// rate specified in the max_error_rate_percent input parameter. The gate
+	// runs right after the entity has been resolved, so that nothing is tagged,
+	// renamed or announced for an unhealthy entity. It is also the check of the
+	// healthcheck subcommand, which requires the input parameter, and skipped
+	// by the resolve subcommand, which only resolves the entity.
+	if config.MaxErrorRatePercent >= 0 && options.Command != "resolve" {
+		if code, err := checkEntityErrorRate(ctx, newrelicClient, applicationGUID, config.MaxErrorRatePercent); err != nil {
+			fmt.Printf("::error::%s\n", err)
+			exit(code)
+		}
+	}
+
+	// Run only the step of the subcommand, if any, once the entity has been
+	// resolved. The healthcheck subcommand is done, as the error rate has been
+	// checked above.
+	switch options.Command {
+	case "resolve", "healthcheck":
+		exit(0)
+	case "tag":
+		if err := tagEntity(ctx, newrelicClient, applicationGUID, config.SetTags, config.TagUpsert); err != nil {
+			fmt.Println(err)
+			exit(exitCodeFailure)
+		}
+		exit(0)
+	}
+
+	// Print the status of the workload if the workload_name input parameter is
+	// set.
+	if config.WorkloadName != "" {
+		status, err := newrelicClient.GetWorkloadStatus(ctx, applicationGUID)
+		if err != nil {
+			fmt.Println(err)
+			exit(exitCodeFailure)
+		}
+		setOutput("workloadStatus", status)
 	}
 
-	// Set the Api-Key header to the value of the newrelicApiKey input parameter.
-	req.Header.Set("Api-Key", newrelicApiKey)
+	// Rename the entity and print the updated entity as JSON output parameter if
+	// the rename_entity_to input parameter is set.
+	if config.RenameEntityTo != "" {
+		err := newrelicClient.renameEntity(ctx, applicationGUID, config.RenameEntityTo)
+		if err != nil {
+			fmt.Println(err)
+			exit(exitCodeFailure)
+		}
+		applicationEntity.Name = config.RenameEntityTo
 
-	// Set the Content-Type header to application/x-www-form-urlencoded.
-	req.Header.Set("Content-Type", "application/json")
+		if err := setJSONOutput("renamedEntity", applicationEntity); err != nil {
+			fmt.Println(err)
+			exit(exitCodeFailure)
+		}
+	}
 
-	// Send the HTTP request using the net/http client.
-	resp, err := client.Do(req)
+	// Add the tags specified in the set_tags input parameter to the entity, or
+	// replace the values of their keys if tag_upsert is set, and print the
+	// updated tags of the entity as JSON output parameter.
+	if len(config.SetTags) > 0 {
+		if err := tagEntity(ctx, newrelicClient, applicationGUID, config.SetTags, config.TagUpsert); err != nil {
+			fmt.Println(err)
+			exit(exitCodeFailure)
+		}
+	}
+
+	// Persist the GUID of the entity as commit status on the commit the
+	// workflow runs for if the persist_guid_as_annotation input parameter is
+	// set. The status links to the page of the entity in the NewRelic UI.
+	if config.PersistGUIDAsAnnotation {
+		permalink, err := newrelicguid.FormatGUIDAsURL(applicationGUID, config.Region, config.PermalinkType)
+		if err == nil {
+			err = createCommitStatus(ctx, newrelicClient.httpClient, config.GitHubToken, applicationEntity, permalink)
+		}
+		if err != nil {
+			fmt.Println(err)
+			exit(exitCodeFailure)
+		}
+	}
+
+	// Post a message about the resolved entity to the Slack webhook specified
+	// in the slack_webhook_url input parameter, if any. The message is
+	// rendered from the slack_message_template input parameter. A failed post
+	// only results in a warning, as the entity has been resolved.
+	if config.SlackWebhookURL != "" {
+		permalink, err := newrelicguid.FormatGUIDAsURL(applicationGUID, config.Region, config.PermalinkType)
+		if err == nil {
+			err = postSlackMessage(ctx, newrelicClient.httpClient, config.SlackWebhookURL, config.SlackMessageTemplate, slackMessageData{
+				GUID:       applicationGUID,
+				Name:       applicationEntity.Name,
+				EntityType: applicationEntity.EntityType,
+				AccountID:  applicationEntity.AccountID,
+				Region:     config.Region.String(),
+				Permalink:  permalink,
+			})
+		}
+		if err != nil {
+			fmt.Printf("::warning::Posting the Slack message failed: %s\n", err)
+		}
+	}
+
+	// Write the application entity to the output file as Kubernetes
+	// ConfigMap, if requested.
+	if err := writeConfigMapFile(config, entities); err != nil {
+		fmt.Println(err)
+		exit(exitCodeFailure)
+	}
+
+	// Fetch the alert policies monitoring the entity and print them as JSON
+	// output parameter if the fetch_alert_policies input parameter is set.
+	if config.FetchAlertPolicies {
+		policies, err := newrelicClient.GetAlertPolicies(ctx, applicationEntity)
+		if err == nil {
+			err = setJSONOutput("alertPolicies", policies)
+		}
+		if err != nil {
+			fmt.Println(err)
+			exit(exitCodeFailure)
+		}
+	}
+
+	// Fetch the alert conditions monitoring the entity and print them as JSON
+	// output parameter if the fetch_alert_conditions input parameter is set.
+	if config.FetchAlertConditions {
+		conditions, err := newrelicClient.GetRelatedAlertConditions(ctx, applicationEntity)
+		if err == nil {
+			err = setJSONOutput("alertConditions", conditions)
+		}
+		if err != nil {
+			fmt.Println(err)
+			exit(exitCodeFailure)
+		}
+	}
+
+	// Fetch the notification channels alerts of the entity are sent to and
+	// print them as JSON output parameter if the fetch_notification_channels
+	// input parameter is set.
+	if config.FetchNotificationChannels {
+		channels, err := newrelicClient.GetEntityNotificationChannels(ctx, applicationEntity)
+		if err == nil {
+			err = setJSONOutput("notificationChannels", channels)
+		}
+		if err != nil {
+			fmt.Println(err)
+			exit(exitCodeFailure)
+		}
+	}
+
+	// Fetch the workloads the entity belongs to and print them as JSON output
+	// parameter if the fetch_workloads input parameter is set.
+	if config.FetchWorkloads {
+		workloads, err := newrelicClient.GetEntityWorkloads(ctx, applicationGUID)
+		if err == nil {
+			err = setJSONOutput("entityWorkloads", workloads)
+		}
+		if err != nil {
+			fmt.Println(err)
+			exit(exitCodeFailure)
+		}
+	}
+
+	// Fetch the hierarchy of the account the entity is reported in and print
+	// it as output parameter if the fetch_account_hierarchy input parameter is
+	// set.
+	if config.FetchAccountHierarchy {
+		names, err := newrelicClient.GetEntityAccountHierarchy(ctx, applicationEntity.AccountID)
+		if err != nil {
+			fmt.Println(err)
+			exit(exitCodeFailure)
+		}
+		setOutput("accountPath", strings.Join(names, " > "))
+	}
+
+	// Fetch the team owning the entity and print it as JSON output parameter if
+	// the fetch_team input parameter is set.
+	if config.FetchTeam {
+		team, err := newrelicClient.GetEntityTeam(ctx, applicationGUID)
+		if err == nil {
+			err = setJSONOutput("entityTeam", team)
+		}
+		if err != nil {
+			fmt.Println(err)
+			exit(exitCodeFailure)
+		}
+	}
+
+	// Fetch the infrastructure hosts the entity runs on and print them as JSON
+	// output parameter if the fetch_infrastructure_hosts input parameter is set.
+	if config.FetchInfrastructureHosts {
+		hosts, err := newrelicClient.GetEntityHosts(ctx, applicationGUID)
+		if err == nil {
+			err = setJSONOutput("entityHosts", hosts)
+		}
+		if err != nil {
+			fmt.Println(err)
+			exit(exitCodeFailure)
+		}
+	}
+
+	// Fetch the Kubernetes attributes of the entity and print them as JSON
+	// output parameter if the fetch_k8s_metadata input parameter is set.
+	if config.FetchK8sMetadata {
+		metadata, err := newrelicClient.GetEntityK8sMetadata(ctx, applicationGUID)
+		if err == nil {
+			err = setJSONOutput("k8sMetadata", metadata)
+		}
+		if err != nil {
+			fmt.Println(err)
+			exit(exitCodeFailure)
+		}
+	}
+
+	// Fetch the Docker images the entity runs in and print them as output
+	// parameters if the fetch_container_images input parameter is set. Fail
+	// the action if the entity runs an image tag other than the one specified
+	// in the assert_container_image input parameter.
+	if config.FetchContainerImages || config.AssertContainerImage != "" {
+		images, err := newrelicClient.GetEntityContainerImages(ctx, applicationGUID)
+		if err != nil {
+			fmt.Println(err)
+			exit(exitCodeFailure)
+		}
+		setOutput("containerImageName", strings.Join(images.Names, config.MultiValueDelimiter))
+		setOutput("containerImageTag", strings.Join(images.Tags, config.MultiValueDelimiter))
+
+		if config.AssertContainerImage != "" {
+			if err := CheckContainerImageTag(images, config.AssertContainerImage); err != nil {
+				fmt.Printf("::error::%s\n", err)
+				exit(exitCodeFailure)
+			}
+		}
+	}
+
+	// Determine how far the migration of the entity to OpenTelemetry has
+	// progressed and print it as output parameter if the
+	// fetch_migration_status input parameter is set.
+	if config.FetchMigrationStatus {
+		status, err := newrelicClient.GetEntityMigrationStatus(ctx, applicationGUID)
+		if err != nil {
+			fmt.Println(err)
+			exit(exitCodeFailure)
+		}
+		setOutput("migrationStatus", string(status))
+	}
+
+	// Fetch the dashboards the entity is visualised in and print them as JSON
+	// output parameter if the fetch_dashboards input parameter is set.
+	if config.FetchDashboards {
+		dashboards, err := newrelicClient.GetEntityDashboards(ctx, applicationGUID)
+		if err == nil {
+			err = setJSONOutput("entityDashboards", dashboards)
+		}
+		if err != nil {
+			fmt.Println(err)
+			exit(exitCodeFailure)
+		}
+	}
+
+	// Fetch the services the entity calls and is called by and print them as
+	// JSON output parameter if the fetch_service_map input parameter is set.
+	if config.FetchServiceMap {
+		neighbours, err := newrelicClient.GetServiceMap(ctx, applicationGUID)
+		if err == nil {
+			err = setJSONOutput("serviceMap", neighbours)
+		}
+		if err != nil {
+			fmt.Println(err)
+			exit(exitCodeFailure)
+		}
+	}
+
+	// Fetch the relationships of the entity to the entities of the accounts
+	// specified in the cross_account_ids input parameter and print them as JSON
+	// output parameter if the fetch_cross_account_deps input parameter is set.
+	if config.FetchCrossAccountDeps {
+		graph, err := newrelicClient.GetEntityCrossAccountDependencies(ctx, applicationEntity, config.CrossAccountIDs)
+		if err == nil {
+			err = setJSONOutput("crossAccountDependencies", graph)
+		}
+		if err != nil {
+			fmt.Println(err)
+			exit(exitCodeFailure)
+		}
+	}
+
+	// Fetch the feature flags associated with the entity from the service
+	// specified in the feature_flag_service_url input parameter and print them
+	// as JSON output parameter if the fetch_feature_flags input parameter is
+	// set.
+	if config.FetchFeatureFlags {
+		flags, err := GetEntityFeatureFlags(ctx, newrelicClient.httpClient, config.FeatureFlagServiceURL, config.FeatureFlagServiceToken, applicationGUID)
+		if err == nil {
+			err = setJSONOutput("featureFlags", flags)
+		}
+		if err != nil {
+			fmt.Println(err)
+			exit(exitCodeFailure)
+		}
+	}
+
+	// Fetch the APM settings of the entity and print them as JSON output
+	// parameter if the fetch_app_settings input parameter is set.
+	if config.FetchAppSettings {
+		settings, err := newrelicClient.GetEntityApplicationSettings(ctx, applicationGUID)
+		if err == nil {
+			err = setJSONOutput("appSettings", settings)
+		}
+		if err != nil {
+			fmt.Println(err)
+			exit(exitCodeFailure)
+		}
+	}
+
+	// Compare the APM settings of the entity with the ones of the entity
+	// specified in the compare_guid input parameter and print the differences
+	// as JSON output parameter.
+	if config.CompareGUID != "" {
+		diff, err := newrelicClient.CompareEntityConfigs(ctx, applicationGUID, config.CompareGUID)
+		if err == nil {
+			err = setJSONOutput("configDiff", diff)
+		}
+		if err != nil {
+			fmt.Println(err)
+			exit(exitCodeFailure)
+		}
+	}
+
+	// Fetch the recent deployments of the entity and print them as JSON output
+	// parameter if the fetch_deployments input parameter is set. The
+	// deployments are also added to the step summary, if available.
+	if config.FetchDeployments {
+		deployments, err := newrelicClient.GetEntityDeployments(ctx, applicationEntity, config.DeploymentsSince)
+		if err == nil {
+			err = setJSONOutput("entityDeployments", deployments)
+		}
+		if err != nil {
+			fmt.Println(err)
+			exit(exitCodeFailure)
+		}
+		if err := writeDeploymentsSummary(deployments); err != nil {
+			fmt.Printf("::warning::Writing the step summary failed: %s\n", err)
+		}
+	}
+
+	// Generate NRQL queries for the entity and print them as JSON output
+	// parameter if the generate_nrql_examples input parameter is set. The
+	// queries are also added to the step summary, if available.
+	if config.GenerateNRQLExamples {
+		examples := GetEntityNRQLExamples(applicationEntity)
+		if err := setJSONOutput("nrqlExamples", examples); err != nil {
+			fmt.Println(err)
+			exit(exitCodeFailure)
+		}
+		if err := writeNRQLExamplesSummary(examples); err != nil {
+			fmt.Printf("::warning::Writing the step summary failed: %s\n", err)
+		}
+	}
+
+	// Fetch the time series of the golden metrics of the entity and print them
+	// as JSON output parameter if the fetch_metric_timeseries input parameter
+	// is set.
+	if config.FetchMetricTimeSeries {
+		timeSeries, err := newrelicClient.GetEntityMetricTimeSeries(ctx, applicationEntity, config.TimeSeriesSince, config.TimeSeriesUntil)
+		if err == nil {
+			err = setJSONOutput("metricTimeSeries", timeSeries)
+		}
+		if err != nil {
+			fmt.Println(err)
+			exit(exitCodeFailure)
+		}
+	}
+
+	// Fetch the recent log lines of the entity and print them as JSON output
+	// parameter if the fetch_logs_in_context input parameter is set.
+	if config.FetchLogsInContext {
+		logs, err := newrelicClient.GetEntityLogs(ctx, applicationEntity)
+		if err == nil {
+			err = setJSONOutput("entityLogs", logs)
+		}
+		if err != nil {
+			fmt.Println(err)
+			exit(exitCodeFailure)
+		}
+	}
+
+	// Fetch the versions of the APM agents reporting the entity and print them
+	// as output parameters if the fetch_agent_version input parameter is set.
+	// Fail the action if the oldest agent is older than the version specified
+	// in the min_agent_version input parameter.
+	if config.FetchAgentVersion || config.MinAgentVersion != "" {
+		versions, err := newrelicClient.GetEntityLanguageAgentVersion(ctx, applicationGUID)
+		if err != nil {
+			fmt.Println(err)
+			exit(exitCodeFailure)
+		}
+		setOutput("agentMinVersion", versions.MinVersion)
+		setOutput("agentMaxVersion", versions.MaxVersion)
+
+		if config.MinAgentVersion != "" && versions.MinVersion != "" && compareVersions(versions.MinVersion, config.MinAgentVersion) < 0 {
+			fmt.Printf("::error::NewRelic entity %s is reported by an APM agent of version %s, which is older than %s.\n", applicationGUID, versions.MinVersion, config.MinAgentVersion)
+			exit(exitCodeOutdatedAgent)
+		}
+	}
+
+	// Fetch the result of the last check of the synthetic monitor entity and
+	// print it as output parameter if the fetch_synthetic_status input
+	// parameter is set. Fail the action if the check did not succeed and the
+	// fail_on_synthetic_failure input parameter is set.
+	if config.FetchSyntheticStatus || config.FailOnSyntheticFailure {
+		status, err := newrelicClient.GetSyntheticMonitorStatus(ctx, applicationEntity)
+		if err != nil {
+			fmt.Println(err)
+			exit(exitCodeFailure)
+		}
+		setOutput("syntheticStatus", status.Status)
+
+		if config.FailOnSyntheticFailure && status.Status != "SUCCESS" {
+			fmt.Printf("::error::Synthetic monitor %s: last check %s: %s\n", status.MonitorName, status.Status, status.Error)
+			exit(exitCodeSyntheticFailed)
+		}
+	}
+
+	// Fetch the performance metrics of the browser application entity and
+	// print them as output parameters if the fetch_browser_summary input
+	// parameter is set. Fail the action if the JavaScript error rate exceeds
+	// the maximum specified in the max_js_error_rate input parameter.
+	if config.FetchBrowserSummary || config.MaxJSErrorRatePercent >= 0 {
+		summary, err := newrelicClient.GetEntityBrowserSummary(ctx, applicationEntity)
+		if err != nil {
+			fmt.Println(err)
+			exit(exitCodeFailure)
+		}
+		jsErrorRatePercent := summary.JsErrorRate * 100
+		setOutput("browserResponseTime", strconv.FormatFloat(summary.PageLoadTimeAverage, 'f', -1, 64))
+		setOutput("browserThroughput", strconv.FormatFloat(summary.PageLoadThroughput, 'f', -1, 64))
+		setOutput("browserJsErrorRate", strconv.FormatFloat(jsErrorRatePercent, 'f', -1, 64))
+		setOutput("browserAjaxResponseTime", strconv.FormatFloat(summary.AjaxResponseTimeAverage, 'f', -1, 64))
+
+		if config.MaxJSErrorRatePercent >= 0 && jsErrorRatePercent > config.MaxJSErrorRatePercent {
+			fmt.Printf("::error::Browser application %s is unhealthy: JavaScript error rate is %.2f%%, maximum allowed is %.2f%%.\n", applicationEntity.Name, jsErrorRatePercent, config.MaxJSErrorRatePercent)
+			exit(exitCodeUnhealthyEntity)
+		}
+	}
+
+	// Fetch the data ingest of the entity over the last 30 days and print it
+	// as output parameter if the fetch_usage_metrics input parameter is set.
+	// Warn if it exceeds the max_ingest_gb_warning input parameter.
+	if config.FetchUsageMetrics || config.MaxIngestGBWarning >= 0 {
+		dataIngestGB, err := newrelicClient.GetEntityUsageMetrics(ctx, applicationEntity)
+		if err != nil {
+			fmt.Println(err)
+			exit(exitCodeFailure)
+		}
+		setOutput("dataIngestGB", strconv.FormatFloat(dataIngestGB, 'f', -1, 64))
+
+		if config.MaxIngestGBWarning >= 0 && dataIngestGB > config.MaxIngestGBWarning {
+			fmt.Printf("::warning::NewRelic entity %s ingested %.2f GB over the last 30 days, more than %.2f GB.\n", applicationEntity.Name, dataIngestGB, config.MaxIngestGBWarning)
+		}
+	}
+
+	// Fetch the alert severity of the entity and print it as output parameter
+	// if the fetch_incident_status input parameter is set. Fail the action if
+	// the entity is in a critical incident and the fail_on_critical_alert
+	// input parameter is set.
+	if config.FetchIncidentStatus || config.FailOnCriticalAlert {
+		alertStatus, err := newrelicClient.getEntityAlertStatus(ctx, applicationGUID)
+		if err != nil {
+			fmt.Println(err)
+			exit(exitCodeFailure)
+		}
+		alertSeverity := alertStatus.Data.Actor.Entity.AlertSeverity
+		setOutput("alertSeverity", alertSeverity)
+
+		if config.FailOnCriticalAlert && alertSeverity == "CRITICAL" {
+			fmt.Printf("::error::NewRelic entity %s is in a critical incident.\n", applicationGUID)
+			exit(exitCodeCriticalAlert)
+		}
+	}
+
+	// Fetch the open violations of the entity and print them as JSON output
+	// parameter if the fetch_violations input parameter is set. Fail the action
+	// if any critical violation is open and the fail_on_open_violations input
+	// parameter is set.
+	if config.FetchViolations || config.FailOnOpenViolations {
+		violations, err := newrelicClient.GetEntityViolations(ctx, applicationEntity)
+		if err == nil {
+			err = setJSONOutput("openViolations", violations)
+		}
+		if err != nil {
+			fmt.Println(err)
+			exit(exitCodeFailure)
+		}
+
+		if config.FailOnOpenViolations {
+			critical := 0
+			for _, violation := range violations {
+				if violation.Priority == "CRITICAL" {
+					fmt.Printf("::error::Open violation: %s (%s)\n", violation.Title, violation.Priority)
+					critical++
+				}
+			}
+			if critical > 0 {
+				fmt.Printf("NewRelic entity %s has %d open critical violations.\n", applicationGUID, critical)
+				exit(exitCodeOpenViolations)
+			}
+		}
+	}
+
+	// Fetch the active anomalies of the entity and print them as JSON output
+	// parameter if the fetch_anomalies input parameter is set. Fail the action
+	// if any critical anomaly is active and the fail_on_active_anomaly input
+	// parameter is set.
+	if config.FetchAnomalies || config.FailOnActiveAnomaly {
+		anomalies, err := newrelicClient.GetEntityAnomalies(ctx, applicationEntity)
+		if err == nil {
+			err = setJSONOutput("activeAnomalies", anomalies)
+		}
+		if err != nil {
+			fmt.Println(err)
+			exit(exitCodeFailure)
+		}
+
+		if config.FailOnActiveAnomaly {
+			critical := 0
+			for _, anomaly := range anomalies {
+				if anomaly.Priority == "CRITICAL" {
+					fmt.Printf("::error::Active anomaly since %s: %s (%s)\n", anomaly.ActivatedAt, anomaly.Title, anomaly.Description)
+					critical++
+				}
+			}
+			if critical > 0 {
+				fmt.Printf("NewRelic entity %s has %d active critical anomalies.\n", applicationGUID, critical)
+				exit(exitCodeActiveAnomaly)
+			}
+		}
+	}
+
+	// Run the NRQL query specified in the nrql_query input parameter and print
+	// the result rows as JSON output parameter.
+	if config.NRQLQuery != "" {
+		rows, err := newrelicClient.GetNRQLQueryResult(ctx, config.AccountID, config.NRQLQuery)
+		if err == nil {
+			err = setJSONOutput("nrqlResults", rows)
+		}
+		if err != nil {
+			fmt.Println(err)
+			exit(exitCodeFailure)
+		}
+	}
+
+	// Run the GraphQL query specified in the graphql_query input parameter with
+	// the variables specified in the graphql_variables input parameter and
+	// print the data of the response as JSON output parameter.
+	if config.GraphQLQuery != "" {
+		data, err := newrelicClient.RunGraphQLQuery(ctx, config.GraphQLQuery, config.GraphQLVariables)
+		if err == nil {
+			err = setJSONOutput("graphqlResult", data)
+		}
+		if err != nil {
+			fmt.Println(err)
+			exit(exitCodeFailure)
+		}
+	}
+
+	// Fetch the service level objectives of the entity and print them as JSON
+	// output parameter if the fetch_slos input parameter is set. Fail the
+	// action if an objective's attainment is below the minimum specified in the
+	// min_slo_attainment_percent input parameter. An objective without data
+	// has an unknown attainment, which only results in a warning.
+	if config.FetchSLOs || config.MinSLOAttainmentPercent >= 0 {
+		objectives, err := newrelicClient.GetEntitySLOs(ctx, applicationGUID)
+		if err == nil {
+			err = setJSONOutput("entitySLOs", objectives)
+		}
+		if err != nil {
+			fmt.Println(err)
+			exit(exitCodeFailure)
+		}
+
+		if config.MinSLOAttainmentPercent >= 0 {
+			for _, objective := range objectives {
+				if objective.Attainment == nil {
+					fmt.Printf("::warning::SLO %s has no data over the last %s, its attainment is unknown and not checked.\n", objective.IndicatorName, objective.TimeWindow)
+					continue
+				}
+				if *objective.Attainment < config.MinSLOAttainmentPercent {
+					fmt.Printf("::error::SLO %s is unhealthy: attainment is %.2f%%, minimum allowed is %.2f%%.\n", objective.IndicatorName, *objective.Attainment, config.MinSLOAttainmentPercent)
+					exit(exitCodeUnhealthyEntity)
+				}
+			}
+		}
+	}
+
+	// Print the daily attainment of the service level objectives of the entity
+	// over the period specified in the slo_period_days input parameter. The
+	// action fails if the average attainment of an objective over the period is
+	// below the minimum attainment. An objective without data over the period
+	// has an unknown attainment, which only results in a warning.
+	if config.FetchSLOHistory {
+		history, err := newrelicClient.GetEntityServiceLevelHistory(ctx, applicationGUID, config.SLOPeriodDays)
+		if err == nil {
+			err = setJSONOutput("sloHistory", history)
+		}
+		if err != nil {
+			fmt.Println(err)
+			exit(exitCodeFailure)
+		}
+
+		if config.MinSLOAttainmentPercent >= 0 {
+			for _, objective := range history {
+				if objective.AverageAttainment == nil {
+					fmt.Printf("::warning::SLO %s has no data over the last %d days, its attainment is unknown and not checked.\n", objective.IndicatorName, config.SLOPeriodDays)
+					continue
+				}
+				if *objective.AverageAttainment < config.MinSLOAttainmentPercent {
+					fmt.Printf("::error::SLO %s is unhealthy: average attainment over the last %d days is %.2f%%, minimum allowed is %.2f%%.\n", objective.IndicatorName, config.SLOPeriodDays, *objective.AverageAttainment, config.MinSLOAttainmentPercent)
+					exit(exitCodeUnhealthyEntity)
+				}
+			}
+		}
+	}
+
+	exit(0)
+}
+
+// This function adds the given tags to the entity with the given GUID, or
+// replaces the values of their keys if upsert is true, and prints the updated
+// tags of the entity as JSON output parameter.
+func tagEntity(ctx context.Context, client *Client, guid string, tags []Tag, upsert bool) error {
+	err := client.updateEntityTags(ctx, guid, tags, upsert)
 	if err != nil {
-		log.Fatal(err)
+		return err
 	}
 
-	// Close the HTTP response body.
-	defer resp.Body.Close()
+	entityTags, err := client.getEntityTags(ctx, guid)
+	if err != nil {
+		return err
+	}
+	return setJSONOutput("entityTags", entityTags)
+}
+
+// This function checks the error rate of the entity with the given GUID
+// against the given maximum. If the check fails, the error is returned
+// together with the exit code the action exits with: exitCodeUnhealthyEntity
+// if the error rate is too high, exitCodeFailure if it could not be fetched.
+func checkEntityErrorRate(ctx context.Context, client *Client, guid string, maxErrorRatePercent float64) (int, error) {
+	summary, err := client.getEntitySummary(ctx, guid)
+	if err != nil {
+		return exitCodeFailure, err
+	}
 
-	// Print http status code.
-	fmt.Println(resp.StatusCode)
+	if err := CheckEntityHealth(summary, maxErrorRatePercent); err != nil {
+		return exitCodeUnhealthyEntity, err
+	}
+	return 0, nil
+}
 
-	// Return an error if the HTTP status code is not 200.
-	if resp.StatusCode != 200 {
-		return GraphQL{}, errors.New("HTTP status code is not 200")
+// This function runs a smoke test against the NewRelic API. It sends a query
+// for the user the API key belongs to and prints the user's email address,
+// the API latency and the endpoint the query has been sent to.
+func runSelfTest(ctx context.Context, config Config) error {
+	// Create the client the same way it is created when fetching a GUID.
+	client, err := newClientFromConfig(ctx, config)
+	if err != nil {
+		return err
 	}
+	fmt.Printf("Endpoint: %s\n", client.endpoint)
 
-	// Unmarshal the HTTP response body into the GraphQL struct.
-	var graphqlResponse GraphQL
-	err = json.NewDecoder(resp.Body).Decode(&graphqlResponse)
+	// Query the user the API key belongs to and measure the latency.
+	var userResponse struct {
+		Data struct {
+			Actor struct {
+				User struct {
+					Name  string `json:"name"`
+					Email string `json:"email"`
+				} `json:"user"`
+			} `json:"actor"`
+		} `json:"data"`
+	}
+	start := time.Now()
+	err = client.queryNerdGraph(ctx, `{ actor { user { name email } } }`, &userResponse)
 	if err != nil {
-		log.Fatal(err)
+		return err
 	}
+	latency := time.Since(start)
 
-	// Return the GraphQL response.
-	return graphqlResponse, nil
+	// Print the results of the self-test.
+	fmt.Printf("Authenticated as: %s <%s>\n", userResponse.Data.Actor.User.Name, userResponse.Data.Actor.User.Email)
+	fmt.Printf("API latency: %s\n", latency.Round(time.Millisecond))
+	fmt.Println("Self-test passed.")
+	return nil
 }
 
-// This function returns the application GUID of the previously fetched
-// GraphQL response. It is assumed that the entities list only contains one
-// GUID.
-func getApplicationGUID(graphqlResponse GraphQL) string {
-	// Return the application GUID.
-	return graphqlResponse.Data.Actor.EntitySearch.Results.Entities[0].GUID
+// This function prints the checklist to work through when the self-test
+// failed.
+func printSelfTestChecklist() {
+	fmt.Println("Self-test failed. Please check the following:")
+	fmt.Println("  - Is the API key a valid NewRelic user API key (NRAK-...)?")
+	fmt.Println("  - Is the NewRelic API endpoint reachable from this network, e.g. through a proxy or firewall?")
+	fmt.Println("  - Is the region (US, EU or GOV) the region the NewRelic account is running in?")
+	fmt.Println("  - Are custom CA certificates required to reach the NewRelic API?")
 }
@@ -0,0 +1,143 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestRunSelfTest(t *testing.T) {
+	server, recorded := newFixtureServer(t, [][2]string{
+		{"user", "testdata/selftest/user.json"},
+	})
+
+	config := Config{Endpoint: server.URL, KeySources: apiKeySources{Key: "NRAK-TEST"}}
+	if err := runSelfTest(context.Background(), config); err != nil {
+		t.Fatalf("runSelfTest() error = %v", err)
+	}
+	if queries := recorded.all(); len(queries) != 1 || !strings.Contains(queries[0], "user { name email }") {
+		t.Errorf("runSelfTest() sent %q, want a query of the user", queries)
+	}
+}
+
+func TestRunSelfTestFailure(t *testing.T) {
+	config := Config{Endpoint: "http://127.0.0.1:1/graphql", KeySources: apiKeySources{Key: "NRAK-TEST"}}
+	if err := runSelfTest(context.Background(), config); err == nil {
+		t.Error("runSelfTest() error = nil, want an error for an unreachable endpoint")
+	}
+}
+
+// This struct is a Logger that discards everything, so that benchmarks do not
+// flood the output.
+type discardLogger struct{}
+
+func (discardLogger) Printf(format string, v ...interface{}) {}
+
+func (discardLogger) Group(name string) func() { return func() {} }
+
+// This struct is a HTTPDoer that records the latency of each request sent
+// through it.
+type latencyRecorder struct {
+	client    HTTPDoer
+	mutex     sync.Mutex
+	latencies []time.Duration
+}
+
+func (r *latencyRecorder) Do(req *http.Request) (*http.Response, error) {
+	start := time.Now()
+	resp, err := r.client.Do(req)
+	r.mutex.Lock()
+	r.latencies = append(r.latencies, time.Since(start))
+	r.mutex.Unlock()
+	return resp, err
+}
+
+// This function returns the given percentile of the recorded latencies.
+func (r *latencyRecorder) percentile(p float64) time.Duration {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	if len(r.latencies) == 0 {
+		return 0
+	}
+	sorted := append([]time.Duration(nil), r.latencies...)
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i] < sorted[j] })
+	return sorted[int(float64(len(sorted)-1)*p)]
+}
+
+// This variable holds the pattern of the search query of an entity search.
+var benchmarkSearchQueryPattern = regexp.MustCompile(`entitySearch\(query: ("(?:[^"\\]|\\.)*")`)
+
+// This function starts a fake NewRelic GraphQL endpoint that answers each
+// entity search with a single entity after the given delay, echoing the
+// search query like NerdGraph does.
+func newDelayedSearchServer(b *testing.B, delay time.Duration) *httptest.Server {
+	b.Helper()
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var request graphQLRequest
+		if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		var searchQuery string
+		if match := benchmarkSearchQueryPattern.FindStringSubmatch(request.Query); match != nil {
+			json.Unmarshal([]byte(match[1]), &searchQuery)
+		}
+		time.Sleep(delay)
+
+		var response GraphQL
+		response.Data.Actor.EntitySearch.Count = 1
+		response.Data.Actor.EntitySearch.Query = searchQuery
+		response.Data.Actor.EntitySearch.Results.Entities = []Entity{
+			{AccountID: 1, EntityType: "APM_APPLICATION_ENTITY", Name: "checkout", GUID: "MXxBUE18QVBQTElDQVRJT058MQ"},
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(response)
+	}))
+	b.Cleanup(server.Close)
+
+	return server
+}
+
+func BenchmarkBatchResolve(b *testing.B) {
+	const delay = 5 * time.Millisecond
+	server := newDelayedSearchServer(b, delay)
+
+	for _, count := range []int{1, 10, 100, 500} {
+		appIDs := make([]string, count)
+		for i := range appIDs {
+			appIDs[i] = fmt.Sprint(i + 1)
+		}
+
+		for _, concurrency := range []int{1, 5, 10, 20} {
+			b.Run(fmt.Sprintf("ids=%d/concurrency=%d", count, concurrency), func(b *testing.B) {
+				recorder := &latencyRecorder{client: server.Client()}
+				client := NewClient(recorder, server.URL, "NRAK-TEST", discardLogger{})
+
+				b.ResetTimer()
+				for i := 0; i < b.N; i++ {
+					results := client.resolveAllGUIDs(context.Background(), false, searchCriteria{}, appIDs, &BatchMetrics{}, false, concurrency)
+					for _, result := range results {
+						if result.Err != nil {
+							b.Fatalf("resolving app ID %s failed: %v", result.AppID, result.Err)
+						}
+					}
+				}
+				b.StopTimer()
+
+				// Report the throughput and the tail latency of the requests.
+				b.ReportMetric(float64(count*b.N)/b.Elapsed().Seconds(), "entities/sec")
+				b.ReportMetric(float64(recorder.percentile(0.99).Microseconds())/1000, "p99-ms")
+			})
+		}
+	}
+}
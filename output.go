@@ -0,0 +1,410 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/zaljic/newrelic-guid-fetcher-action/pkg/newrelicguid"
+)
+
+// This function prints a workflow command to stdout that sets the output
+// parameter with the given name to the given value.
+func setOutput(name string, value string) {
+	fmt.Print(formatOutput(name, value))
+}
+
+// This function returns the workflow command that sets the output parameter
+// with the given name to the given value.
+func formatOutput(name string, value string) string {
+	return fmt.Sprintf("::set-output name=%s::%s\n", name, value)
+}
+
+// This constant holds the maximum size of an output parameter, which GitHub
+// Actions limits to 1 MB.
+const maxOutputBytes = 1 << 20
+
+// This constant holds the version of the schema of the JSON output
+// parameters. It must be increased whenever the schema of one of them changes
+// in a way that is not backwards compatible.
+const schemaVersion = 1
+
+// This variable holds the sentinel appended to a JSON array that has been
+// truncated to fit into an output parameter.
+var truncationSentinel = []byte(`{"truncated":true}`)
+
+// This function marshals the given value as JSON, wrapped with the schema
+// version, and sets the output parameter with the given name to it. Lists that
+// exceed the size limit of output parameters are truncated, in which case a
+// warning is printed.
+func setJSONOutput(name string, value interface{}) error {
+	data, err := formatJSONOutput(name, value)
+	if err != nil {
+		return err
+	}
+	setOutput(name, data)
+	return nil
+}
+
+// This function returns the given value marshalled as JSON and wrapped with
+// the schema version, as it is set as the output parameter with the given
+// name. Lists that exceed the size limit of output parameters are truncated,
+// in which case a warning is printed.
+func formatJSONOutput(name string, value interface{}) (string, error) {
+	data, err := json.Marshal(value)
+	if err != nil {
+		return "", err
+	}
+
+	// Truncate lists so that they fit into the output parameter together with
+	// the schema version they are wrapped with.
+	wrapperBytes := len(fmt.Sprintf(`{"schemaVersion":%d,"data":}`, schemaVersion))
+	if truncated := truncateJSONOutput(data, maxOutputBytes-wrapperBytes); len(truncated) != len(data) {
+		fmt.Printf("::warning::entity output was truncated: %s\n", name)
+		data = truncated
+	}
+
+	data, err = wrapWithSchema(json.RawMessage(data), schemaVersion)
+	if err != nil {
+		return "", err
+	}
+	return string(data), nil
+}
+
+// This function marshals the given value as JSON wrapped with the given schema
+// version, so that consumers of the JSON output parameters can tell which
+// schema they are parsing. The schemaVersion field is always the first field.
+// The fields of a JSON object follow it at the top level in their original
+// order, any other value, e.g. a list, is wrapped in the data field:
+// {"schemaVersion":1,"data":[...]}. The JSON is written by hand, as
+// marshalling a map would sort the schemaVersion field among the others.
+func wrapWithSchema(v interface{}, version int) ([]byte, error) {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return nil, err
+	}
+
+	wrapped := []byte(fmt.Sprintf(`{"schemaVersion":%d`, version))
+	var fields map[string]json.RawMessage
+	if json.Unmarshal(data, &fields) != nil || fields == nil {
+		// Wrap anything but a JSON object in the data field.
+		wrapped = append(wrapped, `,"data":`...)
+		wrapped = append(wrapped, data...)
+		return append(wrapped, '}'), nil
+	}
+
+	// Append the fields of the JSON object after the schemaVersion field by
+	// replacing its opening brace. An empty object has no fields to append.
+	object := bytes.TrimSpace(data)
+	if len(fields) == 0 {
+		return append(wrapped, '}'), nil
+	}
+	wrapped = append(wrapped, ',')
+	return append(wrapped, object[1:]...), nil
+}
+
+// This function truncates the given JSON array so that it fits into maxBytes,
+// including the truncation sentinel {"truncated":true}, which is appended as
+// the last element. Data that already fits or is not a JSON array is returned
+// unchanged.
+func truncateJSONOutput(data []byte, maxBytes int) []byte {
+	if len(data) <= maxBytes {
+		return data
+	}
+	var elements []json.RawMessage
+	if err := json.Unmarshal(data, &elements); err != nil {
+		return data
+	}
+
+	// Keep as many elements as fit into maxBytes together with the brackets,
+	// the separators and the sentinel.
+	size := len("[]") + len(truncationSentinel)
+	truncated := []byte("[")
+	for _, element := range elements {
+		if size+len(element)+len(",") > maxBytes {
+			break
+		}
+		size += len(element) + len(",")
+		truncated = append(truncated, element...)
+		truncated = append(truncated, ',')
+	}
+	truncated = append(truncated, truncationSentinel...)
+	return append(truncated, ']')
+}
+
+// This struct holds the fields of the entity available to the template
+// specified in the entity_permalink_format input parameter.
+type permalinkData struct {
+	GUID      string
+	Name      string
+	AccountID int
+	Region    string
+}
+
+// This function writes the resolved entities in the output format specified
+// in the output_format input parameter, which defaults to gha, the output
+// parameters of the action. The formats are looked up in outputFormatters.
+// The formatted entities are written to the output file, if any, and printed
+// to stdout otherwise.
+func writeEntityOutputs(config Config, entities []Entity) error {
+	applicationEntity := entities[0]
+
+	// Validate the entityJSON output parameter against the JSON Schema
+	// specified in the output_json_schema_file input parameter before any
+	// output parameter is set.
+	if config.OutputJSONSchemaFile != "" {
+		schema, err := loadJSONSchema(config.OutputJSONSchemaFile)
+		if err != nil {
+			return err
+		}
+		entityJSON, err := wrapWithSchema(applicationEntity, schemaVersion)
+		if err != nil {
+			return err
+		}
+		if err := validateJSONSchema(schema, json.RawMessage(entityJSON)); err != nil {
+			return fmt.Errorf("entityJSON: %w", err)
+		}
+	}
+
+	// Format the entities in the output format. The k8s-configmap format is
+	// written by writeConfigMapFile in addition to the output parameters.
+	outputFormat := config.OutputFormat
+	if _, ok := outputFormatters[outputFormat]; !ok {
+		outputFormat = "gha"
+	}
+
+	// Decide once whether the formatted entities are written to the output
+	// file or to stdout. The output file of the k8s-configmap format is
+	// reserved for the ConfigMap, so its output parameters are always printed
+	// to stdout. The formatters only see the output file they write to.
+	formatConfig := config
+	if outputFormat != config.OutputFormat {
+		formatConfig.OutputFile = ""
+	}
+
+	// Set the output parameters of the action whatever the output format, so
+	// that later steps can always read appGUID and the other parameters. The
+	// gha format sets them itself.
+	if outputFormat != "gha" {
+		stdoutConfig := config
+		stdoutConfig.OutputFile = ""
+		parameters, err := GHAFormatter{}.Format(entities, stdoutConfig)
+		if err != nil {
+			return fmt.Errorf("formatting the entities as gha: %w", err)
+		}
+		if _, err := os.Stdout.Write(parameters); err != nil {
+			return err
+		}
+	}
+
+	data, err := outputFormatters[outputFormat].Format(entities, formatConfig)
+	if err != nil {
+		return fmt.Errorf("formatting the entities as %s: %w", outputFormat, err)
+	}
+
+	// Write the formatted entities to the output file, if any, and to stdout
+	// otherwise.
+	if formatConfig.OutputFile != "" {
+		return os.WriteFile(formatConfig.OutputFile, data, 0644)
+	}
+	_, err = os.Stdout.Write(data)
+	return err
+}
+
+// This function returns the names and values of the output parameters of the
+// resolved entities in the order they are set. In batch mode, appGUID holds
+// the GUIDs of all app IDs in the order of the app IDs. If the allow_multiple
+// input parameter is set, the GUIDs and names of all entities are included as
+// well. The components of the GUID of the application entity are only
+// included if the decode_guid input parameter is set.
+func entityOutputParameters(config Config, entities []Entity) ([][2]string, error) {
+	applicationEntity := entities[0]
+	var parameters [][2]string
+
+	// Add the GUID of the application entity, or of all app IDs in batch mode.
+	if len(config.AppIDs) > 1 {
+		var guids []string
+		for _, entity := range entities {
+			guids = append(guids, entity.GUID)
+		}
+		parameters = append(parameters, [2]string{"appGUID", strings.Join(guids, ",")})
+	} else {
+		parameters = append(parameters, [2]string{"appGUID", applicationEntity.GUID})
+	}
+
+	// Add the application entity as JSON.
+	entityJSON, err := formatJSONOutput("entityJSON", applicationEntity)
+	if err != nil {
+		return nil, err
+	}
+	parameters = append(parameters, [2]string{"entityJSON", entityJSON})
+
+	// Add the link to the page of the NewRelic UI specified in the
+	// permalink_type input parameter.
+	permalink, err := newrelicguid.FormatGUIDAsURL(applicationEntity.GUID, config.Region, config.PermalinkType)
+	if err != nil {
+		return nil, err
+	}
+	parameters = append(parameters, [2]string{"permalink", permalink})
+
+	// Add the link rendered from the template specified in the
+	// entity_permalink_format input parameter, if any.
+	if config.PermalinkTemplate != nil {
+		var entityPermalink strings.Builder
+		err := config.PermalinkTemplate.Execute(&entityPermalink, permalinkData{
+			GUID:      applicationEntity.GUID,
+			Name:      applicationEntity.Name,
+			AccountID: applicationEntity.AccountID,
+			Region:    config.Region.String(),
+		})
+		if err != nil {
+			return nil, err
+		}
+		parameters = append(parameters, [2]string{"entityPermalink", entityPermalink.String()})
+	}
+
+	// Add the GUIDs and names of all matching entities.
+	if config.AllowMultiple {
+		var guids, names []string
+		for _, entity := range entities {
+			guids = append(guids, entity.GUID)
+			names = append(names, entity.Name)
+		}
+		parameters = append(parameters,
+			[2]string{"appGUIDs", strings.Join(guids, config.MultiValueDelimiter)},
+			[2]string{"appNames", strings.Join(names, config.MultiValueDelimiter)},
+		)
+	}
+
+	// Add the components of the GUID.
+	if config.DecodeGUID {
+		components, err := DecodeGUID(applicationEntity.GUID)
+		if err != nil {
+			return nil, err
+		}
+		parameters = append(parameters,
+			[2]string{"guidAccountID", strconv.FormatInt(components.AccountID, 10)},
+			[2]string{"guidDomain", components.Domain},
+			[2]string{"guidEntityType", components.EntityType},
+			[2]string{"guidEntityID", components.EntityID},
+		)
+	}
+
+	return parameters, nil
+}
+
+// This function writes the application entity as the manifest of a Kubernetes
+// ConfigMap to the output file if the output_format input parameter is
+// k8s-configmap. The ConfigMap is not a format of the entities, so it is not
+// registered in outputFormatters.
+func writeConfigMapFile(config Config, entities []Entity) error {
+	if config.OutputFormat != configMapOutputFormat {
+		return nil
+	}
+	manifest := renderConfigMap(config.ConfigMapName, config.ConfigMapNamespace, config.AppID, entities[0])
+	return os.WriteFile(config.OutputFile, manifest, 0644)
+}
+
+// This function renders the given entity as the YAML manifest of a
+// Kubernetes ConfigMap. All values are written as double-quoted strings, which
+// are escaped the same way in YAML as they are in JSON.
+func renderConfigMap(name string, namespace string, newrelicAppID string, entity Entity) []byte {
+	// Quote a value so that it can be safely used in the YAML manifest.
+	quote := func(value string) string {
+		quoted, _ := json.Marshal(value)
+		return string(quoted)
+	}
+
+	// Write the ConfigMap boilerplate and metadata.
+	var manifest bytes.Buffer
+	manifest.WriteString("apiVersion: v1\n")
+	manifest.WriteString("kind: ConfigMap\n")
+	manifest.WriteString("metadata:\n")
+	fmt.Fprintf(&manifest, "  name: %s\n", quote(name))
+	if namespace != "" {
+		fmt.Fprintf(&manifest, "  namespace: %s\n", quote(namespace))
+	}
+
+	// Write the entity data.
+	manifest.WriteString("data:\n")
+	fmt.Fprintf(&manifest, "  newrelicAppID: %s\n", quote(newrelicAppID))
+	fmt.Fprintf(&manifest, "  newrelicGUID: %s\n", quote(entity.GUID))
+	fmt.Fprintf(&manifest, "  newrelicEntityName: %s\n", quote(entity.Name))
+	fmt.Fprintf(&manifest, "  newrelicEntityType: %s\n", quote(entity.EntityType))
+
+	return manifest.Bytes()
+}
+
+// This function returns the temporary directory of the runner, or the
+// system's temporary directory when not running on a runner.
+func runnerTempDir() string {
+	if dir := os.Getenv("RUNNER_TEMP"); dir != "" {
+		return dir
+	}
+	return os.TempDir()
+}
+
+// This function writes the status of the run and the app ID it was run for as
+// JSON to the status file in the temporary directory of the runner, where it
+// can be read by a post step.
+func writeStatusFile(status string, newrelicAppID string) error {
+	data, err := json.Marshal(struct {
+		Status string `json:"status"`
+		AppID  string `json:"appId"`
+	}{Status: status, AppID: newrelicAppID})
+	if err != nil {
+		return err
+	}
+
+	return os.WriteFile(filepath.Join(runnerTempDir(), "newrelic-guid-fetcher-status.json"), data, 0644)
+}
+
+// This function appends the given deployments as a Markdown table to the step
+// summary if the GITHUB_STEP_SUMMARY environment variable is set.
+func writeDeploymentsSummary(deployments []Deployment) error {
+	stepSummary := os.Getenv("GITHUB_STEP_SUMMARY")
+	if stepSummary == "" {
+		return nil
+	}
+
+	// Escape the characters that would break a Markdown table cell.
+	cell := strings.NewReplacer("|", "\\|", "\n", " ", "\r", "").Replace
+
+	var markdown strings.Builder
+	markdown.WriteString("### NewRelic deployments\n\n")
+	if len(deployments) == 0 {
+		markdown.WriteString("No deployments found.\n")
+		return appendToFile(stepSummary, markdown.String())
+	}
+	markdown.WriteString("| Version | Timestamp | User | Description |\n")
+	markdown.WriteString("|---------|-----------|------|-------------|\n")
+	for _, deployment := range deployments {
+		fmt.Fprintf(&markdown, "| %s | %s | %s | %s |\n", cell(deployment.Version), cell(deployment.Timestamp), cell(deployment.User), cell(deployment.Description))
+	}
+	return appendToFile(stepSummary, markdown.String())
+}
+
+// This function appends the given NRQL queries as a code block to the step
+// summary if the GITHUB_STEP_SUMMARY environment variable is set. Each query
+// is preceded by its description as a comment.
+func writeNRQLExamplesSummary(examples []NRQLExample) error {
+	stepSummary := os.Getenv("GITHUB_STEP_SUMMARY")
+	if stepSummary == "" {
+		return nil
+	}
+
+	var markdown strings.Builder
+	markdown.WriteString("### NewRelic NRQL examples\n\n```sql\n")
+	for i, example := range examples {
+		if i > 0 {
+			markdown.WriteString("\n")
+		}
+		fmt.Fprintf(&markdown, "-- %s\n%s\n", example.Description, example.NRQL)
+	}
+	markdown.WriteString("```\n")
+	return appendToFile(stepSummary, markdown.String())
+}
@@ -0,0 +1,195 @@
+package main
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+)
+
+func TestWrapWithSchema(t *testing.T) {
+	tests := []struct {
+		name  string
+		value interface{}
+		want  string
+	}{
+		{
+			name:  "struct",
+			value: EntityTag{Key: "team", Values: []string{"checkout"}},
+			want:  `{"schemaVersion":1,"key":"team","values":["checkout"]}`,
+		},
+		{
+			name:  "object keeps the order of its fields",
+			value: json.RawMessage(`{"b":1,"a":2}`),
+			want:  `{"schemaVersion":1,"b":1,"a":2}`,
+		},
+		{
+			name:  "empty object",
+			value: map[string]string{},
+			want:  `{"schemaVersion":1}`,
+		},
+		{
+			name:  "list",
+			value: []int{1, 2},
+			want:  `{"schemaVersion":1,"data":[1,2]}`,
+		},
+		{
+			name:  "string",
+			value: "value",
+			want:  `{"schemaVersion":1,"data":"value"}`,
+		},
+		{
+			name:  "null",
+			value: nil,
+			want:  `{"schemaVersion":1,"data":null}`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := wrapWithSchema(tt.value, 1)
+			if err != nil {
+				t.Fatalf("wrapWithSchema() error = %v", err)
+			}
+			if string(got) != tt.want {
+				t.Errorf("wrapWithSchema() = %s, want %s", got, tt.want)
+			}
+			if !json.Valid(got) {
+				t.Errorf("wrapWithSchema() = %s, which is not valid JSON", got)
+			}
+		})
+	}
+}
+
+func TestTruncateJSONOutput(t *testing.T) {
+	tests := []struct {
+		name     string
+		data     string
+		maxBytes int
+		want     string
+	}{
+		{
+			name:     "array that fits",
+			data:     `[{"a":1},{"b":2}]`,
+			maxBytes: 17,
+			want:     `[{"a":1},{"b":2}]`,
+		},
+		{
+			name:     "array over the limit",
+			data:     `[{"a":1},{"b":2},{"c":3},{"d":4}]`,
+			maxBytes: 30,
+			want:     `[{"a":1},{"truncated":true}]`,
+		},
+		{
+			name:     "array truncated exactly at the limit",
+			data:     `[{"a":1},{"b":2},{"c":3},{"d":4},{"e":5},{"f":6}]`,
+			maxBytes: len(`[{"a":1},{"b":2},{"truncated":true}]`),
+			want:     `[{"a":1},{"b":2},{"truncated":true}]`,
+		},
+		{
+			name:     "single element larger than the limit",
+			data:     `[{"name":"` + strings.Repeat("x", 100) + `"}]`,
+			maxBytes: 50,
+			want:     `[{"truncated":true}]`,
+		},
+		{
+			name:     "object over the limit",
+			data:     `{"name":"` + strings.Repeat("x", 100) + `"}`,
+			maxBytes: 50,
+			want:     `{"name":"` + strings.Repeat("x", 100) + `"}`,
+		},
+		{
+			name:     "invalid JSON over the limit",
+			data:     `[{"a":1},`,
+			maxBytes: 5,
+			want:     `[{"a":1},`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := truncateJSONOutput([]byte(tt.data), tt.maxBytes)
+			if string(got) != tt.want {
+				t.Errorf("truncateJSONOutput() = %s, want %s", got, tt.want)
+			}
+
+			// Truncated arrays must be valid JSON within the limit and end with
+			// the truncation sentinel.
+			if len(got) != len(tt.data) {
+				if len(got) > tt.maxBytes {
+					t.Errorf("truncateJSONOutput() returned %d bytes, want at most %d", len(got), tt.maxBytes)
+				}
+				if !strings.HasSuffix(string(got), `,{"truncated":true}]`) && string(got) != `[{"truncated":true}]` {
+					t.Errorf("truncateJSONOutput() = %s, want it to end with the truncation sentinel", got)
+				}
+				if !json.Valid(got) {
+					t.Errorf("truncateJSONOutput() = %s, which is not valid JSON", got)
+				}
+			}
+		})
+	}
+}
+
+func TestFormatJSONOutputTruncatesToOutputLimit(t *testing.T) {
+	// Marshal a list exceeding the size limit of output parameters.
+	entities := make([]Entity, 20000)
+	for i := range entities {
+		entities[i] = Entity{AccountID: 1, EntityType: "APM_APPLICATION_ENTITY", Name: strings.Repeat("x", 40), GUID: "MXxBUE18QVBQTElDQVRJT058MQ"}
+	}
+
+	got, err := formatJSONOutput("entities", entities)
+	if err != nil {
+		t.Fatalf("formatJSONOutput() error = %v", err)
+	}
+	if len(got) > maxOutputBytes {
+		t.Errorf("formatJSONOutput() returned %d bytes, want at most %d", len(got), maxOutputBytes)
+	}
+	if !strings.HasPrefix(got, `{"schemaVersion":1,"data":[`) || !strings.HasSuffix(got, `{"truncated":true}]}`) {
+		t.Errorf("formatJSONOutput() = %s...%s, want a truncated list wrapped with the schema version", got[:40], got[len(got)-40:])
+	}
+}
+
+func TestRenderConfigMap(t *testing.T) {
+	entity := Entity{EntityType: "APM_APPLICATION_ENTITY", Name: `checkout "eu"`, GUID: "MXxBUE18QVBQTElDQVRJT058MQ"}
+	tests := []struct {
+		name      string
+		namespace string
+		want      string
+	}{
+		{
+			name: "without namespace",
+			want: `apiVersion: v1
+kind: ConfigMap
+metadata:
+  name: "newrelic-entity"
+data:
+  newrelicAppID: "123"
+  newrelicGUID: "MXxBUE18QVBQTElDQVRJT058MQ"
+  newrelicEntityName: "checkout \"eu\""
+  newrelicEntityType: "APM_APPLICATION_ENTITY"
+`,
+		},
+		{
+			name:      "with namespace",
+			namespace: "checkout",
+			want: `apiVersion: v1
+kind: ConfigMap
+metadata:
+  name: "newrelic-entity"
+  namespace: "checkout"
+data:
+  newrelicAppID: "123"
+  newrelicGUID: "MXxBUE18QVBQTElDQVRJT058MQ"
+  newrelicEntityName: "checkout \"eu\""
+  newrelicEntityType: "APM_APPLICATION_ENTITY"
+`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := string(renderConfigMap("newrelic-entity", tt.namespace, "123", entity)); got != tt.want {
+				t.Errorf("renderConfigMap() = %s, want %s", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,64 @@
+package newrelicguid
+
+import "context"
+
+// This interface describes the entity searches of the client the action uses
+// to fetch GUIDs, so that Go programs can depend on it and replace it with a
+// MockClient in their tests.
+type Client interface {
+	// This method returns the GraphQL response of the entity search for the
+	// given app ID.
+	GetGUID(ctx context.Context, appID string) (GraphQL, error)
+
+	// This method returns the GraphQL response of the given entity search
+	// query. The fields requested for each entity are controlled by the given
+	// options.
+	Search(ctx context.Context, searchQuery string, options ...ProjectionOption) (GraphQL, error)
+}
+
+// This type is an option of Client.Search that controls which fields of the
+// matching entities are requested from the New Relic API.
+type ProjectionOption func(*Projection)
+
+// This struct holds the fields of the entities requested by an entity search,
+// in the syntax of a GraphQL selection set.
+type Projection struct {
+	Fields string
+}
+
+// These constants hold the fields of the entities requested by an entity
+// search. The default fields are requested unless a ProjectionOption is
+// given.
+const (
+	defaultProjectionFields  = `accountId entityType name guid deleted reporting lastReportingChangeAt ... on ApmApplicationEntityOutline { language }`
+	guidOnlyProjectionFields = `guid`
+	fullProjectionFields     = `accountId entityType name guid deleted reporting lastReportingChangeAt reportingEventTypes tags { key values } ... on ApmApplicationEntityOutline { language }`
+)
+
+// This function returns the projection resulting from applying the given
+// options to the default projection, which requests the account ID, type,
+// name, GUID, deletion and reporting status and language of the entities.
+func NewProjection(options ...ProjectionOption) Projection {
+	projection := Projection{Fields: defaultProjectionFields}
+	for _, option := range options {
+		option(&projection)
+	}
+	return projection
+}
+
+// This function returns a ProjectionOption that only requests the GUIDs of the
+// entities, which reduces the size of the response.
+func WithGUIDOnly() ProjectionOption {
+	return func(p *Projection) {
+		p.Fields = guidOnlyProjectionFields
+	}
+}
+
+// This function returns a ProjectionOption that requests the full metadata of
+// the entities: in addition to the default fields, the event types they
+// report and their tags.
+func WithFullMetadata() ProjectionOption {
+	return func(p *Projection) {
+		p.Fields = fullProjectionFields
+	}
+}
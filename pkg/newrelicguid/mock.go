@@ -0,0 +1,89 @@
+package newrelicguid
+
+import (
+	"context"
+	"sync"
+)
+
+// This struct holds a call of a MockClient method and the arguments it has
+// been called with, apart from the context.
+type MockCall struct {
+	Method string
+	Args   []interface{}
+}
+
+// This struct is a Client that returns pre-configured entities or an error
+// instead of calling the New Relic API, and records all calls, so that Go
+// programs depending on a Client can be tested without an API key. It is safe
+// for concurrent use.
+type MockClient struct {
+	mu       sync.Mutex
+	entities []Entity
+	err      error
+	calls    []MockCall
+}
+
+// This variable makes sure that MockClient implements the Client interface.
+var _ Client = (*MockClient)(nil)
+
+// This function returns a new MockClient, which returns no entities until it
+// is configured otherwise.
+func NewMockClient() *MockClient {
+	return &MockClient{entities: []Entity{}}
+}
+
+// This function adds the given entity to the entities returned by every call.
+// It returns the MockClient, so that calls can be chained.
+func (m *MockClient) WillReturnEntity(entity Entity) *MockClient {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.entities = append(m.entities, entity)
+	return m
+}
+
+// This function makes every call return the given error instead of the
+// entities. It returns the MockClient, so that calls can be chained.
+func (m *MockClient) WillReturnError(err error) *MockClient {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.err = err
+	return m
+}
+
+// This function records the call and returns the configured entities or
+// error. The app ID is echoed as the query of the response.
+func (m *MockClient) GetGUID(ctx context.Context, appID string) (GraphQL, error) {
+	return m.respond("GetGUID", appID, []interface{}{appID})
+}
+
+// This function records the call and returns the configured entities or
+// error. The options are recorded as the projection they result in, and the
+// search query is echoed as the query of the response.
+func (m *MockClient) Search(ctx context.Context, searchQuery string, options ...ProjectionOption) (GraphQL, error) {
+	return m.respond("Search", searchQuery, []interface{}{searchQuery, NewProjection(options...)})
+}
+
+// This function returns the calls recorded so far, in the order they have
+// been made.
+func (m *MockClient) Calls() []MockCall {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return append([]MockCall{}, m.calls...)
+}
+
+// This function records a call of the given method with the given arguments
+// and returns the configured response with the given query.
+func (m *MockClient) respond(method string, query string, args []interface{}) (GraphQL, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.calls = append(m.calls, MockCall{Method: method, Args: args})
+	if m.err != nil {
+		return GraphQL{}, m.err
+	}
+
+	var response GraphQL
+	response.Data.Actor.EntitySearch.Count = len(m.entities)
+	response.Data.Actor.EntitySearch.Query = query
+	response.Data.Actor.EntitySearch.Results.Entities = append([]Entity{}, m.entities...)
+	return response, nil
+}
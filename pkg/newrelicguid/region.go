@@ -0,0 +1,64 @@
+package newrelicguid
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// This type holds the region a New Relic account is running in. Each region
+// has its own API endpoint and UI.
+type Region string
+
+// These constants hold the regions supported by New Relic.
+const (
+	RegionUS  Region = "US"
+	RegionEU  Region = "EU"
+	RegionGov Region = "GOV"
+)
+
+// This error is returned if the region is not one of the regions supported by
+// New Relic.
+var ErrInvalidRegion = errors.New("invalid NewRelic region")
+
+// This function parses the given region, e.g. US or EU. The region is not case
+// sensitive and surrounding whitespace is ignored.
+func ParseRegion(s string) (Region, error) {
+	region := Region(strings.ToUpper(strings.TrimSpace(s)))
+	switch region {
+	case RegionUS, RegionEU, RegionGov:
+		return region, nil
+	}
+
+	// If the region is not supported, return an error.
+	return "", fmt.Errorf("%w: %q", ErrInvalidRegion, s)
+}
+
+// This function returns the GraphQL endpoint of the New Relic API in the
+// region.
+func (r Region) APIEndpoint() string {
+	switch r {
+	case RegionEU:
+		return "https://api.eu.newrelic.com/graphql"
+	case RegionGov:
+		return "https://gov-api.newrelic.com/graphql"
+	}
+	return "https://api.newrelic.com/graphql"
+}
+
+// This function returns the base URL of the New Relic UI in the region, which
+// dashboard and entity links start with.
+func (r Region) DashboardBaseURL() string {
+	switch r {
+	case RegionEU:
+		return "https://one.eu.newrelic.com"
+	case RegionGov:
+		return "https://gov-one.newrelic.com"
+	}
+	return "https://one.newrelic.com"
+}
+
+// This function returns the region as string, e.g. US.
+func (r Region) String() string {
+	return string(r)
+}
@@ -0,0 +1,48 @@
+// Package newrelicguid provides the types of the New Relic API used to fetch
+// the GUID of an entity, so that Go programs can work with the entities
+// fetched by the action.
+package newrelicguid
+
+// This struct is used to unmarshal the JSON returned by the New Relic API.
+type GraphQL struct {
+	Data struct {
+		Actor struct {
+			EntitySearch struct {
+				Count   int    `json:"count"`
+				Query   string `json:"query"`
+				Results struct {
+					Entities   []Entity `json:"entities"`
+					NextCursor *string  `json:"nextCursor"`
+				} `json:"results"`
+			} `json:"entitySearch"`
+		} `json:"actor"`
+	} `json:"data"`
+}
+
+// This struct holds a single entity returned by the New Relic API.
+type Entity struct {
+	AccountID  int    `json:"accountId"`
+	EntityType string `json:"entityType"`
+	GUID       string `json:"guid"`
+	Name       string `json:"name"`
+	Permalink  string `json:"permalink,omitempty"`
+	Language   string `json:"language,omitempty"`
+	Deleted    bool   `json:"deleted,omitempty"`
+
+	// These fields tell whether the entity is reporting data and when it
+	// last started or stopped reporting, in milliseconds since the epoch.
+	Reporting             *bool `json:"reporting,omitempty"`
+	LastReportingChangeAt int64 `json:"lastReportingChangeAt,omitempty"`
+
+	// These fields are only returned if the full metadata of the entity has
+	// been requested.
+	ReportingEventTypes []string    `json:"reportingEventTypes,omitempty"`
+	Tags                []EntityTag `json:"tags,omitempty"`
+}
+
+// This struct holds a single tag of an entity returned by the New Relic API.
+// A tag key can have more than one value.
+type EntityTag struct {
+	Key    string   `json:"key"`
+	Values []string `json:"values"`
+}
@@ -0,0 +1,54 @@
+package newrelicguid
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// This type holds a page of the New Relic UI an entity can be opened in.
+type PageType string
+
+// These constants hold the pages of the New Relic UI an entity can be opened
+// in.
+const (
+	PageSummary            PageType = "summary"
+	PageDistributedTracing PageType = "distributed-tracing"
+	PageServiceMap         PageType = "service-map"
+	PageDashboards         PageType = "dashboards"
+)
+
+// This error is returned if the page type is not one of the supported pages.
+var ErrInvalidPageType = errors.New("invalid New Relic page type")
+
+// This map holds the path of each page in the New Relic UI. The GUID of the
+// entity is appended to the path.
+var pagePaths = map[PageType]string{
+	PageSummary:            "/redirect/entity/",
+	PageDistributedTracing: "/nr1-core/apm-features/distributed-tracing/",
+	PageServiceMap:         "/nr1-core/apm-features/service-map/",
+	PageDashboards:         "/nr1-core/entity-dashboards/",
+}
+
+// This function parses the given page type, e.g. summary or service-map. The
+// page type is not case sensitive and surrounding whitespace is ignored.
+func ParsePageType(s string) (PageType, error) {
+	page := PageType(strings.ToLower(strings.TrimSpace(s)))
+	if _, ok := pagePaths[page]; !ok {
+		return "", fmt.Errorf("%w: %q", ErrInvalidPageType, s)
+	}
+	return page, nil
+}
+
+// This function returns the URL of the given page of the New Relic UI for the
+// entity with the given GUID in the given region.
+func FormatGUIDAsURL(guid string, region Region, page PageType) (string, error) {
+	if guid == "" {
+		return "", errors.New("entity GUID is empty")
+	}
+	path, ok := pagePaths[page]
+	if !ok {
+		return "", fmt.Errorf("%w: %q", ErrInvalidPageType, page)
+	}
+	return region.DashboardBaseURL() + path + guid, nil
+}
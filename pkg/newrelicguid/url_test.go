@@ -0,0 +1,98 @@
+package newrelicguid
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestFormatGUIDAsURL(t *testing.T) {
+	const guid = "MXxBUE18QVBQTElDQVRJT058MQ"
+	tests := []struct {
+		region Region
+		page   PageType
+		want   string
+	}{
+		{RegionUS, PageSummary, "https://one.newrelic.com/redirect/entity/" + guid},
+		{RegionUS, PageDistributedTracing, "https://one.newrelic.com/nr1-core/apm-features/distributed-tracing/" + guid},
+		{RegionUS, PageServiceMap, "https://one.newrelic.com/nr1-core/apm-features/service-map/" + guid},
+		{RegionUS, PageDashboards, "https://one.newrelic.com/nr1-core/entity-dashboards/" + guid},
+		{RegionEU, PageSummary, "https://one.eu.newrelic.com/redirect/entity/" + guid},
+		{RegionEU, PageDistributedTracing, "https://one.eu.newrelic.com/nr1-core/apm-features/distributed-tracing/" + guid},
+		{RegionEU, PageServiceMap, "https://one.eu.newrelic.com/nr1-core/apm-features/service-map/" + guid},
+		{RegionEU, PageDashboards, "https://one.eu.newrelic.com/nr1-core/entity-dashboards/" + guid},
+		{RegionGov, PageSummary, "https://gov-one.newrelic.com/redirect/entity/" + guid},
+		{RegionGov, PageDistributedTracing, "https://gov-one.newrelic.com/nr1-core/apm-features/distributed-tracing/" + guid},
+		{RegionGov, PageServiceMap, "https://gov-one.newrelic.com/nr1-core/apm-features/service-map/" + guid},
+		{RegionGov, PageDashboards, "https://gov-one.newrelic.com/nr1-core/entity-dashboards/" + guid},
+	}
+
+	// Every page must be covered in every region.
+	if len(tests) != 3*len(pagePaths) {
+		t.Fatalf("%d cases, want one per region and page (%d)", len(tests), 3*len(pagePaths))
+	}
+
+	for _, tt := range tests {
+		t.Run(string(tt.region)+"/"+string(tt.page), func(t *testing.T) {
+			got, err := FormatGUIDAsURL(guid, tt.region, tt.page)
+			if err != nil {
+				t.Fatalf("FormatGUIDAsURL() error = %v", err)
+			}
+			if got != tt.want {
+				t.Errorf("FormatGUIDAsURL() = %s, want %s", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestFormatGUIDAsURLErrors(t *testing.T) {
+	tests := []struct {
+		name     string
+		guid     string
+		page     PageType
+		wantPage bool
+	}{
+		{name: "empty GUID", page: PageSummary},
+		{name: "empty page", guid: "MXxBUE18QVBQTElDQVRJT058MQ", wantPage: true},
+		{name: "unknown page", guid: "MXxBUE18QVBQTElDQVRJT058MQ", page: "logs", wantPage: true},
+		{name: "page not parsed", guid: "MXxBUE18QVBQTElDQVRJT058MQ", page: "Summary", wantPage: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := FormatGUIDAsURL(tt.guid, RegionUS, tt.page)
+			if err == nil {
+				t.Fatal("FormatGUIDAsURL() error = nil, want an error")
+			}
+			if errors.Is(err, ErrInvalidPageType) != tt.wantPage {
+				t.Errorf("FormatGUIDAsURL() error = %v, want ErrInvalidPageType %v", err, tt.wantPage)
+			}
+		})
+	}
+}
+
+func TestParsePageType(t *testing.T) {
+	tests := []struct {
+		input   string
+		want    PageType
+		wantErr bool
+	}{
+		{input: "summary", want: PageSummary},
+		{input: " Service-Map ", want: PageServiceMap},
+		{input: "DISTRIBUTED-TRACING", want: PageDistributedTracing},
+		{input: "dashboards", want: PageDashboards},
+		{input: "service map", wantErr: true},
+		{input: "", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.input, func(t *testing.T) {
+			got, err := ParsePageType(tt.input)
+			if (err != nil) != tt.wantErr || got != tt.want {
+				t.Errorf("ParsePageType() = %q, %v, want %q, error %v", got, err, tt.want, tt.wantErr)
+			}
+			if tt.wantErr && !errors.Is(err, ErrInvalidPageType) {
+				t.Errorf("ParsePageType() error = %v, want ErrInvalidPageType", err)
+			}
+		})
+	}
+}
@@ -0,0 +1,250 @@
+// Package newrelicguid is version 2 of the library of the action. Unlike
+// version 1, whose entities have a fixed set of fields, it decodes the
+// entities of an entity search into a type chosen by the caller:
+//
+//	type App struct {
+//		GUID      string `json:"guid"`
+//		Name      string `json:"name"`
+//		Reporting bool   `json:"reporting"`
+//		Tags      []Tag  `json:"tags"`
+//	}
+//
+//	client := newrelicguid.NewClient(endpoint, apiKey)
+//	apps, err := newrelicguid.SearchEntities[App](ctx, client, "name = 'my-app'")
+//
+// The fields requested for each entity are derived from the JSON tags of the
+// type, unless they are specified with WithFields.
+package newrelicguid
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+)
+
+// This interface describes the HTTP client used to send requests to the New
+// Relic API. It is implemented by *http.Client.
+type HTTPDoer interface {
+	Do(req *http.Request) (*http.Response, error)
+}
+
+// This struct is a client of the GraphQL endpoint of the New Relic API.
+type Client struct {
+	Endpoint   string
+	APIKey     string
+	HTTPClient HTTPDoer
+}
+
+// This function returns a new Client sending requests to the given GraphQL
+// endpoint with the given API key, using http.DefaultClient.
+func NewClient(endpoint string, apiKey string) *Client {
+	return &Client{Endpoint: endpoint, APIKey: apiKey, HTTPClient: http.DefaultClient}
+}
+
+// This struct holds a single tag of an entity. Unlike in version 1, the
+// values are always a list of strings.
+type Tag struct {
+	Key    string   `json:"key"`
+	Values []string `json:"values"`
+}
+
+// This struct holds the default fields of an entity. It can be used as type
+// parameter of SearchEntities if no custom type is needed.
+type Entity struct {
+	AccountID  int    `json:"accountId"`
+	EntityType string `json:"entityType"`
+	GUID       string `json:"guid"`
+	Name       string `json:"name"`
+	Tags       []Tag  `json:"tags"`
+}
+
+// This struct holds a page of the results of an entity search, with the
+// entities decoded into T. NextCursor is nil on the last page.
+type EntitySearchResult[T any] struct {
+	Count      int     `json:"count"`
+	Query      string  `json:"query"`
+	Entities   []T     `json:"entities"`
+	NextCursor *string `json:"nextCursor"`
+}
+
+// This error is returned if the fields to request cannot be derived from the
+// type parameter because it is not a struct.
+var ErrNoFields = errors.New("cannot derive the fields to request from the entity type, use WithFields")
+
+// This type is an option of Search and SearchEntities.
+type SearchOption func(*searchOptions)
+
+// This struct holds the options of an entity search.
+type searchOptions struct {
+	fields string
+}
+
+// This function returns a SearchOption that requests the given fields of the
+// entities, in the syntax of a GraphQL selection set, instead of the fields
+// derived from the type parameter. It is needed for fields of inline
+// fragments, e.g. `guid ... on ApmApplicationEntityOutline { language }`.
+func WithFields(fields string) SearchOption {
+	return func(o *searchOptions) {
+		o.fields = fields
+	}
+}
+
+// This function returns the first page of the results of the given entity
+// search, e.g. "domain = 'APM' AND name = 'my-app'", with the entities decoded
+// into T.
+func Search[T any](ctx context.Context, c *Client, query string, options ...SearchOption) (EntitySearchResult[T], error) {
+	fields, err := searchFields[T](options)
+	if err != nil {
+		return EntitySearchResult[T]{}, err
+	}
+
+	searchQuery := fmt.Sprintf(`query($query: String!) { actor { entitySearch(query: $query) { count query results { entities { %s } nextCursor } } } }`, fields)
+	return searchPage[T](ctx, c, searchQuery, map[string]interface{}{"query": query})
+}
+
+// This function returns the entities of all pages of the results of the given
+// entity search, decoded into T.
+func SearchEntities[T any](ctx context.Context, c *Client, query string, options ...SearchOption) ([]T, error) {
+	result, err := Search[T](ctx, c, query, options...)
+	if err != nil {
+		return nil, err
+	}
+	entities := result.Entities
+
+	fields, err := searchFields[T](options)
+	if err != nil {
+		return nil, err
+	}
+
+	// Fetch the following pages until there is no cursor left.
+	pageQuery := fmt.Sprintf(`query($query: String!, $cursor: String) { actor { entitySearch(query: $query) { count query results(cursor: $cursor) { entities { %s } nextCursor } } } }`, fields)
+	for result.NextCursor != nil && *result.NextCursor != "" {
+		result, err = searchPage[T](ctx, c, pageQuery, map[string]interface{}{"query": query, "cursor": *result.NextCursor})
+		if err != nil {
+			return nil, err
+		}
+		entities = append(entities, result.Entities...)
+	}
+
+	return entities, nil
+}
+
+// This function returns the fields to request for each entity: the fields
+// given with WithFields, or the fields derived from the JSON tags of T.
+func searchFields[T any](options []SearchOption) (string, error) {
+	var o searchOptions
+	for _, option := range options {
+		option(&o)
+	}
+	if o.fields != "" {
+		return o.fields, nil
+	}
+
+	var entity T
+	fields := selectionSet(reflectType(entity))
+	if fields == "" {
+		return "", ErrNoFields
+	}
+	return fields, nil
+}
+
+// This function sends the given entity search query with the given variables
+// and decodes the page of results into T.
+func searchPage[T any](ctx context.Context, c *Client, query string, variables map[string]interface{}) (EntitySearchResult[T], error) {
+	var response struct {
+		Data struct {
+			Actor struct {
+				EntitySearch struct {
+					Count   int    `json:"count"`
+					Query   string `json:"query"`
+					Results struct {
+						Entities   []json.RawMessage `json:"entities"`
+						NextCursor *string           `json:"nextCursor"`
+					} `json:"results"`
+				} `json:"entitySearch"`
+			} `json:"actor"`
+		} `json:"data"`
+	}
+	if err := c.query(ctx, query, variables, &response); err != nil {
+		return EntitySearchResult[T]{}, err
+	}
+
+	// Decode each entity into the type chosen by the caller.
+	entitySearch := response.Data.Actor.EntitySearch
+	result := EntitySearchResult[T]{
+		Count:      entitySearch.Count,
+		Query:      entitySearch.Query,
+		Entities:   make([]T, 0, len(entitySearch.Results.Entities)),
+		NextCursor: entitySearch.Results.NextCursor,
+	}
+	for _, raw := range entitySearch.Results.Entities {
+		var entity T
+		if err := json.Unmarshal(raw, &entity); err != nil {
+			return EntitySearchResult[T]{}, fmt.Errorf("decoding entity: %w", err)
+		}
+		result.Entities = append(result.Entities, entity)
+	}
+
+	return result, nil
+}
+
+// This function sends the given GraphQL query with the given variables to the
+// endpoint of the client and unmarshals the response into the given value.
+// GraphQL errors in the response are returned as error.
+func (c *Client) query(ctx context.Context, query string, variables map[string]interface{}, response interface{}) error {
+	data, err := json.Marshal(map[string]interface{}{"query": query, "variables": variables})
+	if err != nil {
+		return err
+	}
+
+	// Create a HTTP POST request to the GraphQL endpoint.
+	req, err := http.NewRequestWithContext(ctx, "POST", c.Endpoint, bytes.NewReader(data))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Api-Key", c.APIKey)
+	req.Header.Set("Content-Type", "application/json")
+
+	// Send the HTTP request using the HTTP client of the client.
+	httpClient := c.HTTPClient
+	if httpClient == nil {
+		httpClient = http.DefaultClient
+	}
+	resp, err := httpClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	// Return an error if the HTTP status code is not 200.
+	if resp.StatusCode != 200 {
+		return fmt.Errorf("querying NewRelic: HTTP status code %d", resp.StatusCode)
+	}
+
+	// Read the body once, to check for GraphQL errors and to unmarshal it.
+	var body bytes.Buffer
+	if _, err := body.ReadFrom(resp.Body); err != nil {
+		return err
+	}
+	var graphqlErrors struct {
+		Errors []struct {
+			Message string `json:"message"`
+		} `json:"errors"`
+	}
+	if err := json.Unmarshal(body.Bytes(), &graphqlErrors); err != nil {
+		return err
+	}
+	if len(graphqlErrors.Errors) > 0 {
+		var messages []string
+		for _, graphqlError := range graphqlErrors.Errors {
+			messages = append(messages, graphqlError.Message)
+		}
+		return fmt.Errorf("querying NewRelic: %s", strings.Join(messages, "; "))
+	}
+
+	return json.Unmarshal(body.Bytes(), response)
+}
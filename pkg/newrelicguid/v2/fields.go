@@ -0,0 +1,69 @@
+package newrelicguid
+
+import (
+	"reflect"
+	"strings"
+)
+
+// This function returns the type of the given value, dereferencing pointers,
+// slices and arrays down to their element type.
+func reflectType(v interface{}) reflect.Type {
+	t := reflect.TypeOf(v)
+	if t == nil {
+		return nil
+	}
+	return elemType(t)
+}
+
+// This function dereferences pointers, slices and arrays of the given type
+// down to their element type.
+func elemType(t reflect.Type) reflect.Type {
+	for t.Kind() == reflect.Pointer || t.Kind() == reflect.Slice || t.Kind() == reflect.Array {
+		t = t.Elem()
+	}
+	return t
+}
+
+// This function returns the GraphQL selection set requesting the fields of
+// the given struct type, named after their JSON tags. Fields of struct type
+// are requested with their own selection set, fields without JSON tag or with
+// the tag "-" are skipped, and the fields of embedded structs are requested
+// as if they were fields of the struct itself. An empty string is returned if
+// the type is not a struct.
+func selectionSet(t reflect.Type) string {
+	if t == nil || t.Kind() != reflect.Struct {
+		return ""
+	}
+
+	var fields []string
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		fieldType := elemType(field.Type)
+
+		// Request the fields of embedded structs without a JSON tag directly.
+		tag := field.Tag.Get("json")
+		if field.Anonymous && tag == "" && fieldType.Kind() == reflect.Struct {
+			if nested := selectionSet(fieldType); nested != "" {
+				fields = append(fields, nested)
+			}
+			continue
+		}
+
+		// Skip unexported fields and fields that are not decoded from JSON.
+		name, _, _ := strings.Cut(tag, ",")
+		if !field.IsExported() || name == "" || name == "-" {
+			continue
+		}
+
+		// Request the fields of nested structs with their own selection set.
+		if fieldType.Kind() == reflect.Struct {
+			if nested := selectionSet(fieldType); nested != "" {
+				fields = append(fields, name+" { "+nested+" }")
+			}
+			continue
+		}
+		fields = append(fields, name)
+	}
+
+	return strings.Join(fields, " ")
+}
@@ -0,0 +1,92 @@
+// Package testhelpers provides mock New Relic API servers for testing Go
+// programs that fetch entities with the newrelicguid package.
+//
+// NewMockNewRelicServer serves a NerdGraph entity search response containing
+// the given entities, NewErrorServer responds to every request with the given
+// HTTP status code and body. Both servers are closed automatically when the
+// test finishes, and their URL can be used as the GraphQL endpoint:
+//
+//	server := testhelpers.NewMockNewRelicServer(t, []newrelicguid.Entity{
+//		{AccountID: 1, EntityType: "APM_APPLICATION_ENTITY", GUID: "MXxBUE18QVBQTElDQVRJT058MQ", Name: "my-app"},
+//	})
+//	// Send the entity search to server.URL.
+package testhelpers
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/zaljic/newrelic-guid-fetcher-action/pkg/newrelicguid"
+)
+
+// This function starts a mock New Relic GraphQL endpoint that answers every
+// entity search with the given entities, in the format returned by NerdGraph.
+// Like NerdGraph, it responds with 401 if the Api-Key header is missing and
+// with 400 if the request is not a POST request with a JSON body containing a
+// query.
+func NewMockNewRelicServer(t testing.TB, entities []newrelicguid.Entity) *httptest.Server {
+	t.Helper()
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		// Reject requests without an API key.
+		if r.Header.Get("Api-Key") == "" {
+			http.Error(w, `{"errors":[{"message":"Api-Key header missing"}]}`, http.StatusUnauthorized)
+			return
+		}
+
+		// Reject requests that are not GraphQL queries.
+		var request struct {
+			Query string `json:"query"`
+		}
+		if r.Method != http.MethodPost || json.NewDecoder(r.Body).Decode(&request) != nil || request.Query == "" {
+			http.Error(w, `{"errors":[{"message":"invalid GraphQL request"}]}`, http.StatusBadRequest)
+			return
+		}
+
+		// Respond with the given entities.
+		var response newrelicguid.GraphQL
+		response.Data.Actor.EntitySearch.Count = len(entities)
+		response.Data.Actor.EntitySearch.Results.Entities = entities
+		if response.Data.Actor.EntitySearch.Results.Entities == nil {
+			response.Data.Actor.EntitySearch.Results.Entities = []newrelicguid.Entity{}
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(response)
+	}))
+	t.Cleanup(server.Close)
+
+	return server
+}
+
+// This function starts a mock New Relic GraphQL endpoint that responds to
+// every request with the given HTTP status code and body, e.g. 401 for an
+// invalid API key or 429 for an exceeded rate limit.
+func NewErrorServer(t testing.TB, statusCode int, body string) *httptest.Server {
+	t.Helper()
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(statusCode)
+		w.Write([]byte(body))
+	}))
+	t.Cleanup(server.Close)
+
+	return server
+}
+
+// This function returns a workload entity in the account with the given ID,
+// as returned by the NerdGraph entity search. The GUID is encoded the same way
+// New Relic encodes the GUIDs of workloads.
+func NewWorkloadEntity(accountID int, workloadID int, name string) newrelicguid.Entity {
+	guid := fmt.Sprintf("%d|NR1|WORKLOAD|%d", accountID, workloadID)
+	return newrelicguid.Entity{
+		AccountID:  accountID,
+		EntityType: "WORKLOAD_ENTITY",
+		GUID:       base64.RawStdEncoding.EncodeToString([]byte(guid)),
+		Name:       name,
+	}
+}
@@ -0,0 +1,61 @@
+//go:build debug
+
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"runtime"
+	"runtime/pprof"
+)
+
+// These variables hold the paths the CPU and memory profiles are written to.
+// The flags are only compiled in with the debug build tag.
+var (
+	cpuProfile = flag.String("cpuprofile", "", "Write a CPU profile to the given file")
+	memProfile = flag.String("memprofile", "", "Write a memory profile to the given file on exit")
+)
+
+// This function starts the CPU profile if the cpuprofile flag is set. The
+// returned function stops the CPU profile and writes the memory profile if the
+// memprofile flag is set. Failing to write a profile is not fatal, in which
+// case a warning is printed.
+func startProfiling() func() {
+	var cpuFile *os.File
+	if *cpuProfile != "" {
+		f, err := os.Create(*cpuProfile)
+		if err == nil {
+			err = pprof.StartCPUProfile(f)
+		}
+		if err != nil {
+			fmt.Printf("::warning::Starting the CPU profile failed: %s\n", err)
+		} else {
+			cpuFile = f
+		}
+	}
+
+	return func() {
+		// Stop the CPU profile.
+		if cpuFile != nil {
+			pprof.StopCPUProfile()
+			cpuFile.Close()
+		}
+
+		// Write the memory profile after a garbage collection, so that it
+		// reflects the live heap.
+		if *memProfile == "" {
+			return
+		}
+		f, err := os.Create(*memProfile)
+		if err != nil {
+			fmt.Printf("::warning::Writing the memory profile failed: %s\n", err)
+			return
+		}
+		defer f.Close()
+		runtime.GC()
+		if err := pprof.WriteHeapProfile(f); err != nil {
+			fmt.Printf("::warning::Writing the memory profile failed: %s\n", err)
+		}
+	}
+}
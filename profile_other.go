@@ -0,0 +1,9 @@
+//go:build !debug
+
+package main
+
+// This function does nothing, as profiling is only compiled in with the debug
+// build tag. The returned function does nothing either.
+func startProfiling() func() {
+	return func() {}
+}
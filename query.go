@@ -0,0 +1,248 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// This struct is used to marshal the body of the HTTP requests sent to the
+// New Relic GraphQL endpoint.
+type graphQLRequest struct {
+	Query         string      `json:"query"`
+	OperationName string      `json:"operationName,omitempty"`
+	Variables     interface{} `json:"variables"`
+	Timeout       int         `json:"timeout,omitempty"`
+}
+
+// This variable holds the pattern of the names of GraphQL operations.
+var graphqlNamePattern = regexp.MustCompile(`^[_A-Za-z][_0-9A-Za-z]*$`)
+
+// This function gives the operation of the given GraphQL query the given
+// name, so that the name can be sent as operationName, which GraphQL servers
+// only accept if the query contains an operation of that name. The query and
+// the name to send are returned. Queries whose operation already has a name
+// are returned unchanged together with an empty name.
+func nameOperation(query string, name string) (string, string) {
+	trimmed := strings.TrimSpace(query)
+	if name == "" {
+		return query, ""
+	}
+
+	// Name the shorthand form of a query, which has no operation type.
+	if strings.HasPrefix(trimmed, "{") {
+		return "query " + name + " " + trimmed, name
+	}
+
+	// Name the operation if its type is not followed by a name.
+	for _, operationType := range []string{"query", "mutation", "subscription"} {
+		rest, ok := strings.CutPrefix(trimmed, operationType)
+		if !ok {
+			continue
+		}
+		rest = strings.TrimSpace(rest)
+		if strings.HasPrefix(rest, "{") {
+			return operationType + " " + name + " " + rest, name
+		}
+		if strings.HasPrefix(rest, "(") {
+			return operationType + " " + name + rest, name
+		}
+	}
+
+	return query, ""
+}
+
+// This struct holds the criteria the entity search is narrowed down by. Only
+// the criteria that are set are included in the search query.
+type searchCriteria struct {
+	AppID               string
+	ClusterName         string
+	KubernetesNamespace string
+	Domain              string
+	Type                string
+	Language            string
+	WorkloadName        string
+	FullTextSearchTerm  string
+	AccountID           int
+	IncludeDeleted      bool
+}
+
+// This function builds the entity search query from the given criteria. If a
+// workload name is specified, the workload of that name in the given account
+// is searched for instead of the app ID. If a cluster name is specified, the
+// Kubernetes cluster, or the namespace within it, is searched for instead. If
+// a full-text search term is specified, the entities whose name contains the
+// term are searched for. Deleted entities are excluded unless IncludeDeleted
+// is set.
+func buildSearchQuery(criteria searchCriteria) string {
+	var conditions []string
+	if criteria.FullTextSearchTerm != "" {
+		conditions = append(conditions, "name LIKE "+searchValue("%"+criteria.FullTextSearchTerm+"%"))
+	} else if criteria.WorkloadName != "" {
+		conditions = append(conditions, "name = "+searchValue(criteria.WorkloadName))
+		conditions = append(conditions, "type = 'WORKLOAD'")
+		conditions = append(conditions, fmt.Sprintf("accountId = %d", criteria.AccountID))
+	} else if criteria.ClusterName != "" && criteria.KubernetesNamespace != "" {
+		conditions = append(conditions, "tags.clusterName = "+searchValue(criteria.ClusterName))
+		conditions = append(conditions, "tags.namespaceName = "+searchValue(criteria.KubernetesNamespace))
+	} else if criteria.ClusterName != "" {
+		conditions = append(conditions, "name = "+searchValue(criteria.ClusterName))
+		conditions = append(conditions, "type = 'CLUSTER'")
+	} else {
+		conditions = append(conditions, "domainId = "+searchValue(criteria.AppID))
+	}
+
+	// Narrow the search down to the given domain and type, e.g. INFRA/HOST.
+	if criteria.Type != "" {
+		conditions = append(conditions, "type = "+searchValue(criteria.Type))
+	}
+	if criteria.Domain != "" {
+		conditions = append(conditions, "domain = "+searchValue(criteria.Domain))
+	}
+
+	// Narrow the search down to the language of the reporting APM agent.
+	if criteria.Language != "" {
+		conditions = append(conditions, "language = "+searchValue(criteria.Language))
+	}
+
+	// Exclude the deleted entities, which NewRelic retains with a deleted flag.
+	if !criteria.IncludeDeleted {
+		conditions = append(conditions, "deleted = false")
+	}
+
+	return strings.Join(conditions, " AND ")
+}
+
+// This function estimates how expensive the given entity search query is for
+// the NewRelic API, as a rough score. Every predicate adds 5 points, every OR
+// clause 15, every LIKE predicate 10 and every wildcard (% or *) 25 points. A
+// query that is not constrained by an ID, i.e. domainId, guid, id or
+// accountId, scans many entities and adds another 50 points. Keywords and
+// operators within quoted values are ignored.
+func estimateQueryComplexity(query string) int {
+	// Split the query into its unquoted words and count the wildcards,
+	// including those within quoted values.
+	var words []string
+	var word strings.Builder
+	wildcards := 0
+	quoted := false
+	for i := 0; i < len(query); i++ {
+		c := query[i]
+		switch {
+		case c == '%' || c == '*':
+			wildcards++
+		case quoted && c == '\\':
+			i++
+		case c == '\'':
+			quoted = !quoted
+		}
+		if quoted || c == '\'' || c == ' ' || c == '(' || c == ')' {
+			if word.Len() > 0 {
+				words = append(words, word.String())
+				word.Reset()
+			}
+			continue
+		}
+		word.WriteByte(c)
+	}
+	if word.Len() > 0 {
+		words = append(words, word.String())
+	}
+
+	// Score the predicates, OR clauses and LIKE predicates.
+	score := wildcards * 25
+	predicates := 0
+	constrained := false
+	expectField := true
+	for _, word := range words {
+		switch strings.ToUpper(word) {
+		case "AND":
+			expectField = true
+		case "OR":
+			score += 15
+			expectField = true
+		case "LIKE":
+			score += 10
+		default:
+			if !expectField {
+				continue
+			}
+			predicates++
+			expectField = false
+			field, _, _ := strings.Cut(word, "=")
+			switch strings.ToLower(field) {
+			case "domainid", "guid", "id", "accountid":
+				constrained = true
+			}
+		}
+	}
+	score += predicates * 5
+	if !constrained {
+		score += 50
+	}
+
+	return score
+}
+
+// This struct holds a NRQL query generated for an entity and a description
+// of what it shows.
+type NRQLExample struct {
+	Description string `json:"description"`
+	NRQL        string `json:"nrql"`
+}
+
+// This map holds the NRQL query templates for each entity type. Each template
+// contains a %s verb, which is replaced with the quoted GUID of the entity.
+var nrqlExampleTemplates = map[string][]NRQLExample{
+	"APM_APPLICATION_ENTITY": {
+		{"Throughput, response time and error rate over the last hour", "SELECT rate(count(*), 1 minute) AS 'throughput', average(duration) * 1000 AS 'responseTime', percentage(count(*), WHERE error IS true) AS 'errorRate' FROM Transaction WHERE entity.guid = %s SINCE 1 HOUR AGO TIMESERIES"},
+		{"Slowest transactions over the last hour", "SELECT average(duration) * 1000 AS 'responseTime', count(*) FROM Transaction WHERE entity.guid = %s FACET name SINCE 1 HOUR AGO LIMIT 10"},
+		{"Most frequent errors over the last day", "SELECT count(*) FROM TransactionError WHERE entity.guid = %s FACET error.class, error.message SINCE 1 DAY AGO LIMIT 10"},
+	},
+	"BROWSER_APPLICATION_ENTITY": {
+		{"Page load time over the last hour", "SELECT average(duration) AS 'pageLoadTime', percentile(duration, 95) FROM PageView WHERE entityGuid = %s SINCE 1 HOUR AGO TIMESERIES"},
+		{"Most frequent JavaScript errors over the last day", "SELECT count(*) FROM JavaScriptError WHERE entityGuid = %s FACET errorClass, errorMessage SINCE 1 DAY AGO LIMIT 10"},
+		{"Slowest AJAX requests over the last hour", "SELECT average(timeToLoadEventStart) FROM AjaxRequest WHERE entityGuid = %s FACET requestUrl SINCE 1 HOUR AGO LIMIT 10"},
+	},
+	"INFRASTRUCTURE_HOST_ENTITY": {
+		{"CPU and memory usage over the last hour", "SELECT average(cpuPercent), average(memoryUsedPercent) FROM SystemSample WHERE entityGuid = %s SINCE 1 HOUR AGO TIMESERIES"},
+		{"Processes using the most CPU over the last hour", "SELECT average(cpuPercent) FROM ProcessSample WHERE entityGuid = %s FACET processDisplayName SINCE 1 HOUR AGO LIMIT 10"},
+	},
+	"MOBILE_APPLICATION_ENTITY": {
+		{"Sessions and crashes over the last day", "SELECT uniqueCount(sessionId) AS 'sessions', filter(count(*), WHERE eventType() = 'MobileCrash') AS 'crashes' FROM MobileSession, MobileCrash WHERE entityGuid = %s SINCE 1 DAY AGO TIMESERIES"},
+	},
+	"SYNTHETIC_MONITOR_ENTITY": {
+		{"Check results and duration over the last day", "SELECT count(*), average(duration) FROM SyntheticCheck WHERE entityGuid = %s FACET result SINCE 1 DAY AGO TIMESERIES"},
+	},
+}
+
+// This function returns NRQL queries showing typical data of the given
+// entity, with the GUID of the entity filled in. The queries depend on the
+// type of the entity; a query for the logs of the entity is returned for
+// every type.
+func GetEntityNRQLExamples(entity Entity) []NRQLExample {
+	guid := searchValue(entity.GUID)
+	examples := []NRQLExample{}
+	for _, template := range nrqlExampleTemplates[entity.EntityType] {
+		examples = append(examples, NRQLExample{Description: template.Description, NRQL: fmt.Sprintf(template.NRQL, guid)})
+	}
+	examples = append(examples, NRQLExample{
+		Description: "Most recent logs",
+		NRQL:        fmt.Sprintf("SELECT timestamp, level, message FROM Log WHERE entity.guid = %s SINCE 1 HOUR AGO LIMIT 100", guid),
+	})
+	return examples
+}
+
+// This function quotes a value so that it can be used in an entity search
+// query.
+func searchValue(value string) string {
+	return "'" + strings.ReplaceAll(value, "'", "\\'") + "'"
+}
+
+// This function quotes a value so that it can be used as a string argument in
+// a GraphQL query. GraphQL strings are escaped the same way as JSON strings.
+func graphqlString(value string) string {
+	quoted, _ := json.Marshal(value)
+	return string(quoted)
+}
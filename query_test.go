@@ -0,0 +1,141 @@
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestBuildSearchQuery(t *testing.T) {
+	tests := []struct {
+		name     string
+		criteria searchCriteria
+		want     string
+	}{
+		{
+			name:     "app ID",
+			criteria: searchCriteria{AppID: "123"},
+			want:     "domainId = '123' AND deleted = false",
+		},
+		{
+			name:     "app ID narrowed down",
+			criteria: searchCriteria{AppID: "123", Domain: "APM", Type: "APPLICATION"},
+			want:     "domainId = '123' AND type = 'APPLICATION' AND domain = 'APM' AND deleted = false",
+		},
+		{
+			name:     "cluster",
+			criteria: searchCriteria{ClusterName: "prod"},
+			want:     "name = 'prod' AND type = 'CLUSTER' AND deleted = false",
+		},
+		{
+			name:     "Kubernetes namespace",
+			criteria: searchCriteria{ClusterName: "prod", KubernetesNamespace: "checkout"},
+			want:     "tags.clusterName = 'prod' AND tags.namespaceName = 'checkout' AND deleted = false",
+		},
+		{
+			name:     "deleted entities included",
+			criteria: searchCriteria{AppID: "123", IncludeDeleted: true},
+			want:     "domainId = '123'",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := buildSearchQuery(tt.criteria); got != tt.want {
+				t.Errorf("buildSearchQuery() = %s, want %s", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestQuoting(t *testing.T) {
+	if got, want := searchValue(`it's`), `'it\'s'`; got != want {
+		t.Errorf("searchValue() = %s, want %s", got, want)
+	}
+	if got, want := graphqlString("domainId = '1' AND name = \"a\\b\"\n"), `"domainId = '1' AND name = \"a\\b\"\n"`; got != want {
+		t.Errorf("graphqlString() = %s, want %s", got, want)
+	}
+}
+
+func TestEstimateQueryComplexity(t *testing.T) {
+	tests := []struct {
+		query string
+		want  int
+	}{
+		// Two predicates constrained by the app ID.
+		{query: "domainId = '123' AND deleted = false", want: 10},
+		// Two predicates not constrained by an ID.
+		{query: "name = 'prod' AND type = 'CLUSTER'", want: 60},
+		// A LIKE predicate with two wildcards.
+		{query: "name LIKE '%checkout%'", want: 115},
+		// An OR clause of two predicates.
+		{query: "name = 'a' OR name = 'b'", want: 75},
+		// Keywords and operators within quoted values are ignored.
+		{query: "domainId = '123' AND name = 'a OR b'", want: 10},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.query, func(t *testing.T) {
+			if got := estimateQueryComplexity(tt.query); got != tt.want {
+				t.Errorf("estimateQueryComplexity() = %d, want %d", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestGetEntityNRQLExamples(t *testing.T) {
+	tests := []struct {
+		entityType string
+		want       int
+	}{
+		{entityType: "APM_APPLICATION_ENTITY", want: 4},
+		{entityType: "BROWSER_APPLICATION_ENTITY", want: 4},
+		{entityType: "SYNTHETIC_MONITOR_ENTITY", want: 2},
+		{entityType: "UNKNOWN_ENTITY", want: 1},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.entityType, func(t *testing.T) {
+			examples := GetEntityNRQLExamples(Entity{EntityType: tt.entityType, GUID: "MXxBUE18QVBQTElDQVRJT058MQ"})
+			if len(examples) != tt.want {
+				t.Fatalf("GetEntityNRQLExamples() returned %d examples, want %d", len(examples), tt.want)
+			}
+
+			// Every query must have the quoted GUID filled in, and the last
+			// one must be the query of the logs.
+			for _, example := range examples {
+				if !strings.Contains(example.NRQL, "= 'MXxBUE18QVBQTElDQVRJT058MQ'") {
+					t.Errorf("NRQL %q does not contain the quoted GUID", example.NRQL)
+				}
+			}
+			if last := examples[len(examples)-1]; !strings.Contains(last.NRQL, "FROM Log") {
+				t.Errorf("last NRQL = %q, want the query of the logs", last.NRQL)
+			}
+		})
+	}
+}
+
+func TestNameOperation(t *testing.T) {
+	tests := []struct {
+		name      string
+		query     string
+		opName    string
+		wantQuery string
+		wantName  string
+	}{
+		{name: "shorthand query", query: `{ actor { user { name } } }`, opName: "lookup", wantQuery: `query lookup { actor { user { name } } }`, wantName: "lookup"},
+		{name: "anonymous query", query: `query { actor { user { name } } }`, opName: "lookup", wantQuery: `query lookup { actor { user { name } } }`, wantName: "lookup"},
+		{name: "anonymous query with variables", query: `query($guid: EntityGuid!) { actor { entity(guid: $guid) { name } } }`, opName: "lookup", wantQuery: `query lookup($guid: EntityGuid!) { actor { entity(guid: $guid) { name } } }`, wantName: "lookup"},
+		{name: "anonymous mutation", query: `mutation { taggingAddTagsToEntity }`, opName: "tag", wantQuery: `mutation tag { taggingAddTagsToEntity }`, wantName: "tag"},
+		{name: "named query", query: `query existing { actor { user { name } } }`, opName: "lookup", wantQuery: `query existing { actor { user { name } } }`},
+		{name: "no name", query: `{ actor { user { name } } }`, wantQuery: `{ actor { user { name } } }`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			query, name := nameOperation(tt.query, tt.opName)
+			if query != tt.wantQuery || name != tt.wantName {
+				t.Errorf("nameOperation() = %q, %q, want %q, %q", query, name, tt.wantQuery, tt.wantName)
+			}
+		})
+	}
+}
@@ -0,0 +1,76 @@
+package main
+
+import (
+	"context"
+	"math"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// This struct is a token bucket rate limiter. The bucket holds up to one
+// second worth of tokens and is refilled at the configured rate, and every
+// request takes one token out of it. It is safe for concurrent use, so a
+// single instance is shared by all goroutines sending requests.
+type RateLimiter struct {
+	mutex  sync.Mutex
+	rate   float64
+	tokens float64
+	last   time.Time
+}
+
+// This function returns a new RateLimiter that allows the given number of
+// requests per second. The bucket starts full.
+func NewRateLimiter(requestsPerSecond int) *RateLimiter {
+	return &RateLimiter{
+		rate:   float64(requestsPerSecond),
+		tokens: float64(requestsPerSecond),
+		last:   time.Now(),
+	}
+}
+
+// This function blocks until a token is available and takes it out of the
+// bucket. It returns the error of the given context if the context is done
+// before a token is available.
+func (l *RateLimiter) Wait(ctx context.Context) error {
+	for {
+		// Refill the bucket for the time passed since the last call and take a
+		// token out of it, if available.
+		l.mutex.Lock()
+		now := time.Now()
+		l.tokens = math.Min(l.rate, l.tokens+now.Sub(l.last).Seconds()*l.rate)
+		l.last = now
+		if l.tokens >= 1 {
+			l.tokens--
+			l.mutex.Unlock()
+			return nil
+		}
+		wait := time.Duration((1 - l.tokens) / l.rate * float64(time.Second))
+		l.mutex.Unlock()
+
+		// Wait until the next token is available, then try again.
+		timer := time.NewTimer(wait)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return ctx.Err()
+		case <-timer.C:
+		}
+	}
+}
+
+// This struct wraps a HTTPDoer and waits for the rate limiter before sending
+// each request using the wrapped client.
+type rateLimitedClient struct {
+	client  HTTPDoer
+	limiter *RateLimiter
+}
+
+// This function waits for a token of the rate limiter and sends the request
+// using the wrapped client.
+func (c rateLimitedClient) Do(req *http.Request) (*http.Response, error) {
+	if err := c.limiter.Wait(req.Context()); err != nil {
+		return nil, err
+	}
+	return c.client.Do(req)
+}
@@ -0,0 +1,91 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+const (
+	// This constant holds the number of times a request failing with a
+	// transient HTTP status code is sent before giving up.
+	defaultRetryAttempts = 3
+
+	// This constant holds the time to wait before the first retry of a
+	// request. The time is doubled before each further retry.
+	defaultRetryDelay = time.Second
+)
+
+// This struct wraps a HTTPDoer and retries requests failing with a transient
+// HTTP status code, i.e. 429 Too Many Requests or a 502, 503 or 504 gateway
+// error. The log lines of the retries of a request are grouped in the
+// workflow log.
+type retryingClient struct {
+	client   HTTPDoer
+	logger   Logger
+	attempts int
+	delay    time.Duration
+}
+
+// This function sends the request using the wrapped client and retries it
+// while it fails with a transient HTTP status code, up to the number of
+// attempts of the client. The time to wait before a retry is taken from the
+// Retry-After header of the response if it holds a number of seconds. The
+// response of the last attempt is returned.
+func (c retryingClient) Do(req *http.Request) (*http.Response, error) {
+	resp, err := c.client.Do(req)
+	if err != nil || !isTransientStatus(resp.StatusCode) || c.attempts < 2 || req.GetBody == nil {
+		return resp, err
+	}
+
+	// Group the log lines of the retries, so that they are not scattered
+	// between the log lines of the action.
+	defer c.logger.Group(fmt.Sprintf("Retrying %s %s", req.Method, req.URL))()
+
+	delay := c.delay
+	for attempt := 2; attempt <= c.attempts; attempt++ {
+		wait := delay
+		if seconds, err := strconv.Atoi(resp.Header.Get("Retry-After")); err == nil && seconds >= 0 {
+			wait = time.Duration(seconds) * time.Second
+		}
+		c.logger.Printf("Attempt %d of %d failed with HTTP status code %d, retrying in %s\n", attempt-1, c.attempts, resp.StatusCode, wait)
+		resp.Body.Close()
+
+		// Wait before the retry, unless the request is cancelled meanwhile.
+		timer := time.NewTimer(wait)
+		select {
+		case <-req.Context().Done():
+			timer.Stop()
+			return nil, req.Context().Err()
+		case <-timer.C:
+		}
+
+		// Send a copy of the request with a fresh copy of its body, as the
+		// body of the previous attempt has been read.
+		body, err := req.GetBody()
+		if err != nil {
+			return nil, err
+		}
+		retry := req.Clone(req.Context())
+		retry.Body = body
+		resp, err = c.client.Do(retry)
+		if err != nil || !isTransientStatus(resp.StatusCode) {
+			return resp, err
+		}
+		delay *= 2
+	}
+
+	c.logger.Printf("Attempt %d of %d failed with HTTP status code %d, giving up\n", c.attempts, c.attempts, resp.StatusCode)
+	return resp, nil
+}
+
+// This function returns whether the given HTTP status code is caused by a
+// transient condition, so that the request may succeed when sent again.
+func isTransientStatus(statusCode int) bool {
+	switch statusCode {
+	case http.StatusTooManyRequests, http.StatusBadGateway, http.StatusServiceUnavailable, http.StatusGatewayTimeout:
+		return true
+	}
+	return false
+}
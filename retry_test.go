@@ -0,0 +1,166 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// This struct is a Logger that records the log lines, including the lines
+// starting and ending a group.
+type recordingLogger struct {
+	mutex sync.Mutex
+	lines []string
+}
+
+func (l *recordingLogger) Printf(format string, v ...interface{}) {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+	l.lines = append(l.lines, fmt.Sprintf(format, v...))
+}
+
+func (l *recordingLogger) Group(name string) func() {
+	l.Printf("::group::%s\n", name)
+	return func() {
+		l.Printf("::endgroup::\n")
+	}
+}
+
+// This function starts a server answering the requests with the given HTTP
+// status codes in turn, the last one being repeated. The bodies of the
+// received requests are recorded.
+func newStatusSequenceServer(t *testing.T, statusCodes []int, retryAfter string) (*httptest.Server, *recordedQueries) {
+	t.Helper()
+
+	recorded := &recordedQueries{}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		recorded.mutex.Lock()
+		statusCode := statusCodes[min(len(recorded.queries), len(statusCodes)-1)]
+		recorded.queries = append(recorded.queries, string(body))
+		recorded.mutex.Unlock()
+
+		if retryAfter != "" {
+			w.Header().Set("Retry-After", retryAfter)
+		}
+		w.WriteHeader(statusCode)
+	}))
+	t.Cleanup(server.Close)
+
+	return server, recorded
+}
+
+func TestRetryingClient(t *testing.T) {
+	tests := []struct {
+		name        string
+		statusCodes []int
+		retryAfter  string
+		wantStatus  int
+		wantLines   []string
+	}{
+		{
+			name:        "success",
+			statusCodes: []int{200},
+			wantStatus:  200,
+		},
+		{
+			name:        "transient failure",
+			statusCodes: []int{503, 200},
+			wantStatus:  200,
+			wantLines: []string{
+				"::group::Retrying POST <endpoint>\n",
+				"Attempt 1 of 3 failed with HTTP status code 503, retrying in 1ms\n",
+				"::endgroup::\n",
+			},
+		},
+		{
+			name:        "Retry-After header",
+			statusCodes: []int{429, 200},
+			retryAfter:  "0",
+			wantStatus:  200,
+			wantLines: []string{
+				"::group::Retrying POST <endpoint>\n",
+				"Attempt 1 of 3 failed with HTTP status code 429, retrying in 0s\n",
+				"::endgroup::\n",
+			},
+		},
+		{
+			name:        "persistent failure",
+			statusCodes: []int{502},
+			wantStatus:  502,
+			wantLines: []string{
+				"::group::Retrying POST <endpoint>\n",
+				"Attempt 1 of 3 failed with HTTP status code 502, retrying in 1ms\n",
+				"Attempt 2 of 3 failed with HTTP status code 502, retrying in 2ms\n",
+				"Attempt 3 of 3 failed with HTTP status code 502, giving up\n",
+				"::endgroup::\n",
+			},
+		},
+		{
+			name:        "permanent failure",
+			statusCodes: []int{500},
+			wantStatus:  500,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server, recorded := newStatusSequenceServer(t, tt.statusCodes, tt.retryAfter)
+			logger := &recordingLogger{}
+			client := retryingClient{client: server.Client(), logger: logger, attempts: defaultRetryAttempts, delay: time.Millisecond}
+
+			req, err := http.NewRequest("POST", server.URL, strings.NewReader(`{"query":"{ actor { user { name } } }"}`))
+			if err != nil {
+				t.Fatal(err)
+			}
+			resp, err := client.Do(req)
+			if err != nil {
+				t.Fatalf("Do() error = %v", err)
+			}
+			resp.Body.Close()
+			if resp.StatusCode != tt.wantStatus {
+				t.Errorf("Do() status code = %d, want %d", resp.StatusCode, tt.wantStatus)
+			}
+
+			// Each attempt must send the whole body.
+			for _, body := range recorded.all() {
+				if body != `{"query":"{ actor { user { name } } }"}` {
+					t.Errorf("Do() sent the body %q, want the body of the request", body)
+				}
+			}
+
+			var wantLines []string
+			for _, line := range tt.wantLines {
+				wantLines = append(wantLines, strings.ReplaceAll(line, "<endpoint>", server.URL))
+			}
+			if strings.Join(logger.lines, "") != strings.Join(wantLines, "") {
+				t.Errorf("Do() logged %q, want %q", logger.lines, wantLines)
+			}
+		})
+	}
+}
+
+func TestRetryingClientCancelled(t *testing.T) {
+	server, recorded := newStatusSequenceServer(t, []int{503}, "")
+	client := retryingClient{client: server.Client(), logger: &recordingLogger{}, attempts: defaultRetryAttempts, delay: time.Hour}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	req, err := http.NewRequestWithContext(ctx, "POST", server.URL, strings.NewReader("{}"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := client.Do(req); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("Do() error = %v, want context.DeadlineExceeded", err)
+	}
+	if queries := recorded.all(); len(queries) != 1 {
+		t.Errorf("Do() sent %d requests, want 1", len(queries))
+	}
+}
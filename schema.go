@@ -0,0 +1,228 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"os"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// This struct holds the subset of JSON Schema the outputs can be validated
+// against: the type, enum, const, string, number, object and array keywords.
+// Other keywords are ignored.
+type jsonSchema struct {
+	Type                 interface{}            `json:"type"`
+	Enum                 []interface{}          `json:"enum"`
+	Const                interface{}            `json:"const"`
+	Pattern              string                 `json:"pattern"`
+	MinLength            *int                   `json:"minLength"`
+	MaxLength            *int                   `json:"maxLength"`
+	Minimum              *float64               `json:"minimum"`
+	Maximum              *float64               `json:"maximum"`
+	Properties           map[string]*jsonSchema `json:"properties"`
+	Required             []string               `json:"required"`
+	AdditionalProperties *bool                  `json:"additionalProperties"`
+	Items                *jsonSchema            `json:"items"`
+	MinItems             *int                   `json:"minItems"`
+	MaxItems             *int                   `json:"maxItems"`
+}
+
+// This function reads the JSON Schema from the file at the given path.
+func loadJSONSchema(path string) (*jsonSchema, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("reading JSON schema: %w", err)
+	}
+	var schema jsonSchema
+	if err := json.Unmarshal(data, &schema); err != nil {
+		return nil, fmt.Errorf("parsing JSON schema %s: %w", path, err)
+	}
+	return &schema, nil
+}
+
+// This function validates the given value against the JSON Schema and
+// returns an error listing all violations, one per line, each prefixed with
+// the path of the value that violates the schema, e.g. $.guid.
+func validateJSONSchema(schema *jsonSchema, value interface{}) error {
+	// Validate the JSON representation of the value, so that the field names
+	// match the ones of the output.
+	data, err := json.Marshal(value)
+	if err != nil {
+		return err
+	}
+	var document interface{}
+	if err := json.Unmarshal(data, &document); err != nil {
+		return err
+	}
+
+	violations := schema.validate("$", document)
+	if len(violations) > 0 {
+		return fmt.Errorf("JSON schema validation failed:\n  %s", strings.Join(violations, "\n  "))
+	}
+	return nil
+}
+
+// This function returns the violations of the schema by the given value of
+// the decoded JSON document at the given path.
+func (s *jsonSchema) validate(path string, value interface{}) []string {
+	var violations []string
+	violate := func(format string, v ...interface{}) {
+		violations = append(violations, path+": "+fmt.Sprintf(format, v...))
+	}
+
+	// Check the type, enum and const keywords, which apply to any value.
+	if types := s.types(); len(types) > 0 {
+		actual := jsonType(value)
+		matches := false
+		for _, t := range types {
+			if t == actual || (t == "number" && actual == "integer") {
+				matches = true
+			}
+		}
+		if !matches {
+			violate("expected %s, got %s", strings.Join(types, " or "), actual)
+			return violations
+		}
+	}
+	if s.Enum != nil && !containsJSONValue(s.Enum, value) {
+		violate("value %s is not one of the allowed values", jsonText(value))
+	}
+	if s.Const != nil && !equalJSONValues(s.Const, value) {
+		violate("value %s is not %s", jsonText(value), jsonText(s.Const))
+	}
+
+	switch value := value.(type) {
+	case string:
+		// Check the string keywords. The length is counted in characters.
+		length := len([]rune(value))
+		if s.MinLength != nil && length < *s.MinLength {
+			violate("string is shorter than %d characters", *s.MinLength)
+		}
+		if s.MaxLength != nil && length > *s.MaxLength {
+			violate("string is longer than %d characters", *s.MaxLength)
+		}
+		if s.Pattern != "" {
+			pattern, err := regexp.Compile(s.Pattern)
+			if err != nil {
+				violate("invalid pattern %q in schema: %s", s.Pattern, err)
+			} else if !pattern.MatchString(value) {
+				violate("string %q does not match pattern %q", value, s.Pattern)
+			}
+		}
+
+	case float64:
+		// Check the number keywords.
+		if s.Minimum != nil && value < *s.Minimum {
+			violate("%v is less than the minimum %v", value, *s.Minimum)
+		}
+		if s.Maximum != nil && value > *s.Maximum {
+			violate("%v is greater than the maximum %v", value, *s.Maximum)
+		}
+
+	case map[string]interface{}:
+		// Check the object keywords. The properties are checked in
+		// alphabetical order, so that the violations are always listed the
+		// same way.
+		for _, name := range s.Required {
+			if _, ok := value[name]; !ok {
+				violate("required property %q is missing", name)
+			}
+		}
+		names := make([]string, 0, len(value))
+		for name := range value {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		for _, name := range names {
+			if property, ok := s.Properties[name]; ok {
+				violations = append(violations, property.validate(path+"."+name, value[name])...)
+			} else if s.AdditionalProperties != nil && !*s.AdditionalProperties {
+				violate("property %q is not allowed", name)
+			}
+		}
+
+	case []interface{}:
+		// Check the array keywords.
+		if s.MinItems != nil && len(value) < *s.MinItems {
+			violate("array has fewer than %d items", *s.MinItems)
+		}
+		if s.MaxItems != nil && len(value) > *s.MaxItems {
+			violate("array has more than %d items", *s.MaxItems)
+		}
+		if s.Items != nil {
+			for i, item := range value {
+				violations = append(violations, s.Items.validate(fmt.Sprintf("%s[%d]", path, i), item)...)
+			}
+		}
+	}
+
+	return violations
+}
+
+// This function returns the types allowed by the type keyword, which is
+// either a single type or a list of types.
+func (s *jsonSchema) types() []string {
+	switch t := s.Type.(type) {
+	case string:
+		return []string{t}
+	case []interface{}:
+		var types []string
+		for _, item := range t {
+			if name, ok := item.(string); ok {
+				types = append(types, name)
+			}
+		}
+		return types
+	}
+	return nil
+}
+
+// This function returns the JSON Schema type of the given value of a decoded
+// JSON document. Numbers without a fractional part are integers.
+func jsonType(value interface{}) string {
+	switch value := value.(type) {
+	case nil:
+		return "null"
+	case bool:
+		return "boolean"
+	case string:
+		return "string"
+	case float64:
+		if value == math.Trunc(value) {
+			return "integer"
+		}
+		return "number"
+	case []interface{}:
+		return "array"
+	case map[string]interface{}:
+		return "object"
+	}
+	return "unknown"
+}
+
+// This function returns whether the given list contains a value equal to the
+// given value.
+func containsJSONValue(values []interface{}, value interface{}) bool {
+	for _, v := range values {
+		if equalJSONValues(v, value) {
+			return true
+		}
+	}
+	return false
+}
+
+// This function returns whether the given values of decoded JSON documents
+// are equal, by comparing their JSON representation. Object keys are sorted
+// when marshalling, so the order of the keys does not matter.
+func equalJSONValues(a interface{}, b interface{}) bool {
+	return jsonText(a) == jsonText(b)
+}
+
+// This function returns the JSON representation of the given value.
+func jsonText(value interface{}) string {
+	data, _ := json.Marshal(value)
+	return string(data)
+}
@@ -0,0 +1,203 @@
+package main
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/zaljic/newrelic-guid-fetcher-action/pkg/newrelicguid"
+)
+
+// This function writes the given JSON Schema to a file in a temporary
+// directory and returns the path of the file.
+func writeSchemaFile(t *testing.T, schema string) string {
+	t.Helper()
+
+	path := filepath.Join(t.TempDir(), "schema.json")
+	if err := os.WriteFile(path, []byte(schema), 0644); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestValidateJSONSchema(t *testing.T) {
+	tests := []struct {
+		name     string
+		schema   string
+		document string
+		want     []string
+	}{
+		{
+			name:     "type",
+			schema:   `{"type": "string"}`,
+			document: `1`,
+			want:     []string{"$: expected string, got integer"},
+		},
+		{
+			name:     "integer is a number",
+			schema:   `{"type": "number"}`,
+			document: `1`,
+		},
+		{
+			name:     "number is not an integer",
+			schema:   `{"type": "integer"}`,
+			document: `1.5`,
+			want:     []string{"$: expected integer, got number"},
+		},
+		{
+			name:     "list of types",
+			schema:   `{"type": ["string", "null"]}`,
+			document: `null`,
+		},
+		{
+			name:     "required",
+			schema:   `{"type": "object", "required": ["guid", "name"]}`,
+			document: `{"guid": "MXxBUE18QVBQTElDQVRJT058MQ"}`,
+			want:     []string{`$: required property "name" is missing`},
+		},
+		{
+			name:     "enum",
+			schema:   `{"enum": ["APM_APPLICATION_ENTITY", "BROWSER_APPLICATION_ENTITY"]}`,
+			document: `"INFRA_HOST_ENTITY"`,
+			want:     []string{`$: value "INFRA_HOST_ENTITY" is not one of the allowed values`},
+		},
+		{
+			name:     "enum of objects ignores the key order",
+			schema:   `{"enum": [{"a": 1, "b": 2}]}`,
+			document: `{"b": 2, "a": 1}`,
+		},
+		{
+			name:     "nested properties",
+			schema:   `{"properties": {"tags": {"properties": {"env": {"type": "string", "pattern": "^(prod|staging)$"}}}}}`,
+			document: `{"tags": {"env": "dev"}}`,
+			want:     []string{`$.tags.env: string "dev" does not match pattern "^(prod|staging)$"`},
+		},
+		{
+			name:     "items",
+			schema:   `{"type": "array", "items": {"type": "object", "properties": {"accountId": {"type": "integer", "minimum": 1}}}}`,
+			document: `[{"accountId": 1}, {"accountId": 0}, {"accountId": "1"}]`,
+			want:     []string{"$[1].accountId: 0 is less than the minimum 1", "$[2].accountId: expected integer, got string"},
+		},
+		{
+			name:     "additional properties in alphabetical order",
+			schema:   `{"properties": {"guid": {}}, "additionalProperties": false}`,
+			document: `{"name": "checkout", "guid": "MXxBUE18QVBQTElDQVRJT058MQ", "domain": "APM"}`,
+			want:     []string{`$: property "domain" is not allowed`, `$: property "name" is not allowed`},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var schema jsonSchema
+			if err := json.Unmarshal([]byte(tt.schema), &schema); err != nil {
+				t.Fatal(err)
+			}
+			err := validateJSONSchema(&schema, json.RawMessage(tt.document))
+			if len(tt.want) == 0 {
+				if err != nil {
+					t.Errorf("validateJSONSchema() error = %v, want nil", err)
+				}
+				return
+			}
+			want := "JSON schema validation failed:\n  " + strings.Join(tt.want, "\n  ")
+			if err == nil || err.Error() != want {
+				t.Errorf("validateJSONSchema() error = %v, want %s", err, want)
+			}
+		})
+	}
+}
+
+func TestEqualJSONValues(t *testing.T) {
+	tests := []struct {
+		a, b string
+		want bool
+	}{
+		{a: `{"a": 1, "b": [1, 2]}`, b: `{"b": [1, 2], "a": 1}`, want: true},
+		{a: `[1, 2]`, b: `[2, 1]`, want: false},
+		{a: `1`, b: `1.0`, want: true},
+		{a: `"1"`, b: `1`, want: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.a+" "+tt.b, func(t *testing.T) {
+			var a, b interface{}
+			json.Unmarshal([]byte(tt.a), &a)
+			json.Unmarshal([]byte(tt.b), &b)
+			if got := equalJSONValues(a, b); got != tt.want {
+				t.Errorf("equalJSONValues() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestLoadJSONSchema(t *testing.T) {
+	tests := []struct {
+		name    string
+		path    string
+		wantErr string
+	}{
+		{name: "valid", path: writeSchemaFile(t, `{"type": "object", "required": ["guid"]}`)},
+		{name: "missing file", path: filepath.Join(t.TempDir(), "missing.json"), wantErr: "reading JSON schema"},
+		{name: "invalid JSON", path: writeSchemaFile(t, `{"type": `), wantErr: "parsing JSON schema"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			schema, err := loadJSONSchema(tt.path)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Errorf("loadJSONSchema() error = %v, want it to contain %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil || schema.Type != "object" || len(schema.Required) != 1 {
+				t.Errorf("loadJSONSchema() = %+v, %v, want the schema of the file", schema, err)
+			}
+		})
+	}
+}
+
+func TestWriteEntityOutputsJSONSchema(t *testing.T) {
+	tests := []struct {
+		name    string
+		schema  string
+		wantErr string
+	}{
+		{
+			name:   "valid",
+			schema: `{"type": "object", "required": ["schemaVersion", "guid"], "properties": {"guid": {"type": "string"}}}`,
+		},
+		{
+			name:    "invalid",
+			schema:  `{"type": "object", "required": ["owner"], "properties": {"accountId": {"type": "string"}}}`,
+			wantErr: "entityJSON: JSON schema validation failed:\n  $: required property \"owner\" is missing\n  $.accountId: expected string, got integer",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := Config{OutputJSONSchemaFile: writeSchemaFile(t, tt.schema), PermalinkType: newrelicguid.PageSummary}
+			var err error
+			stdout := captureStdout(t, func() {
+				err = writeEntityOutputs(cfg, formatterTestEntities)
+			})
+			if tt.wantErr == "" {
+				if err != nil || !strings.Contains(stdout, "::set-output name=appGUID::") {
+					t.Errorf("writeEntityOutputs() error = %v, stdout = %q, want the output parameters", err, stdout)
+				}
+				return
+			}
+
+			// A violation fails the action before any output parameter is
+			// set.
+			if err == nil || err.Error() != tt.wantErr {
+				t.Errorf("writeEntityOutputs() error = %v, want %s", err, tt.wantErr)
+			}
+			if stdout != "" {
+				t.Errorf("writeEntityOutputs() printed %q, want no output parameters", stdout)
+			}
+		})
+	}
+}
@@ -0,0 +1,79 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"text/template"
+)
+
+// This constant holds the template of the Slack message used if the
+// slack_message_template input parameter is not set.
+const defaultSlackMessageTemplate = "NewRelic entity *{{.Name}}* ({{.EntityType}}) resolved to GUID `{{.GUID}}`: {{.Permalink}}"
+
+// This struct holds the fields of the entity available to the template
+// specified in the slack_message_template input parameter.
+type slackMessageData struct {
+	GUID       string
+	Name       string
+	EntityType string
+	AccountID  int
+	Region     string
+	Permalink  string
+}
+
+// This function renders the given template with the given data and posts the
+// result to the given Slack incoming webhook. If the result is a JSON object,
+// e.g. a message with blocks, it is posted as is, otherwise it is posted as
+// the text of the message. The webhook URL is never included in the errors
+// returned, as it grants access to the channel.
+func postSlackMessage(ctx context.Context, client HTTPDoer, webhookURL string, messageTemplate *template.Template, data slackMessageData) error {
+	// Render the message from the template.
+	var message strings.Builder
+	if err := messageTemplate.Execute(&message, data); err != nil {
+		return fmt.Errorf("rendering Slack message: %w", err)
+	}
+
+	// Post the rendered message as is if it is a JSON object, otherwise wrap
+	// it into a message payload.
+	payload := []byte(message.String())
+	var object map[string]interface{}
+	if json.Unmarshal(payload, &object) != nil {
+		var err error
+		payload, err = json.Marshal(map[string]string{"text": message.String()})
+		if err != nil {
+			return err
+		}
+	}
+
+	// Create a HTTP POST request to the webhook.
+	req, err := http.NewRequestWithContext(ctx, "POST", webhookURL, bytes.NewReader(payload))
+	if err != nil {
+		return errors.New("posting Slack message: invalid webhook URL")
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	// Send the HTTP request using the given client. The error of the client
+	// contains the URL, so only the underlying error is returned.
+	resp, err := client.Do(req)
+	if err != nil {
+		var urlErr *url.Error
+		if errors.As(err, &urlErr) {
+			err = urlErr.Err
+		}
+		return fmt.Errorf("posting Slack message: %w", err)
+	}
+	defer resp.Body.Close()
+
+	// Return an error if Slack rejected the message.
+	if resp.StatusCode != 200 {
+		return fmt.Errorf("posting Slack message: HTTP status code %d", resp.StatusCode)
+	}
+
+	return nil
+}
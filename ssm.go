@@ -0,0 +1,43 @@
+package main
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	awsconfig "github.com/aws/aws-sdk-go-v2/config"
+	"github.com/aws/aws-sdk-go-v2/service/ssm"
+	"github.com/aws/aws-sdk-go-v2/service/ssm/types"
+)
+
+// This function fetches and decrypts the parameter with the given name from
+// the AWS SSM Parameter Store. The credentials and region are read from the
+// standard AWS credential chain, e.g. the AWS_* environment variables set by
+// aws-actions/configure-aws-credentials. A warning is printed if the parameter
+// is not a SecureString, as the API key is then stored unencrypted.
+func fetchSSMParameter(ctx context.Context, name string) (string, error) {
+	// Load the AWS configuration from the standard credential chain.
+	awsConfig, err := awsconfig.LoadDefaultConfig(ctx)
+	if err != nil {
+		return "", fmt.Errorf("loading AWS configuration: %w", err)
+	}
+
+	// Fetch the parameter, decrypting it if it is a SecureString.
+	output, err := ssm.NewFromConfig(awsConfig).GetParameter(ctx, &ssm.GetParameterInput{
+		Name:           aws.String(name),
+		WithDecryption: aws.Bool(true),
+	})
+	if err != nil {
+		return "", fmt.Errorf("fetching SSM parameter %s: %w", name, err)
+	}
+	if output.Parameter == nil || aws.ToString(output.Parameter.Value) == "" {
+		return "", fmt.Errorf("SSM parameter %s is empty", name)
+	}
+
+	// Warn about API keys that are stored unencrypted.
+	if output.Parameter.Type != types.ParameterTypeSecureString {
+		fmt.Printf("::warning::SSM parameter %s is a %s parameter, please store the NewRelic API key as SecureString so that it is encrypted\n", name, output.Parameter.Type)
+	}
+
+	return aws.ToString(output.Parameter.Value), nil
+}
@@ -0,0 +1,123 @@
+// This program checks that the inputs declared in action.yml match the
+// INPUT_* environment variables read by the action. It reports every input
+// that is only declared in action.yml or only read by the Go code, and exits
+// with a non-zero exit code if there is any. It is run from the root of the
+// repository:
+//
+//	go run ./tools/validate-action-yml
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// This regular expression matches the os.Getenv call sites reading an input
+// parameter and captures the name of the environment variable.
+var getenvPattern = regexp.MustCompile(`os\.Getenv\("(INPUT_[A-Za-z0-9_]+)"\)`)
+
+// This regular expression matches the name of an input declared in the inputs
+// section of action.yml.
+var inputPattern = regexp.MustCompile(`^  ([A-Za-z0-9_-]+):`)
+
+// This function is the entry point of the program.
+func main() {
+	declared, err := actionInputs("action.yml")
+	if err != nil {
+		fmt.Println(err)
+		os.Exit(1)
+	}
+	read, err := codeInputs(".")
+	if err != nil {
+		fmt.Println(err)
+		os.Exit(1)
+	}
+
+	// Report the inputs that only appear on one side.
+	problems := 0
+	for _, name := range sortedKeys(declared) {
+		if !read[name] {
+			fmt.Printf("%s is declared in action.yml but never read by the action\n", name)
+			problems++
+		}
+	}
+	for _, name := range sortedKeys(read) {
+		if !declared[name] {
+			fmt.Printf("%s is read by the action but not declared in action.yml\n", name)
+			problems++
+		}
+	}
+	if problems > 0 {
+		os.Exit(1)
+	}
+	fmt.Println("action.yml matches the inputs read by the action.")
+}
+
+// This function returns the environment variables of the inputs declared in
+// the inputs section of the given action.yml. The runner sets each input as
+// INPUT_ followed by the upper-cased name of the input.
+func actionInputs(path string) (map[string]bool, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	inputs := map[string]bool{}
+	inInputs := false
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		line := scanner.Text()
+
+		// Track whether the line is within the top-level inputs section.
+		if line != "" && !strings.HasPrefix(line, " ") && !strings.HasPrefix(line, "#") {
+			inInputs = strings.HasPrefix(line, "inputs:")
+			continue
+		}
+		if match := inputPattern.FindStringSubmatch(line); inInputs && match != nil {
+			inputs["INPUT_"+strings.ToUpper(strings.ReplaceAll(match[1], " ", "_"))] = true
+		}
+	}
+
+	return inputs, scanner.Err()
+}
+
+// This function returns the input environment variables read by the Go files
+// of the action in the given directory. Test files are ignored.
+func codeInputs(dir string) (map[string]bool, error) {
+	files, err := filepath.Glob(filepath.Join(dir, "*.go"))
+	if err != nil {
+		return nil, err
+	}
+
+	inputs := map[string]bool{}
+	for _, file := range files {
+		if strings.HasSuffix(file, "_test.go") {
+			continue
+		}
+		source, err := os.ReadFile(file)
+		if err != nil {
+			return nil, err
+		}
+		for _, match := range getenvPattern.FindAllStringSubmatch(string(source), -1) {
+			inputs[match[1]] = true
+		}
+	}
+
+	return inputs, nil
+}
+
+// This function returns the keys of the given set in alphabetical order.
+func sortedKeys(set map[string]bool) []string {
+	keys := make([]string, 0, len(set))
+	for key := range set {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
@@ -0,0 +1,63 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+)
+
+// This function fetches the secret at the given path from the KV secrets
+// engine of the HashiCorp Vault server at vaultAddr and returns the value of
+// the given field. The path has the format "<path>#<field>", for example
+// "secret/data/newrelic#apiKey". Both version 1 and version 2 of the KV secrets
+// engine are supported.
+func fetchVaultSecret(ctx context.Context, client HTTPDoer, vaultAddr string, vaultToken string, vaultPath string) (string, error) {
+	// Split the path into the secret path and the field.
+	path, field, ok := strings.Cut(vaultPath, "#")
+	if !ok || path == "" || field == "" {
+		return "", fmt.Errorf("invalid Vault path %q, expected <path>#<field>", vaultPath)
+	}
+
+	// Create a HTTP GET request for the secret.
+	url := strings.TrimRight(vaultAddr, "/") + "/v1/" + strings.TrimLeft(path, "/")
+	req, err := http.NewRequestWithContext(ctx, "GET", url, nil)
+	if err != nil {
+		return "", err
+	}
+	req.Header.Set("X-Vault-Token", vaultToken)
+
+	// Send the HTTP request using the given client.
+	resp, err := client.Do(req)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+
+	// Return an error if the HTTP status code is not 200.
+	if resp.StatusCode != 200 {
+		return "", fmt.Errorf("fetching Vault secret %s: HTTP status code %d", path, resp.StatusCode)
+	}
+
+	// Unmarshal the secret. Version 2 of the KV secrets engine nests the secret
+	// in a second data object.
+	var secret struct {
+		Data map[string]interface{} `json:"data"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&secret); err != nil {
+		return "", err
+	}
+	values := secret.Data
+	if nested, ok := values["data"].(map[string]interface{}); ok {
+		values = nested
+	}
+
+	// Return the value of the field.
+	value, ok := values[field].(string)
+	if !ok || value == "" {
+		return "", fmt.Errorf("Vault secret %s has no field %q", path, field)
+	}
+
+	return value, nil
+}